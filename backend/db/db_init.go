@@ -29,8 +29,16 @@ func NewDatabase(dbPath string) (*Database, error) {
 		return nil, err
 	}
 
+	if err := seedRoles(db); err != nil {
+		return nil, err
+	}
+
 	AddDefaultAdmin(db)
 
+	if err := seedDefaultOrganization(db); err != nil {
+		return nil, err
+	}
+
 	log.Printf("Connected to database: %s", dbPath)
 	return &Database{DB: db}, nil
 }
@@ -42,15 +50,56 @@ func initSchema(db *sql.DB) error {
 	}
 
 	schemaStatements := []string{
+		`CREATE TABLE IF NOT EXISTS organizations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			slug TEXT UNIQUE NOT NULL,
+			name TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+
 		`CREATE TABLE IF NOT EXISTS users (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			username TEXT UNIQUE NOT NULL,
 			password_hash TEXT NOT NULL,
+			display_name TEXT NOT NULL DEFAULT '',
+			email TEXT,
+			email_verified_at TIMESTAMP,
+			organization TEXT NOT NULL DEFAULT '',
+			bio TEXT NOT NULL DEFAULT '',
+			preferred_language TEXT NOT NULL DEFAULT '',
+			timezone TEXT NOT NULL DEFAULT '',
+			avatar_image_id INTEGER REFERENCES images(id) ON DELETE SET NULL,
 			role TEXT NOT NULL DEFAULT 'user',
 			tickets INTEGER DEFAULT 999,
+			totp_secret TEXT,
+			totp_pending_secret TEXT,
+			totp_enabled INTEGER NOT NULL DEFAULT 0,
+			totp_last_step INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS images (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			hash TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			width INTEGER NOT NULL,
+			height INTEGER NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);`,
 
+		`CREATE TABLE IF NOT EXISTS image_variants (
+			image_id INTEGER NOT NULL,
+			size TEXT NOT NULL,
+			blob_key TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			width INTEGER NOT NULL,
+			height INTEGER NOT NULL,
+			byte_size INTEGER NOT NULL,
+			PRIMARY KEY (image_id, size),
+			FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE
+		);`,
+
 		`CREATE TABLE IF NOT EXISTS events (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			name TEXT NOT NULL,
@@ -59,7 +108,13 @@ func initSchema(db *sql.DB) error {
 			date TIMESTAMP,
 			venue TEXT NOT NULL,
 			price REAL NOT NULL,
-			image BLOB
+			image BLOB,
+			image_id INTEGER REFERENCES images(id) ON DELETE SET NULL,
+			version INTEGER NOT NULL DEFAULT 1,
+			text_updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			organization_id INTEGER REFERENCES organizations(id),
+			deleted_at TIMESTAMP
 		);`,
 
 		`CREATE TABLE IF NOT EXISTS event_translations (
@@ -69,17 +124,216 @@ func initSchema(db *sql.DB) error {
 			name TEXT NOT NULL,
 			description TEXT NOT NULL,
 			venue TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
 		);`,
 
+		`CREATE TABLE IF NOT EXISTS event_media (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id INTEGER NOT NULL,
+			image_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+			caption TEXT NOT NULL DEFAULT '',
+			alt_text TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+			FOREIGN KEY (image_id) REFERENCES images(id)
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS event_media_translations (
+			media_id INTEGER NOT NULL,
+			language TEXT NOT NULL,
+			caption TEXT NOT NULL DEFAULT '',
+			alt_text TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (media_id, language),
+			FOREIGN KEY (media_id) REFERENCES event_media(id) ON DELETE CASCADE
+		);`,
+
 		`CREATE TABLE IF NOT EXISTS registrations (
 			user_id INTEGER NOT NULL,
 			event_id INTEGER NOT NULL,
 			registered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			checked_in_at TIMESTAMP,
 			PRIMARY KEY (user_id, event_id),
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
 			FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
 		);`,
+
+		`CREATE TABLE IF NOT EXISTS event_organizers (
+			event_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (event_id, user_id),
+			FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS organization_members (
+			organization_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			role TEXT NOT NULL DEFAULT 'user',
+			joined_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (organization_id, user_id),
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			family_id TEXT NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			access_token_id TEXT NOT NULL,
+			access_expires_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			used_at TIMESTAMP,
+			revoked_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			family_id TEXT UNIQUE NOT NULL,
+			device TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			key_hash TEXT UNIQUE NOT NULL,
+			scopes TEXT NOT NULL DEFAULT '',
+			organization_id INTEGER,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP,
+			last_used_at TIMESTAMP,
+			last_used_ip TEXT,
+			revoked_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE SET NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS impersonation_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			token_id TEXT NOT NULL,
+			method TEXT NOT NULL,
+			path TEXT NOT NULL,
+			status INTEGER NOT NULL,
+			ip TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS email_verification_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			email TEXT NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			used_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS password_reset_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT UNIQUE NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			used_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS login_failures (
+			kind TEXT NOT NULL,
+			value TEXT NOT NULL,
+			failures INTEGER NOT NULL,
+			last_failed_at TIMESTAMP NOT NULL,
+			locked_until TIMESTAMP,
+			PRIMARY KEY (kind, value)
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			code_hash TEXT NOT NULL,
+			used_at TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS roles (
+			name TEXT PRIMARY KEY,
+			description TEXT NOT NULL DEFAULT '',
+			builtin INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS permissions (
+			name TEXT PRIMARY KEY,
+			description TEXT NOT NULL DEFAULT ''
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS role_permissions (
+			role TEXT NOT NULL,
+			permission TEXT NOT NULL,
+			PRIMARY KEY (role, permission),
+			FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE,
+			FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS user_identities (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			issuer TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (issuer, subject),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS oidc_logins (
+			state_hash TEXT PRIMARY KEY,
+			code_verifier TEXT NOT NULL,
+			nonce TEXT NOT NULL,
+			user_id INTEGER,
+			browser_hash TEXT NOT NULL DEFAULT '',
+			expires_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS revoked_tokens (
+			token_id TEXT PRIMARY KEY,
+			expires_at TIMESTAMP NOT NULL
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS event_revisions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_id INTEGER NOT NULL,
+			revision INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			author TEXT NOT NULL,
+			snapshot TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (event_id, revision),
+			FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+		);`,
 	}
 
 	for _, stmt := range schemaStatements {
@@ -88,10 +342,176 @@ func initSchema(db *sql.DB) error {
 		}
 	}
 
+	// Columns added after the first release, databases created before them need an ALTER
+	columnMigrations := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"users", "deleted_at", "TIMESTAMP"},
+		{"events", "deleted_at", "TIMESTAMP"},
+		{"events", "version", "INTEGER NOT NULL DEFAULT 1"},
+		{"events", "image_id", "INTEGER REFERENCES images(id) ON DELETE SET NULL"},
+		// SQLite can't add a column defaulting to CURRENT_TIMESTAMP, rows from before
+		// stay NULL which the translation report reads as "not known to be stale"
+		{"events", "text_updated_at", "TIMESTAMP"},
+		{"event_translations", "updated_at", "TIMESTAMP"},
+		{"users", "totp_secret", "TEXT"},
+		{"users", "totp_pending_secret", "TEXT"},
+		{"users", "totp_enabled", "INTEGER NOT NULL DEFAULT 0"},
+		{"users", "totp_last_step", "INTEGER NOT NULL DEFAULT 0"},
+		// Events from before ownership have no creator and only admins manage them
+		{"events", "created_by", "INTEGER REFERENCES users(id) ON DELETE SET NULL"},
+		{"registrations", "checked_in_at", "TIMESTAMP"},
+		// Filled in with the default organization by seedDefaultOrganization
+		{"events", "organization_id", "INTEGER REFERENCES organizations(id)"},
+		{"users", "display_name", "TEXT NOT NULL DEFAULT ''"},
+		{"users", "email", "TEXT"},
+		{"users", "email_verified_at", "TIMESTAMP"},
+		{"users", "organization", "TEXT NOT NULL DEFAULT ''"},
+		{"users", "bio", "TEXT NOT NULL DEFAULT ''"},
+		{"users", "preferred_language", "TEXT NOT NULL DEFAULT ''"},
+		{"users", "timezone", "TEXT NOT NULL DEFAULT ''"},
+		{"users", "avatar_image_id", "INTEGER REFERENCES images(id) ON DELETE SET NULL"},
+		// Logins started before this have no browser to match and can't finish
+		{"oidc_logins", "browser_hash", "TEXT NOT NULL DEFAULT ''"},
+	}
+
+	for _, m := range columnMigrations {
+		if err := addColumnIfMissing(db, m.table, m.column, m.definition); err != nil {
+			return err
+		}
+	}
+
+	// Older databases may hold the same language twice for an event, keep the latest
+	// one so the unique index can be created
+	indexStatements := []string{
+		`DELETE FROM event_translations WHERE id NOT IN (
+			SELECT MAX(id) FROM event_translations GROUP BY event_id, language
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_event_translations_event_language
+			ON event_translations (event_id, language);`,
+		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens (family_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_access_token ON refresh_tokens (access_token_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_events_created_by ON events (created_by);`,
+		`CREATE INDEX IF NOT EXISTS idx_event_organizers_user ON event_organizers (user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_events_organization ON events (organization_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_organization_members_user ON organization_members (user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys (user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_impersonation_log_user ON impersonation_log (user_id);`,
+		// SQLite allows any number of NULLs in a unique index, so users without an email don't clash
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);`,
+	}
+
+	for _, stmt := range indexStatements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("index creation failed: %w", err)
+		}
+	}
+
 	log.Println("Database schema initialized")
 	return nil
 }
 
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return fmt.Errorf("failed to scan columns of %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// seedRoles writes the known permissions and the built-in roles, giving the
+// admin role every permission. The user role starts without any, which is
+// what users could do before roles had permissions, and organizers can only
+// create events, managing just those they organize.
+func seedRoles(db *sql.DB) error {
+	for _, p := range helpers.Permissions {
+		_, err := db.Exec(
+			"INSERT INTO permissions (name, description) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET description = excluded.description",
+			p.Name, p.Description,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to seed permission %s: %w", p.Name, err)
+		}
+	}
+
+	seedStatements := []string{
+		`INSERT OR IGNORE INTO roles (name, description, builtin) VALUES ('admin', 'Full access to everything', 1);`,
+		`INSERT OR IGNORE INTO roles (name, description, builtin) VALUES ('user', 'Default role of new users', 1);`,
+		`INSERT OR IGNORE INTO role_permissions (role, permission) SELECT 'admin', name FROM permissions;`,
+	}
+
+	for _, stmt := range seedStatements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to seed roles: %w", err)
+		}
+	}
+
+	// Only granted when the role is new, so admins can change it afterwards
+	result, err := db.Exec(`INSERT OR IGNORE INTO roles (name, description, builtin) VALUES ('organizer', 'Creates events and manages the ones they organize', 1);`)
+	if err != nil {
+		return fmt.Errorf("failed to seed roles: %w", err)
+	}
+	if created, err := result.RowsAffected(); err == nil && created > 0 {
+		if _, err := db.Exec("INSERT OR IGNORE INTO role_permissions (role, permission) VALUES ('organizer', ?)", helpers.PermEventsCreate); err != nil {
+			return fmt.Errorf("failed to seed roles: %w", err)
+		}
+	}
+	return nil
+}
+
+// seedDefaultOrganization creates the organization everything from before
+// organizations belongs to. Users only join it along with it, later ones join
+// through DEFAULT_ORGANIZATION, but events without an organization are moved
+// into it on every start.
+func seedDefaultOrganization(db *sql.DB) error {
+	result, err := db.Exec(`INSERT OR IGNORE INTO organizations (slug, name) VALUES ('default', 'Default');`)
+	if err != nil {
+		return fmt.Errorf("failed to seed default organization: %w", err)
+	}
+	if created, err := result.RowsAffected(); err == nil && created > 0 {
+		_, err := db.Exec(
+			`INSERT OR IGNORE INTO organization_members (organization_id, user_id, role)
+			 SELECT o.id, u.id, u.role FROM organizations o, users u WHERE o.slug = 'default'`,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to seed default organization: %w", err)
+		}
+	}
+
+	_, err = db.Exec(`UPDATE events SET organization_id = (SELECT id FROM organizations WHERE slug = 'default') WHERE organization_id IS NULL;`)
+	if err != nil {
+		return fmt.Errorf("failed to seed default organization: %w", err)
+	}
+	return nil
+}
+
 func AddDefaultAdmin(db *sql.DB) {
 	password := "admin"
 	hashedPassword, err := helpers.HashPassword(password)
@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// NormalizeEmail validates a bare email address and lowercases it, so the
+// same mailbox can't be registered twice with different casing.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", fmt.Errorf("email is required")
+	}
+	if len(email) > 254 {
+		return "", fmt.Errorf("email must be at most 254 characters")
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return "", fmt.Errorf("'%s' is not a valid email address", email)
+	}
+
+	_, domain, _ := strings.Cut(email, "@")
+	if !strings.Contains(domain, ".") {
+		return "", fmt.Errorf("'%s' is not a valid email address", email)
+	}
+	return email, nil
+}
+
+// NormalizeDisplayName trims name and checks it is short enough to show next
+// to others and free of control characters. An empty name is allowed, clients
+// show the username then.
+func NormalizeDisplayName(name string) (string, error) {
+	return NormalizeProfileText("display name", name, 64, false)
+}
+
+// RequireEmailVerification is whether users need a verified email address
+// before they can book an event, REQUIRE_EMAIL_VERIFICATION.
+func RequireEmailVerification() bool {
+	return GetEnv("REQUIRE_EMAIL_VERIFICATION", "false") == "true"
+}
@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func VersionETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// AnyVersion is what RequireIfMatchVersion returns for "If-Match: *", which
+// matches whatever version the resource is at as long as it exists.
+const AnyVersion int64 = -1
+
+// RequireIfMatchVersion reads the resource version the client last saw from the
+// If-Match header, answering 428 when it is missing and 412 when it is unusable.
+func RequireIfMatchVersion(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		HttpError(w, http.StatusPreconditionRequired, "missing If-Match header, send the ETag you got when reading the resource")
+		return 0, false
+	}
+	if ifMatch == "*" {
+		return AnyVersion, true
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(ifMatch, `"`), 10, 64)
+	if err != nil || strings.HasPrefix(ifMatch, "W/") {
+		HttpError(w, http.StatusPreconditionFailed, "If-Match does not match the current version of the resource")
+		return 0, false
+	}
+
+	return version, true
+}
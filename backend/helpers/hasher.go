@@ -1,16 +1,178 @@
 package helpers
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
 
-var HashPassword = func(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher hashes passwords into a self describing string, so hashes
+// made with another algorithm or other parameters can still be checked.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Owns reports whether hash was made with this hasher's algorithm.
+	Owns(hash string) bool
+	Verify(password, hash string) bool
+	// NeedsRehash reports whether hash was made with other parameters.
+	NeedsRehash(hash string) bool
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
 	if err != nil {
 		return "", err
 	}
 	return string(hashedPassword), nil
 }
 
+func (h BcryptHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (h BcryptHasher) Verify(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher encodes hashes in the PHC string format,
+// $argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>.
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   uint32
+}
+
+type argon2idHash struct {
+	memory, iterations uint32
+	parallelism        uint8
+	salt, key          []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (h Argon2idHasher) Verify(password, hash string) bool {
+	decoded, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	key := argon2.IDKey([]byte(password), decoded.salt, decoded.iterations, decoded.memory, decoded.parallelism, uint32(len(decoded.key)))
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	decoded, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return decoded.memory != h.Memory ||
+		decoded.iterations != h.Iterations ||
+		decoded.parallelism != h.Parallelism ||
+		len(decoded.salt) != h.SaltLength ||
+		len(decoded.key) != int(h.KeyLength)
+}
+
+func decodeArgon2id(hash string) (*argon2idHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, fmt.Errorf("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var decoded argon2idHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &decoded.memory, &decoded.iterations, &decoded.parallelism); err != nil {
+		return nil, fmt.Errorf("invalid argon2 parameters %q: %w", parts[3], err)
+	}
+
+	var err error
+	if decoded.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2 salt: %w", err)
+	}
+	if decoded.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("invalid argon2 key: %w", err)
+	}
+	return &decoded, nil
+}
+
+// PasswordHasherFromEnv is the hasher new passwords are hashed with, picked by
+// PASSWORD_HASH_ALGORITHM ("argon2id" or "bcrypt") and tuned by the
+// ARGON2_* and BCRYPT_COST variables. The argon2id defaults follow the OWASP
+// recommendation.
+func PasswordHasherFromEnv() PasswordHasher {
+	if GetEnv("PASSWORD_HASH_ALGORITHM", "argon2id") == "bcrypt" {
+		return BcryptHasher{Cost: GetEnvInt("BCRYPT_COST", bcrypt.DefaultCost)}
+	}
+
+	return Argon2idHasher{
+		Memory:      uint32(GetEnvInt("ARGON2_MEMORY_KIB", 19*1024)),
+		Iterations:  uint32(GetEnvInt("ARGON2_ITERATIONS", 2)),
+		Parallelism: uint8(GetEnvInt("ARGON2_PARALLELISM", 1)),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// hasherFor finds the hasher that made hash, with the configured parameters
+// when it is the configured algorithm.
+func hasherFor(hash string) PasswordHasher {
+	current := PasswordHasherFromEnv()
+	for _, hasher := range []PasswordHasher{current, BcryptHasher{}, Argon2idHasher{}} {
+		if hasher.Owns(hash) {
+			return hasher
+		}
+	}
+	return nil
+}
+
+var HashPassword = func(password string) (string, error) {
+	return PasswordHasherFromEnv().Hash(password)
+}
+
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	hasher := hasherFor(hash)
+	return hasher != nil && hasher.Verify(password, hash)
+}
+
+// PasswordNeedsRehash reports whether hash was made with another algorithm or
+// other parameters than the configured ones, meaning the password should be
+// hashed again the next time it is known.
+func PasswordNeedsRehash(hash string) bool {
+	current := PasswordHasherFromEnv()
+	return !current.Owns(hash) || current.NeedsRehash(hash)
 }
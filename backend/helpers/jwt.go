@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,83 +12,325 @@ import (
 
 var secretKey = []byte(os.Getenv("JWT_SECRET_KEY"))
 
-func CreateToken(username string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"username": username,
-			"exp":      time.Now().Add(time.Hour * 24).Unix(),
-		})
+// IsTokenRevoked reports whether the access token with the given jti was
+// revoked before it expired. main points it at the revoked token store.
+var IsTokenRevoked = func(tokenID string) bool {
+	return false
+}
+
+// AccessToken is a signed access token along with the claims needed to
+// revoke it later.
+type AccessToken struct {
+	Token    string
+	ID       string
+	UserID   int64
+	Username string
+	Role     string
+	// OrgID is the organization the token was issued for, 0 for none
+	OrgID     int64
+	ExpiresAt time.Time
+	// Actor is who is impersonating the user, nil for their own tokens
+	Actor *Actor
+}
+
+func (t *AccessToken) Principal() *Principal {
+	return &Principal{
+		ID:        t.UserID,
+		Username:  t.Username,
+		Role:      t.Role,
+		OrgID:     t.OrgID,
+		TokenID:   t.ID,
+		ExpiresAt: t.ExpiresAt,
+		Actor:     t.Actor,
+	}
+}
+
+// AccessTokenTTL is how long access tokens are valid, ACCESS_TOKEN_TTL or 15 minutes.
+func AccessTokenTTL() time.Duration {
+	return GetEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// NewAccessToken carries the id and role of the user next to the username, so
+// requests can be authorized without looking the user up, and the organization
+// they act in unless orgID is 0.
+func NewAccessToken(userID int64, username, role string, orgID int64) (*AccessToken, error) {
+	return newAccessToken(userID, username, role, orgID, nil, AccessTokenTTL())
+}
+
+func newAccessToken(userID int64, username, role string, orgID int64, actor *Actor, ttl time.Duration) (*AccessToken, error) {
+	tokenID, err := RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	claims := jwt.MapClaims{
+		"sub":      strconv.FormatInt(userID, 10),
+		"username": username,
+		"role":     role,
+		"jti":      tokenID,
+		"exp":      expiresAt.Unix(),
+	}
+	if orgID != 0 {
+		claims["org"] = strconv.FormatInt(orgID, 10)
+	}
+	if actor != nil {
+		claims["act"] = map[string]any{
+			"sub":      strconv.FormatInt(actor.ID, 10),
+			"username": actor.Username,
+		}
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenString, err := token.SignedString(secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AccessToken{
+		Token:     tokenString,
+		ID:        tokenID,
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		OrgID:     orgID,
+		ExpiresAt: expiresAt,
+		Actor:     actor,
+	}, nil
+}
+
+func CreateToken(userID int64, username, role string) (string, error) {
+	token, err := NewAccessToken(userID, username, role, 0)
 	if err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	return token.Token, nil
 }
 
-func verifyToken(tokenString string) (string, error) {
+func parseToken(tokenString string) (*AccessToken, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
 		return secretKey, nil
 	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return "", fmt.Errorf("invalid token")
+		return nil, fmt.Errorf("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", fmt.Errorf("failed to extract claims")
+		return nil, fmt.Errorf("failed to extract claims")
+	}
+
+	// Challenge tokens are signed with the same key but are no access tokens
+	if _, ok := claims["purpose"]; ok {
+		return nil, fmt.Errorf("not an access token")
 	}
 
 	username, ok := claims["username"].(string)
 	if !ok {
-		return "", fmt.Errorf("username claim not found or not a string")
+		return nil, fmt.Errorf("username claim not found or not a string")
 	}
 
-	return username, nil
-}
+	subject, _ := claims["sub"].(string)
+	userID, err := strconv.ParseInt(subject, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("sub claim not found or not a user id")
+	}
 
-func GetUserNameFromToken(r *http.Request) (string, error) {
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
-		return "", fmt.Errorf("request does not contain an access token")
+	role, ok := claims["role"].(string)
+	if !ok {
+		return nil, fmt.Errorf("role claim not found or not a string")
 	}
 
-	tokenString = tokenString[len("Bearer "):]
+	// Tokens from before organizations have no org claim and act in none
+	var orgID int64
+	if org, ok := claims["org"].(string); ok {
+		orgID, err = strconv.ParseInt(org, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("org claim is not an organization id")
+		}
+	}
 
-	username, err := verifyToken(tokenString)
-	if err != nil {
-		return "", err
+	var actor *Actor
+	if act, ok := claims["act"]; ok {
+		actor, err = parseActor(act)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Tokens without an id can't be revoked, so they aren't accepted either
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		return nil, fmt.Errorf("jti claim not found or not a string")
 	}
 
-	return username, nil
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, fmt.Errorf("exp claim not found")
+	}
+
+	if IsTokenRevoked(tokenID) {
+		return nil, fmt.Errorf("token has been revoked")
+	}
+
+	return &AccessToken{
+		Token:     tokenString,
+		ID:        tokenID,
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		OrgID:     orgID,
+		ExpiresAt: expiresAt.Time,
+		Actor:     actor,
+	}, nil
 }
 
-func ProtectedHandler(w http.ResponseWriter, r *http.Request, isQualifiedCallback func(username string) bool, handler func(w http.ResponseWriter, r *http.Request)) {
+// ProtectedHandler only lets requests with a valid access token or API key
+// reach handler, and only when isQualifiedCallback, if given, approves of their
+// principal. The principal comes from Authenticate, so checks need no queries.
+func ProtectedHandler(w http.ResponseWriter, r *http.Request, isQualifiedCallback func(p *Principal) bool, handler func(w http.ResponseWriter, r *http.Request)) {
 	w.Header().Set("Content-Type", "application/json")
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
+	if r.Header.Get("Authorization") == "" {
 		HttpError(w, http.StatusUnauthorized, "request does not contain an access token")
 		return
 	}
 
-	tokenString = tokenString[len("Bearer "):]
+	principal := GetPrincipal(r)
+	if principal == nil {
+		// Routes mounted without Authenticate still get their token checked
+		principal = principalFromHeader(r)
+		if principal == nil {
+			HttpError(w, http.StatusUnauthorized, "invalid token, you dont have permission for this route")
+			return
+		}
+		if status, err := selectOrganization(r, principal); err != nil {
+			HttpError(w, status, err.Error())
+			return
+		}
+		r = r.WithContext(WithPrincipal(r.Context(), principal))
+		if principal.Actor != nil {
+			auditImpersonation(w, r, principal, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorize(w, r, principal, isQualifiedCallback, handler)
+			}))
+			return
+		}
+	}
+
+	authorize(w, r, principal, isQualifiedCallback, handler)
+}
 
-	username, err := verifyToken(tokenString)
-	if err != nil {
-		HttpError(w, http.StatusUnauthorized, "invalid token, you dont have permission for this route")
+func authorize(w http.ResponseWriter, r *http.Request, principal *Principal, isQualifiedCallback func(p *Principal) bool, handler func(w http.ResponseWriter, r *http.Request)) {
+	if principal.Actor != nil && !impersonationMayServe(r) {
+		HttpError(w, http.StatusForbidden, "impersonation tokens can't change anything")
 		return
 	}
 
-	if isQualifiedCallback != nil && !isQualifiedCallback(username) {
+	if isQualifiedCallback != nil && !isQualifiedCallback(principal) {
 		HttpError(w, http.StatusUnauthorized, "you dont have permission for this route")
 		return
 	}
 
 	handler(w, r)
 }
+
+const (
+	// ChallengeTwoFactor is handed out by Login when the password was right but
+	// a second factor is still needed
+	ChallengeTwoFactor = "2fa"
+	// ChallengeEnrollment is handed out instead when two-factor authentication
+	// is required for the user but they haven't set it up yet
+	ChallengeEnrollment = "2fa_enroll"
+)
+
+// ChallengeToken is a short-lived token that proves the password of a user
+// was checked, only good for finishing the login it was issued for.
+type ChallengeToken struct {
+	Token     string
+	ID        string
+	UserID    int64
+	Purpose   string
+	ExpiresAt time.Time
+}
+
+func CreateChallengeToken(userID int64, purpose string) (*ChallengeToken, error) {
+	tokenID, err := RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(GetEnvDuration("CHALLENGE_TOKEN_TTL", 5*time.Minute))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
+		jwt.MapClaims{
+			"sub":     strconv.FormatInt(userID, 10),
+			"purpose": purpose,
+			"jti":     tokenID,
+			"exp":     expiresAt.Unix(),
+		})
+
+	tokenString, err := token.SignedString(secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChallengeToken{
+		Token:     tokenString,
+		ID:        tokenID,
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// VerifyChallengeToken checks that tokenString is a live challenge token
+// issued for purpose.
+func VerifyChallengeToken(tokenString, purpose string) (*ChallengeToken, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
+		return secretKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if claims["purpose"] != purpose {
+		return nil, fmt.Errorf("not a %s challenge token", purpose)
+	}
+
+	subject, _ := claims["sub"].(string)
+	userID, err := strconv.ParseInt(subject, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("sub claim not found or not a user id")
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		return nil, fmt.Errorf("jti claim not found or not a string")
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, fmt.Errorf("exp claim not found")
+	}
+
+	if IsTokenRevoked(tokenID) {
+		return nil, fmt.Errorf("token has been revoked")
+	}
+
+	return &ChallengeToken{
+		Token:     tokenString,
+		ID:        tokenID,
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: expiresAt.Time,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLanguage is the language the top-level event fields are written in.
+func DefaultLanguage() string {
+	return GetEnv("DEFAULT_LANGUAGE", "en")
+}
+
+// SupportedLanguages are the languages events are expected to be published in,
+// read from the comma separated SUPPORTED_LANGUAGES. Invalid tags are skipped.
+func SupportedLanguages() []string {
+	languages := []string{}
+	for _, tag := range strings.Split(GetEnv("SUPPORTED_LANGUAGES", "en,ar"), ",") {
+		language, err := CanonicalLanguage(strings.TrimSpace(tag))
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(languages, language) {
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
+
+// CanonicalLanguage validates a BCP 47 tag and returns its canonical form, so
+// "EN-us" and "en-US" end up stored the same way.
+func CanonicalLanguage(tag string) (string, error) {
+	if tag == "" {
+		return "", fmt.Errorf("language is required")
+	}
+	parsed, err := language.Parse(tag)
+	if err != nil {
+		return "", fmt.Errorf("%q is not a valid BCP 47 language tag", tag)
+	}
+	return parsed.String(), nil
+}
+
+// PreferredLanguage looks up the language a user picked on their profile, ""
+// when they haven't picked one. main points it at the user store.
+var PreferredLanguage = func(userID int64) string {
+	return ""
+}
+
+// LanguagePreferences is what the request asks content to be shown in, as an
+// Accept-Language style list. ?lang= wins, otherwise the preferred language of
+// the logged in user comes first, then the Accept-Language header.
+func LanguagePreferences(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return lang
+	}
+
+	header := r.Header.Get("Accept-Language")
+	p := GetPrincipal(r)
+	if p == nil {
+		return header
+	}
+
+	preferred := PreferredLanguage(p.ID)
+	if preferred == "" {
+		return header
+	}
+	if header == "" {
+		return preferred
+	}
+	return preferred + ", " + header
+}
+
+// NegotiateLanguage picks the best of the available languages for preferences,
+// see LanguagePreferences. fallback is returned when nothing matches, and has
+// to be one of available.
+func NegotiateLanguage(preferences string, available []string, fallback string) string {
+	if preferences == "" || len(available) < 2 {
+		return fallback
+	}
+
+	wanted, _, err := language.ParseAcceptLanguage(preferences)
+	if err != nil || len(wanted) == 0 {
+		return fallback
+	}
+
+	// The first supported tag is what the matcher falls back to
+	supported := []language.Tag{language.Make(fallback)}
+	names := []string{fallback}
+	for _, lang := range available {
+		if lang == fallback {
+			continue
+		}
+		supported = append(supported, language.Make(lang))
+		names = append(names, lang)
+	}
+
+	_, index, confidence := language.NewMatcher(supported).Match(wanted...)
+	if confidence == language.No {
+		return fallback
+	}
+	return names[index]
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// OrganizationHeader picks the organization a request acts in by id or slug,
+// instead of the one its access token was issued for.
+const OrganizationHeader = "X-Organization"
+
+// Membership is the role a user has in an organization, Role being empty when
+// they aren't a member.
+type Membership struct {
+	OrganizationID int64
+	Role           string
+}
+
+// FindMembership looks up the membership of a user in the organization with
+// the given id or slug, nil meaning there is no such organization. main
+// points it at the organization store, until then there are none.
+var FindMembership = func(userID int64, org string) (*Membership, error) {
+	return nil, nil
+}
+
+// selectOrganization sets the organization p acts in, answering with the
+// status to reject the request with when that fails. Users can only act in
+// organizations they are a member of, unless they may manage all of them. An
+// organization from the token that is gone or left is dropped silently, the
+// header is the user's explicit choice so there they learn why it failed.
+func selectOrganization(r *http.Request, p *Principal) (int, error) {
+	org := r.Header.Get(OrganizationHeader)
+	fromHeader := org != ""
+	if !fromHeader {
+		if p.OrgID == 0 {
+			return 0, nil
+		}
+		org = strconv.FormatInt(p.OrgID, 10)
+	}
+	p.OrgID = 0
+
+	membership, err := FindMembership(p.ID, org)
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("couldn't check the organization")
+	}
+	if membership == nil {
+		if fromHeader {
+			return http.StatusNotFound, errors.New("organization not found")
+		}
+		return 0, nil
+	}
+	if membership.Role == "" && !p.Can(PermOrgsManage) {
+		if fromHeader {
+			return http.StatusForbidden, errors.New("you aren't a member of this organization")
+		}
+		return 0, nil
+	}
+
+	p.OrgID = membership.OrganizationID
+	p.OrgRole = membership.Role
+	return 0, nil
+}
@@ -0,0 +1,154 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords []byte
+
+// PasswordPolicy is what a new password has to satisfy, read from the
+// PASSWORD_* variables by PasswordPolicyFromEnv.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// PasswordViolation is one rule a password broke, Rule being a stable code
+// clients can match on.
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password broke.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password does not meet the policy: " + strings.Join(messages, ", ")
+}
+
+func PasswordPolicyFromEnv() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     GetEnvInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:     GetEnvInt("PASSWORD_MAX_LENGTH", 64),
+		RequireUpper:  GetEnv("PASSWORD_REQUIRE_UPPER", "false") == "true",
+		RequireLower:  GetEnv("PASSWORD_REQUIRE_LOWER", "false") == "true",
+		RequireDigit:  GetEnv("PASSWORD_REQUIRE_DIGIT", "false") == "true",
+		RequireSymbol: GetEnv("PASSWORD_REQUIRE_SYMBOL", "false") == "true",
+	}
+}
+
+// Check returns a *PasswordPolicyError listing every broken rule, or nil.
+func (p PasswordPolicy) Check(username, password string) error {
+	var violations []PasswordViolation
+	add := func(rule, message string) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add("min_length", fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add("max_length", fmt.Sprintf("must be at most %d characters long", p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case !unicode.IsLetter(c) && !unicode.IsSpace(c):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add("uppercase", "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		add("lowercase", "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add("digit", "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add("symbol", "must contain a symbol")
+	}
+
+	if username != "" && strings.EqualFold(password, username) {
+		add("username", "must not be the same as the username")
+	}
+	if IsBreachedPassword(password) {
+		add("breached", "is too common, it appears in lists of breached passwords")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// CheckPassword checks a password against the configured policy.
+func CheckPassword(username, password string) error {
+	return PasswordPolicyFromEnv().Check(username, password)
+}
+
+var (
+	breachedOnce      sync.Once
+	breachedPasswords map[string]struct{}
+)
+
+// IsBreachedPassword looks the password up, case insensitively, in the bundled
+// list of common passwords and in BREACHED_PASSWORDS_FILE when it is set, one
+// password per line.
+func IsBreachedPassword(password string) bool {
+	breachedOnce.Do(func() {
+		breachedPasswords = map[string]struct{}{}
+		addPasswordList(commonPasswords)
+
+		if path := GetEnv("BREACHED_PASSWORDS_FILE", ""); path != "" {
+			list, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("Failed to load breached passwords from %s: %v", path, err)
+				return
+			}
+			addPasswordList(list)
+		}
+	})
+
+	_, found := breachedPasswords[strings.ToLower(password)]
+	return found
+}
+
+func addPasswordList(list []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(list))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			breachedPasswords[strings.ToLower(line)] = struct{}{}
+		}
+	}
+}
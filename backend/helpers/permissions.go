@@ -0,0 +1,143 @@
+package helpers
+
+import "net/http"
+
+// Permissions that can be granted to roles. Routes check for them with
+// Require instead of checking the role of the user.
+const (
+	PermEventsCreate     = "events:create"
+	PermEventsUpdate     = "events:update"
+	PermEventsDelete     = "events:delete"
+	PermEventsRestore    = "events:restore"
+	PermEventsHistory    = "events:history"
+	PermEventsTranslate  = "events:translate"
+	PermEventsAssign     = "events:assign"
+	PermEventsAttendees  = "events:attendees"
+	PermEventsCheckin    = "events:checkin"
+	PermUsersRead        = "users:read"
+	PermUsersRoles       = "users:roles"
+	PermUsersDelete      = "users:delete"
+	PermUsersRestore     = "users:restore"
+	PermUsersSessions    = "users:sessions"
+	PermUsersTwoFactor   = "users:two-factor"
+	PermUsersImpersonate = "users:impersonate"
+	PermAuthLockouts     = "auth:lockouts"
+	PermAuthSettings     = "auth:settings"
+	PermRolesManage      = "roles:manage"
+	PermAuditRead        = "audit:read"
+	PermMembersManage    = "members:manage"
+	PermOrgsManage       = "orgs:manage"
+)
+
+// AdminRole always has every permission, so there is no way to lock every
+// user out of the admin routes.
+const AdminRole = "admin"
+
+// DefaultRole is the role of new users.
+const DefaultRole = "user"
+
+type Permission struct {
+	Name        string
+	Description string
+	// Organization permissions can also be granted by the role a user has in
+	// their active organization, the others only by their own role
+	Organization bool
+}
+
+// Permissions lists every permission with what it allows, it's written to the
+// database on startup.
+var Permissions = []Permission{
+	{PermEventsCreate, "Create events", true},
+	{PermEventsUpdate, "Edit events, their images, media and roll them back", true},
+	{PermEventsDelete, "Delete events", true},
+	{PermEventsRestore, "List deleted events and restore them", true},
+	{PermEventsHistory, "View and compare revisions of events", true},
+	{PermEventsTranslate, "Edit translations of events and view the translation report", true},
+	{PermEventsAssign, "Assign other users to events", true},
+	{PermEventsAttendees, "View the attendees of any event", true},
+	{PermEventsCheckin, "Check in attendees at any event", true},
+	{PermUsersRead, "List users and view any user with their events", true},
+	{PermUsersRoles, "Change the role of users", false},
+	{PermUsersDelete, "Delete other users", false},
+	{PermUsersRestore, "List deleted users and restore them", false},
+	{PermUsersSessions, "View and revoke sessions and API keys of other users", false},
+	{PermUsersTwoFactor, "Reset two-factor authentication of other users", false},
+	{PermUsersImpersonate, "Act as other users to see what they see, without changing anything", false},
+	{PermAuthLockouts, "View and clear login lockouts", false},
+	{PermAuthSettings, "Choose which roles need two-factor authentication", false},
+	{PermRolesManage, "Create, edit and delete roles", false},
+	{PermAuditRead, "View the log of what was done while impersonating users", false},
+	{PermMembersManage, "Add and remove members of an organization and change their role in it", true},
+	{PermOrgsManage, "Create and delete organizations and act in any of them", false},
+}
+
+func IsPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether role was granted permission. main points it
+// at the role store, until then nothing is allowed.
+var HasPermission = func(role, permission string) bool {
+	return false
+}
+
+// IsOrganizationPermission reports whether name can be granted by a role in
+// an organization.
+func IsOrganizationPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return p.Organization
+		}
+	}
+	return false
+}
+
+// Can checks the role of the user, and for organization permissions their
+// role in the organization they act in too. API keys are held to their scopes
+// on top of that.
+func (p *Principal) Can(permission string) bool {
+	if !p.allowedByScopes(permission) {
+		return false
+	}
+	if HasPermission(p.Role, permission) {
+		return true
+	}
+	return p.OrgRole != "" && IsOrganizationPermission(permission) && HasPermission(p.OrgRole, permission)
+}
+
+// Require is the ProtectedHandler check for routes that need permission.
+func Require(permission string) func(p *Principal) bool {
+	return func(p *Principal) bool {
+		return p.Can(permission)
+	}
+}
+
+// SelfOr lets the user whose id is in the route through, and everybody else
+// with permission.
+func SelfOr(r *http.Request, permission string) func(p *Principal) bool {
+	return func(p *Principal) bool {
+		userId, err := ParseUserIdFromRoute(r)
+		if err != nil {
+			return false
+		}
+		return p.ID == userId || p.Can(permission)
+	}
+}
+
+// SessionSelfOr is SelfOr for actions that can't be undone, such as deleting
+// the account. The user has to have logged in themselves for those, API keys
+// act on their owner only within their scopes, like for everybody else.
+func SessionSelfOr(r *http.Request, permission string) func(p *Principal) bool {
+	return func(p *Principal) bool {
+		userId, err := ParseUserIdFromRoute(r)
+		if err != nil {
+			return false
+		}
+		return (p.ID == userId && SessionOnly(p)) || p.Can(permission)
+	}
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Principal is who a request was made by, taken from its access token.
+type Principal struct {
+	ID       int64
+	Username string
+	Role     string
+	// OrgID is the organization the request acts in and OrgRole the role the
+	// user has there, see selectOrganization. Both are empty outside of one.
+	OrgID     int64
+	OrgRole   string
+	TokenID   string
+	ExpiresAt time.Time
+	// APIKeyID is the API key the request was made with, 0 for access tokens.
+	// Such requests only get the permissions listed in Scopes.
+	APIKeyID int64
+	Scopes   []string
+	// Actor is the admin impersonating the user, nil unless the request was
+	// made with an impersonation token
+	Actor *Actor
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// GetPrincipal returns the principal Authenticate found for the request, nil
+// for anonymous requests.
+func GetPrincipal(r *http.Request) *Principal {
+	p, _ := r.Context().Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Authenticate verifies the bearer token or API key of a request once and puts
+// its Principal in the request context. Requests without a valid token pass on
+// without one, ProtectedHandler turns them away where a login is needed.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := principalFromHeader(r); p != nil {
+			if status, err := selectOrganization(r, p); err != nil {
+				HttpError(w, status, err.Error())
+				return
+			}
+			r = r.WithContext(WithPrincipal(r.Context(), p))
+			if p.Actor != nil {
+				auditImpersonation(w, r, p, next)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func principalFromHeader(r *http.Request) *Principal {
+	tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || tokenString == "" {
+		return nil
+	}
+	if strings.HasPrefix(tokenString, APIKeyPrefix) {
+		return principalFromAPIKey(r, tokenString)
+	}
+
+	token, err := parseToken(tokenString)
+	if err != nil {
+		return nil
+	}
+	return token.Principal()
+}
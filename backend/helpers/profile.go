@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	// Timezones are validated against the embedded database, hosts without
+	// one installed would reject every name otherwise
+	_ "time/tzdata"
+)
+
+// NormalizeProfileText trims a free-text profile field and checks it fits in
+// maxLength characters. Only multiline fields may contain line breaks, no other
+// control characters are allowed.
+func NormalizeProfileText(field, value string, maxLength int, multiline bool) (string, error) {
+	value = strings.TrimSpace(value)
+	if len([]rune(value)) > maxLength {
+		return "", fmt.Errorf("%s must be at most %d characters", field, maxLength)
+	}
+	invalid := strings.IndexFunc(value, func(r rune) bool {
+		return unicode.IsControl(r) && !(multiline && (r == '\n' || r == '\r'))
+	})
+	if invalid >= 0 {
+		return "", fmt.Errorf("%s must not contain control characters", field)
+	}
+	return value, nil
+}
+
+// NormalizeTimezone validates an IANA timezone name such as "Africa/Cairo".
+// An empty name is allowed and leaves the timezone unset.
+func NormalizeTimezone(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil
+	}
+	// "Local" would be whatever the server runs in
+	if name == "Local" {
+		return "", fmt.Errorf("'%s' is not a valid IANA timezone", name)
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid IANA timezone", name)
+	}
+	return location.String(), nil
+}
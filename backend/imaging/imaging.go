@@ -0,0 +1,166 @@
+package imaging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const SizeOriginal = "original"
+
+type Size struct {
+	Name         string
+	MaxDimension int
+}
+
+// Sizes are the resized variants generated next to the original upload.
+var Sizes = []Size{
+	{Name: "thumb", MaxDimension: 160},
+	{Name: "small", MaxDimension: 480},
+	{Name: "medium", MaxDimension: 1024},
+}
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type, use jpeg, png, gif or webp")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+var extensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+type Variant struct {
+	Size        string
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+type ProcessedImage struct {
+	Hash        string
+	ContentType string
+	Width       int
+	Height      int
+	Variants    []Variant
+}
+
+func IsValidSize(name string) bool {
+	if name == SizeOriginal {
+		return true
+	}
+	for _, size := range Sizes {
+		if size.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SniffContentType looks at the bytes themselves, never at what the client claims.
+func SniffContentType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return "", ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+// Process validates an uploaded image and produces the original plus one
+// resized variant per entry in Sizes. Images are never upscaled, and JPEG stays
+// JPEG while every other format is resized to PNG to keep transparency.
+func Process(data []byte, maxPixels int) (*ProcessedImage, error) {
+	contentType, err := SniffContentType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the header before decoding so a tiny file can't claim a huge canvas
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	sum := sha256.Sum256(data)
+	processed := &ProcessedImage{
+		Hash:        hex.EncodeToString(sum[:]),
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Variants: []Variant{{
+			Size:        SizeOriginal,
+			ContentType: contentType,
+			Extension:   extensions[contentType],
+			Width:       config.Width,
+			Height:      config.Height,
+			Data:        data,
+		}},
+	}
+
+	for _, size := range Sizes {
+		variant, err := resize(src, contentType, size)
+		if err != nil {
+			return nil, err
+		}
+		processed.Variants = append(processed.Variants, *variant)
+	}
+
+	return processed, nil
+}
+
+func resize(src image.Image, contentType string, size Size) (*Variant, error) {
+	width, height := fit(src.Bounds().Dx(), src.Bounds().Dy(), size.MaxDimension)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	variant := &Variant{Size: size.Name, Width: width, Height: height}
+
+	if contentType == "image/jpeg" {
+		variant.ContentType, variant.Extension = "image/jpeg", "jpg"
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s variant: %w", size.Name, err)
+		}
+	} else {
+		variant.ContentType, variant.Extension = "image/png", "png"
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, fmt.Errorf("failed to encode %s variant: %w", size.Name, err)
+		}
+	}
+
+	variant.Data = buf.Bytes()
+	return variant, nil
+}
+
+func fit(width, height, maxDimension int) (int, int) {
+	if width <= maxDimension && height <= maxDimension {
+		return width, height
+	}
+	if width >= height {
+		return maxDimension, max(1, height*maxDimension/width)
+	}
+	return max(1, width*maxDimension/height), maxDimension
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"immodi/submission-backend/imaging"
+	"immodi/submission-backend/repos"
+)
+
+// MigrateLegacyImages moves images still stored in the events.image column into
+// the image pipeline so they get variants and are served like new uploads.
+// Images that can't be decoded are left where they are and logged.
+func MigrateLegacyImages(eventRepo repos.EventInterface, imageRepo repos.ImageInterface, maxPixels int) {
+	ids, err := eventRepo.GetLegacyImageEventIds()
+	if err != nil {
+		log.Printf("Failed to look up legacy event images: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		event, err := eventRepo.GetEventById(id)
+		if err != nil || event == nil {
+			log.Printf("Failed to get event %d with a legacy image: %v", id, err)
+			continue
+		}
+
+		data, err := eventRepo.GetLegacyEventImage(id)
+		if err != nil {
+			log.Printf("Failed to read legacy image of event %d: %v", id, err)
+			continue
+		}
+
+		processed, err := imaging.Process(data, maxPixels)
+		if err != nil {
+			log.Printf("Skipping legacy image of event %d: %v", id, err)
+			continue
+		}
+
+		image, err := imageRepo.SaveImage(processed)
+		if err != nil {
+			log.Printf("Failed to store legacy image of event %d: %v", id, err)
+			continue
+		}
+
+		// An event edited in the meantime is picked up again on the next start
+		if _, err := eventRepo.SetEventImage(id, event.Version, &image.ID); err != nil {
+			log.Printf("Failed to attach migrated image to event %d: %v", id, err)
+			imageRepo.DeleteImage(image.ID)
+			continue
+		}
+		log.Printf("Migrated legacy image of event %d", id)
+	}
+}
+
+// StartImageCleanupJob removes stored images no event or gallery uses anymore,
+// checking every interval. Images younger than an hour are skipped so uploads
+// still being attached are not touched. Calling the returned function stops it.
+func StartImageCleanupJob(imageRepo repos.ImageInterface, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		cleanupImages(imageRepo)
+		for {
+			select {
+			case <-ticker.C:
+				cleanupImages(imageRepo)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func cleanupImages(imageRepo repos.ImageInterface) {
+	deleted, err := imageRepo.DeleteOrphanedImages(time.Now().Add(-time.Hour))
+	if err != nil {
+		log.Printf("Failed to delete orphaned images: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d orphaned images", deleted)
+	}
+}
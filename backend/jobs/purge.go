@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"immodi/submission-backend/repos"
+)
+
+// StartPurgeJob hard-deletes soft-deleted events and users once they have been
+// in the trash for longer than retention, checking every interval. Calling the
+// returned function stops the job.
+func StartPurgeJob(eventRepo repos.EventInterface, userRepo repos.UserInterface, retention, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		purgeTrash(eventRepo, userRepo, retention)
+		for {
+			select {
+			case <-ticker.C:
+				purgeTrash(eventRepo, userRepo, retention)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func purgeTrash(eventRepo repos.EventInterface, userRepo repos.UserInterface, retention time.Duration) {
+	cutoff := time.Now().Add(-retention)
+
+	events, err := eventRepo.PurgeDeletedEvents(cutoff)
+	if err != nil {
+		log.Printf("Failed to purge deleted events: %v", err)
+	} else if events > 0 {
+		log.Printf("Purged %d deleted events", events)
+	}
+
+	users, err := userRepo.PurgeDeletedUsers(cutoff)
+	if err != nil {
+		log.Printf("Failed to purge deleted users: %v", err)
+	} else if users > 0 {
+		log.Printf("Purged %d deleted users", users)
+	}
+}
@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"immodi/submission-backend/repos"
+)
+
+// StartTokenCleanupJob drops expired refresh tokens and access token
+// revocations every interval, along with failed logins older than
+// failureWindow. Calling the returned function stops it.
+func StartTokenCleanupJob(authRepo repos.AuthInterface, interval, failureWindow time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		cleanupTokens(authRepo, failureWindow)
+		for {
+			select {
+			case <-ticker.C:
+				cleanupTokens(authRepo, failureWindow)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func cleanupTokens(authRepo repos.AuthInterface, failureWindow time.Duration) {
+	deleted, err := authRepo.DeleteExpiredTokens()
+	if err != nil {
+		log.Printf("Failed to delete expired tokens: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d expired tokens", deleted)
+	}
+
+	failures, err := authRepo.DeleteStaleLoginFailures(time.Now().Add(-failureWindow))
+	if err != nil {
+		log.Printf("Failed to delete stale login failures: %v", err)
+	} else if failures > 0 {
+		log.Printf("Deleted %d stale login failures", failures)
+	}
+}
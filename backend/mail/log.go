@@ -0,0 +1,12 @@
+package mail
+
+import "log"
+
+// LogSender writes emails to the server log instead of sending them, which is
+// enough for development and for deployments without a mail server.
+type LogSender struct{}
+
+func (LogSender) Send(msg Message) error {
+	log.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
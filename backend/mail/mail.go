@@ -0,0 +1,14 @@
+package mail
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers emails, the API only talks to this so the transport can be
+// swapped without touching the handlers.
+type Sender interface {
+	Send(msg Message) error
+}
@@ -4,10 +4,16 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"immodi/submission-backend/db"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/jobs"
+	"immodi/submission-backend/mail"
 	"immodi/submission-backend/repos"
 	"immodi/submission-backend/routes"
+	"immodi/submission-backend/sso"
+	"immodi/submission-backend/storage"
 	helper_structs "immodi/submission-backend/structs"
 
 	"github.com/go-chi/chi/v5"
@@ -23,7 +29,7 @@ func main() {
 	}
 
 	// Connect to database
-	db, err := db.NewDatabase("file:db/api.db?cache=shared&mode=rwc")
+	db, err := db.NewDatabase("file:db/api.db?cache=shared&mode=rwc&_pragma=foreign_keys(1)")
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -39,21 +45,139 @@ func main() {
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "ngrok-skip-browser-warning"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", "X-Challenge-Token", "X-Organization", "ngrok-skip-browser-warning"},
+		ExposedHeaders:   []string{"Link", "ETag", "X-Impersonated-By"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
+	// Image bytes live outside the database, by default next to it on disk
+	blobStore, err := storage.NewFileSystemStore(helpers.GetEnv("BLOB_STORAGE_DIR", "data/blobs"))
+	if err != nil {
+		log.Fatalf("Failed to open blob storage: %v", err)
+	}
+
+	// Without an SMTP server mails only end up in the log
+	var mailer mail.Sender = mail.LogSender{}
+	if addr := helpers.GetEnv("SMTP_ADDR", ""); addr != "" {
+		mailer, err = mail.NewSMTPSender(addr, helpers.GetEnv("SMTP_FROM", "no-reply@localhost"), helpers.GetEnv("SMTP_USERNAME", ""), helpers.GetEnv("SMTP_PASSWORD", ""))
+		if err != nil {
+			log.Fatalf("Failed to set up mail: %v", err)
+		}
+	}
+
 	api := &helper_structs.API{
 		EventRepo: repos.NewEventRepository(db.DB),
 		UserRepo:  repos.NewUserRepository(db.DB),
 		AuthRepo:  repos.NewAuthRepository(db.DB),
+		RoleRepo:  repos.NewRoleRepository(db.DB),
+		OrgRepo:   repos.NewOrganizationRepository(db.DB),
+		ImageRepo: repos.NewImageRepository(db.DB, blobStore),
+		Mailer:    mailer,
+	}
+
+	// Single sign-on is offered next to passwords once an identity provider is set up
+	if config, ok := sso.ConfigFromEnv(); ok {
+		api.SSO = sso.NewProvider(config)
+	}
+
+	jobs.MigrateLegacyImages(api.EventRepo, api.ImageRepo, helpers.GetEnvInt("MAX_IMAGE_PIXELS", 40_000_000))
+	stopImageCleanup := jobs.StartImageCleanupJob(api.ImageRepo, helpers.GetEnvDuration("IMAGE_CLEANUP_INTERVAL", time.Hour))
+	defer stopImageCleanup()
+
+	// Revoked access tokens are rejected until they expire, failing closed when
+	// the denylist can't be read
+	helpers.IsTokenRevoked = func(tokenID string) bool {
+		revoked, err := api.AuthRepo.IsTokenRevoked(tokenID)
+		if err != nil {
+			log.Printf("Failed to check token revocation: %v", err)
+			return true
+		}
+		return revoked
+	}
+	// Permissions are looked up on every check, so changes to a role apply to
+	// its users right away
+	helpers.HasPermission = func(role, permission string) bool {
+		granted, err := api.RoleRepo.RoleHasPermission(role, permission)
+		if err != nil {
+			log.Printf("Failed to check permission: %v", err)
+			return false
+		}
+		return granted
+	}
+	// Memberships are looked up on every request too, so removed members lose
+	// access to the organization right away
+	helpers.FindMembership = func(userID int64, org string) (*helpers.Membership, error) {
+		membership, err := api.OrgRepo.GetMembership(userID, org)
+		if err != nil || membership == nil {
+			return nil, err
+		}
+		return &helpers.Membership{OrganizationID: membership.OrganizationID, Role: membership.Role}, nil
+	}
+	// API keys are looked up on every request, so revoking one takes effect
+	// right away
+	helpers.FindAPIKey = func(keyHash, ip string) (*helpers.APIKeyOwner, error) {
+		owner, err := api.AuthRepo.GetAPIKeyOwner(keyHash)
+		if err != nil || owner == nil {
+			return nil, err
+		}
+		if err := api.AuthRepo.TouchAPIKey(owner.Key.ID, ip); err != nil {
+			log.Printf("Failed to record API key use: %v", err)
+		}
+
+		var orgID int64
+		if owner.Key.OrganizationID != nil {
+			orgID = *owner.Key.OrganizationID
+		}
+		return &helpers.APIKeyOwner{
+			KeyID:    owner.Key.ID,
+			UserID:   owner.Key.UserID,
+			Username: owner.Username,
+			Role:     owner.Role,
+			OrgID:    orgID,
+			Scopes:   owner.Key.Scopes,
+		}, nil
+	}
+	// Everything done while impersonating a user ends up in the audit log
+	helpers.LogImpersonation = func(entry helpers.ImpersonationEntry) {
+		err := api.AuthRepo.RecordImpersonation(repos.ImpersonationEntry{
+			ActorID: entry.ActorID,
+			UserID:  entry.UserID,
+			TokenID: entry.TokenID,
+			Method:  entry.Method,
+			Path:    entry.Path,
+			Status:  entry.Status,
+			IP:      entry.IP,
+		})
+		if err != nil {
+			log.Printf("Failed to record impersonation: %v", err)
+		}
+	}
+	// Translated event content defaults to the language picked on the profile
+	helpers.PreferredLanguage = func(userID int64) string {
+		user, err := api.UserRepo.GetUserById(userID)
+		if err != nil {
+			log.Printf("Failed to get preferred language: %v", err)
+			return ""
+		}
+		if user == nil {
+			return ""
+		}
+		return user.PreferredLanguage
+	}
+	stopTokenCleanup := jobs.StartTokenCleanupJob(api.AuthRepo, helpers.GetEnvDuration("TOKEN_CLEANUP_INTERVAL", time.Hour), helpers.GetEnvDuration("LOGIN_FAILURE_WINDOW", time.Hour))
+	defer stopTokenCleanup()
+
+	// Hard-delete whatever has been sitting in the trash longer than the retention period
+	if retention := helpers.GetEnvDuration("TRASH_RETENTION", 30*24*time.Hour); retention > 0 {
+		stopPurge := jobs.StartPurgeJob(api.EventRepo, api.UserRepo, retention, helpers.GetEnvDuration("TRASH_PURGE_INTERVAL", time.Hour))
+		defer stopPurge()
 	}
 
 	// Middlewares
 	r.Use(middleware.Logger)
+	r.Use(helpers.Authenticate)
 
 	// Routes
 	r.Get("/", routes.Root)
@@ -66,6 +190,12 @@ func main() {
 	r.Route("/events", func(r chi.Router) {
 		routes.EventsRouter(r, db.DB, api)
 	})
+	r.Route("/roles", func(r chi.Router) {
+		routes.RolesRouter(r, db.DB, api)
+	})
+	r.Route("/organizations", func(r chi.Router) {
+		routes.OrganizationsRouter(r, db.DB, api)
+	})
 
 	r.NotFound(routes.NotFound)
 	r.MethodNotAllowed(routes.NotAllowed)
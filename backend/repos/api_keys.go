@@ -0,0 +1,145 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIKey is a personal key a user scripts against the API with. Only the hash
+// of the key is stored, Prefix being enough of it to recognize the key by.
+type APIKey struct {
+	ID             int64    `json:"id"`
+	UserID         int64    `json:"userId"`
+	Name           string   `json:"name"`
+	Prefix         string   `json:"prefix"`
+	Scopes         []string `json:"scopes"`
+	OrganizationID *int64   `json:"organizationId,omitempty"`
+	CreatedAt      string   `json:"createdAt"`
+	ExpiresAt      *string  `json:"expiresAt,omitempty"`
+	LastUsedAt     *string  `json:"lastUsedAt,omitempty"`
+	LastUsedIP     *string  `json:"lastUsedIp,omitempty"`
+}
+
+// APIKeyOwner is a live API key together with the user it belongs to.
+type APIKeyOwner struct {
+	Key      APIKey
+	Username string
+	Role     string
+}
+
+// apiKeyUseInterval is how often the last use of a key is written, so busy
+// scripts don't turn every request into a write.
+const apiKeyUseInterval = time.Minute
+
+const apiKeyColumns = "k.id, k.user_id, k.name, k.prefix, k.scopes, k.organization_id, k.created_at, k.expires_at, k.last_used_at, k.last_used_ip"
+
+const liveAPIKey = "k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > CURRENT_TIMESTAMP)"
+
+func scanAPIKey(row rowScanner, extra ...any) (*APIKey, error) {
+	var k APIKey
+	var scopes string
+	dest := append([]any{&k.ID, &k.UserID, &k.Name, &k.Prefix, &scopes, &k.OrganizationID, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.LastUsedIP}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	k.Scopes = strings.Fields(scopes)
+	return &k, nil
+}
+
+// CreateAPIKey stores a new key of the user by its hash.
+func (r *AuthRepository) CreateAPIKey(k APIKey, keyHash string, expiresAt *time.Time) (int64, error) {
+	var expires *string
+	if expiresAt != nil {
+		formatted := expiresAt.UTC().Format(time.DateTime)
+		expires = &formatted
+	}
+
+	result, err := r.db.Exec(
+		`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, organization_id, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		k.UserID, k.Name, k.Prefix, keyHash, strings.Join(k.Scopes, " "), k.OrganizationID, expires,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create API key for user id %d: %w", k.UserID, err)
+	}
+	return result.LastInsertId()
+}
+
+// GetAPIKeys lists the keys of a user that haven't been revoked, expired ones
+// included so the user can tell why a script stopped working.
+func (r *AuthRepository) GetAPIKeys(userID int64) ([]APIKey, error) {
+	rows, err := r.db.Query(
+		"SELECT "+apiKeyColumns+" FROM api_keys k WHERE k.user_id = ? AND k.revoked_at IS NULL ORDER BY k.created_at DESC, k.id DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch API keys of user id %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning API key row: %w", err)
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes one key of the user, ErrNotFound when the user has no
+// such key.
+func (r *AuthRepository) RevokeAPIKey(userID, keyID int64) error {
+	result, err := r.db.Exec(
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		keyID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key id %d of user id %d: %w", keyID, userID, err)
+	}
+	return requireAffected(result, fmt.Errorf("no API key %d found for user id %d: %w", keyID, userID, ErrNotFound))
+}
+
+// RevokeUserAPIKeys revokes every key of the user.
+func (r *AuthRepository) RevokeUserAPIKeys(userID int64) error {
+	_, err := r.db.Exec("UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL", userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API keys of user id %d: %w", userID, err)
+	}
+	return nil
+}
+
+// GetAPIKeyOwner finds the live key with the given hash, nil when it doesn't
+// exist, was revoked, expired or its user was deleted.
+func (r *AuthRepository) GetAPIKeyOwner(keyHash string) (*APIKeyOwner, error) {
+	var owner APIKeyOwner
+	k, err := scanAPIKey(r.db.QueryRow(
+		"SELECT "+apiKeyColumns+", u.username, u.role FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.key_hash = ? AND "+liveAPIKey+" AND u.deleted_at IS NULL",
+		keyHash,
+	), &owner.Username, &owner.Role)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	owner.Key = *k
+	return &owner, nil
+}
+
+// TouchAPIKey records that the key was just used from ip, at most once every
+// apiKeyUseInterval.
+func (r *AuthRepository) TouchAPIKey(keyID int64, ip string) error {
+	_, err := r.db.Exec(
+		"UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP, last_used_ip = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)",
+		ip, keyID, time.Now().Add(-apiKeyUseInterval).UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record use of API key id %d: %w", keyID, err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package repos
 import (
 	"database/sql"
 	"fmt"
+	"time"
 )
 
 type AuthUser struct {
@@ -19,6 +20,54 @@ type AuthRepository struct {
 
 type AuthInterface interface {
 	GetAuthUserByUsername(username string) (*AuthUser, error)
+	GetAuthUserById(id int64) (*AuthUser, error)
+	CreateSession(s Session, t RefreshToken) (int64, error)
+	GetUserSessions(userID int64, currentTokenID string) ([]Session, error)
+	RevokeSession(userID, sessionID int64) error
+	RevokeUserSessions(userID int64) error
+	GetRefreshToken(tokenHash string) (*RefreshToken, error)
+	RotateRefreshToken(tokenHash string, next RefreshToken, ip string) error
+	RevokeTokenFamily(familyID string) error
+	Logout(tokenID string, expiresAt time.Time) error
+	RevokeToken(tokenID string, expiresAt time.Time) error
+	IsTokenRevoked(tokenID string) (bool, error)
+	DeleteExpiredTokens() (int64, error)
+	ChangePassword(userID int64, password string) error
+	CreatePasswordResetToken(userID int64, tokenHash string, expiresAt time.Time) error
+	ResetPassword(tokenHash, password string) error
+	RehashPassword(userID int64, oldHash, newHash string) error
+	GetLoginLockout(kind, value string) (*time.Time, error)
+	RecordLoginFailure(kind, value string, policy LockoutPolicy) (*time.Time, error)
+	ClearLoginFailures(kind, value string) (bool, error)
+	GetLoginFailures(failedSince time.Time) ([]LoginFailures, error)
+	DeleteStaleLoginFailures(failedBefore time.Time) (int64, error)
+	GetTwoFactor(userID int64) (*TwoFactor, error)
+	SetPendingTOTPSecret(userID int64, secret string) error
+	EnableTwoFactor(userID int64, step int64, recoveryCodeHashes []string) error
+	DisableTwoFactor(userID int64) error
+	UseTOTPStep(userID int64, step int64) (bool, error)
+	UseRecoveryCode(userID int64, codeHash string) (bool, error)
+	ReplaceRecoveryCodes(userID int64, codeHashes []string) error
+	GetTwoFactorRequiredRoles() ([]string, error)
+	SetTwoFactorRequiredRoles(roles []string) error
+	IsTwoFactorRequired(role string) (bool, error)
+	CreateOIDCLogin(login OIDCLogin) error
+	ConsumeOIDCLogin(stateHash string) (*OIDCLogin, error)
+	GetAuthUserByIdentity(issuer, subject string) (*AuthUser, error)
+	LinkIdentity(userID int64, issuer, subject string) error
+	ProvisionUser(username, role, issuer, subject string) (*AuthUser, error)
+	GetDefaultOrganization(userID int64) (int64, error)
+	CreateAPIKey(k APIKey, keyHash string, expiresAt *time.Time) (int64, error)
+	GetAPIKeys(userID int64) ([]APIKey, error)
+	RevokeAPIKey(userID, keyID int64) error
+	RevokeUserAPIKeys(userID int64) error
+	GetAPIKeyOwner(keyHash string) (*APIKeyOwner, error)
+	TouchAPIKey(keyID int64, ip string) error
+	SetEmail(userID int64, email string) error
+	CreateEmailVerificationToken(userID int64, email, tokenHash string, expiresAt time.Time) error
+	VerifyEmail(tokenHash string) error
+	RecordImpersonation(e ImpersonationEntry) error
+	GetImpersonationLog(actorID, userID int64, limit int) ([]ImpersonationEntry, error)
 }
 
 func NewAuthRepository(db *sql.DB) *AuthRepository {
@@ -28,7 +77,7 @@ func NewAuthRepository(db *sql.DB) *AuthRepository {
 func (r *AuthRepository) GetAuthUserByUsername(username string) (*AuthUser, error) {
 	var u AuthUser
 	err := r.db.QueryRow(
-		"SELECT id, username, role, created_at, password_hash FROM users WHERE username = ?",
+		"SELECT id, username, role, created_at, password_hash FROM users WHERE username = ? AND deleted_at IS NULL",
 		username,
 	).Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.PasswordHash)
 
@@ -0,0 +1,112 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SetEmail changes the email address of the user, which has to be normalized
+// already. A new address starts out unverified, setting the same one again
+// keeps it verified. ErrAlreadyExists is returned when another user has it.
+func (r *AuthRepository) SetEmail(userID int64, email string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start changing email of user id %d: %w", userID, err)
+	}
+	defer tx.Rollback()
+
+	var taken bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE email = ? AND id != ?)", email, userID).Scan(&taken); err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if taken {
+		return fmt.Errorf("email '%s' is already in use: %w", email, ErrAlreadyExists)
+	}
+
+	result, err := tx.Exec(
+		`UPDATE users SET email = ?, email_verified_at = CASE WHEN email = ? THEN email_verified_at ELSE NULL END
+		 WHERE id = ? AND deleted_at IS NULL`,
+		email, email, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to change email of user id %d: %w", userID, err)
+	}
+	if err := requireAffected(result, fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit email of user id %d: %w", userID, err)
+	}
+	return nil
+}
+
+// CreateEmailVerificationToken stores a token proving the user can read mail
+// sent to email, replacing any earlier one that wasn't used yet.
+func (r *AuthRepository) CreateEmailVerificationToken(userID int64, email, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start creating email verification token: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM email_verification_tokens WHERE user_id = ? AND used_at IS NULL", userID); err != nil {
+		return fmt.Errorf("failed to delete earlier email verification tokens: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO email_verification_tokens (user_id, email, token_hash, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		userID, email, tokenHash, expiresAt.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create email verification token for user id %d: %w", userID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit email verification token: %w", err)
+	}
+	return nil
+}
+
+// VerifyEmail uses up a verification token, marking the address it was sent
+// to as verified. Tokens only work while the
+// user still has that address, ErrNotFound is returned for unknown, used or
+// expired tokens and for addresses that were changed since.
+func (r *AuthRepository) VerifyEmail(tokenHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start verifying email: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	var email string
+	err = tx.QueryRow(
+		`UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP
+		 WHERE token_hash = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		 AND EXISTS (SELECT 1 FROM users u WHERE u.id = email_verification_tokens.user_id AND u.email = email_verification_tokens.email AND u.deleted_at IS NULL)
+		 RETURNING user_id, email`,
+		tokenHash,
+	).Scan(&userID, &email)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("email verification token is invalid or expired: %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to use email verification token: %w", err)
+	}
+
+	_, err = tx.Exec(
+		"UPDATE users SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP) WHERE id = ? AND email = ?",
+		userID, email,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to verify email of user id %d: %w", userID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit email verification: %w", err)
+	}
+	return nil
+}
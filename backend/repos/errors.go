@@ -0,0 +1,12 @@
+package repos
+
+import "errors"
+
+var (
+	ErrNotFound        = errors.New("record not found")
+	ErrVersionConflict = errors.New("record was modified by someone else")
+	ErrInvalidInput    = errors.New("invalid input")
+	ErrTokenReused     = errors.New("token was already used")
+	ErrAlreadyExists   = errors.New("record already exists")
+	ErrInUse           = errors.New("record is still in use")
+)
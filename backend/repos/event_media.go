@@ -0,0 +1,328 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EventMedia is one image of an event gallery. Cover is set when the event's
+// own image is this gallery image.
+type EventMedia struct {
+	ID           int64                   `json:"id"`
+	EventID      int64                   `json:"eventId"`
+	Position     int                     `json:"position"`
+	Caption      string                  `json:"caption"`
+	AltText      string                  `json:"altText"`
+	Cover        bool                    `json:"cover"`
+	ImageURL     string                  `json:"imageUrl"`
+	Width        int                     `json:"width"`
+	Height       int                     `json:"height"`
+	ImageID      int64                   `json:"-"`
+	ImageHash    string                  `json:"-"`
+	Translations []EventMediaTranslation `json:"translations"`
+}
+
+type EventMediaTranslation struct {
+	Language string `json:"language"`
+	Caption  string `json:"caption"`
+	AltText  string `json:"altText"`
+}
+
+// AddEventMedia appends an already stored image to the end of the event gallery.
+func (r *EventRepository) AddEventMedia(eventID, imageID int64, caption, altText string, translations []EventMediaTranslation) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start adding media to event id %d: %w", eventID, err)
+	}
+	defer tx.Rollback()
+
+	if err := r.requireLiveEvent(tx, eventID); err != nil {
+		return 0, err
+	}
+
+	var mediaID int64
+	err = tx.QueryRow(
+		`INSERT INTO event_media (event_id, image_id, position, caption, alt_text)
+		 SELECT ?, ?, COALESCE(MAX(position), 0) + 1, ?, ? FROM event_media WHERE event_id = ?
+		 RETURNING id`,
+		eventID, imageID, caption, altText, eventID,
+	).Scan(&mediaID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add media to event id %d: %w", eventID, err)
+	}
+
+	if err := insertEventMediaTranslations(tx, mediaID, translations); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit media of event id %d: %w", eventID, err)
+	}
+	return mediaID, nil
+}
+
+// GetEventMedia lists the gallery of an event in display order.
+func (r *EventRepository) GetEventMedia(eventID int64) ([]EventMedia, error) {
+	rows, err := r.db.Query(
+		`SELECT m.id, m.event_id, m.position, m.caption, m.alt_text, m.image_id, i.hash, i.width, i.height,
+		        COALESCE(e.image_id = m.image_id, 0)
+		 FROM event_media m
+		 JOIN events e ON e.id = m.event_id
+		 JOIN images i ON i.id = m.image_id
+		 WHERE m.event_id = ? AND e.deleted_at IS NULL`+orgFilter(r.org, "e.organization_id")+`
+		 ORDER BY m.position`,
+		eventID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch media of event id %d: %w", eventID, err)
+	}
+	defer rows.Close()
+
+	media := []EventMedia{}
+	for rows.Next() {
+		var m EventMedia
+		if err := rows.Scan(&m.ID, &m.EventID, &m.Position, &m.Caption, &m.AltText, &m.ImageID, &m.ImageHash, &m.Width, &m.Height, &m.Cover); err != nil {
+			return nil, fmt.Errorf("error scanning event media row: %w", err)
+		}
+		m.ImageURL = fmt.Sprintf("/events/%d/media/%d/image?v=%s", m.EventID, m.ID, ImageVersion(m.ImageHash))
+		m.Translations = []EventMediaTranslation{}
+		media = append(media, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch media of event id %d: %w", eventID, err)
+	}
+
+	byID := map[int64]*EventMedia{}
+	for i := range media {
+		byID[media[i].ID] = &media[i]
+	}
+
+	translationRows, err := r.db.Query(
+		`SELECT t.media_id, t.language, t.caption, t.alt_text
+		 FROM event_media_translations t
+		 JOIN event_media m ON m.id = t.media_id
+		 WHERE m.event_id = ?`+orgEventFilter(r.org, "m.event_id")+`
+		 ORDER BY t.language`,
+		eventID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch media translations of event id %d: %w", eventID, err)
+	}
+	defer translationRows.Close()
+
+	for translationRows.Next() {
+		var mediaID int64
+		var t EventMediaTranslation
+		if err := translationRows.Scan(&mediaID, &t.Language, &t.Caption, &t.AltText); err != nil {
+			return nil, fmt.Errorf("error scanning event media translation: %w", err)
+		}
+		if m, ok := byID[mediaID]; ok {
+			m.Translations = append(m.Translations, t)
+		}
+	}
+	return media, translationRows.Err()
+}
+
+// GetEventMediaItem returns nil when the event has no such media.
+func (r *EventRepository) GetEventMediaItem(eventID, mediaID int64) (*EventMedia, error) {
+	media, err := r.GetEventMedia(eventID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range media {
+		if media[i].ID == mediaID {
+			return &media[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// UpdateEventMedia replaces the caption, alt text and translations of a gallery image.
+func (r *EventRepository) UpdateEventMedia(eventID, mediaID int64, caption, altText string, translations []EventMediaTranslation) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start updating media id %d: %w", mediaID, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE event_media SET caption = ?, alt_text = ?
+		 WHERE id = ? AND event_id IN (SELECT id FROM events WHERE id = ? AND deleted_at IS NULL`+orgFilter(r.org, "organization_id")+`)`,
+		caption, altText, mediaID, eventID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update media id %d: %w", mediaID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("couldn't verify media update result: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no media %d found on event id %d: %w", mediaID, eventID, ErrNotFound)
+	}
+
+	if _, err := tx.Exec("DELETE FROM event_media_translations WHERE media_id = ?", mediaID); err != nil {
+		return fmt.Errorf("failed to delete existing media translations: %w", err)
+	}
+	if err := insertEventMediaTranslations(tx, mediaID, translations); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit media id %d: %w", mediaID, err)
+	}
+	return nil
+}
+
+// ReorderEventMedia puts the gallery in the given order, mediaIDs has to list
+// every image of the event exactly once.
+func (r *EventRepository) ReorderEventMedia(eventID int64, mediaIDs []int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start reordering media of event id %d: %w", eventID, err)
+	}
+	defer tx.Rollback()
+
+	if err := r.requireLiveEvent(tx, eventID); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id FROM event_media WHERE event_id = ?", eventID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch media of event id %d: %w", eventID, err)
+	}
+	current := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning event media id: %w", err)
+		}
+		current[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to fetch media of event id %d: %w", eventID, err)
+	}
+
+	if len(mediaIDs) != len(current) {
+		return fmt.Errorf("%w: expected all %d media ids of the event, got %d", ErrInvalidInput, len(current), len(mediaIDs))
+	}
+	seen := map[int64]bool{}
+	for _, id := range mediaIDs {
+		if !current[id] || seen[id] {
+			return fmt.Errorf("%w: media id %d is unknown or listed twice", ErrInvalidInput, id)
+		}
+		seen[id] = true
+	}
+
+	for i, id := range mediaIDs {
+		if _, err := tx.Exec("UPDATE event_media SET position = ? WHERE id = ?", i+1, id); err != nil {
+			return fmt.Errorf("failed to move media id %d: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit media order of event id %d: %w", eventID, err)
+	}
+	return nil
+}
+
+// DeleteEventMedia removes an image from the gallery, also clearing the event
+// image when it was the cover, and returns the image id so its bytes can go.
+func (r *EventRepository) DeleteEventMedia(eventID, mediaID int64) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start deleting media id %d: %w", mediaID, err)
+	}
+	defer tx.Rollback()
+
+	imageID, err := r.eventMediaImage(tx, eventID, mediaID)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM event_media WHERE id = ?", mediaID); err != nil {
+		return 0, fmt.Errorf("failed to delete media id %d: %w", mediaID, err)
+	}
+	if _, err := tx.Exec("UPDATE events SET image_id = NULL, version = version + 1 WHERE id = ? AND image_id = ?", eventID, imageID); err != nil {
+		return 0, fmt.Errorf("failed to clear cover of event id %d: %w", eventID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit deletion of media id %d: %w", mediaID, err)
+	}
+	return imageID, nil
+}
+
+// SetEventCover makes a gallery image the event's image and returns the image
+// it replaced, like SetEventImage does.
+func (r *EventRepository) SetEventCover(eventID, mediaID int64) (*int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start setting cover of event id %d: %w", eventID, err)
+	}
+	defer tx.Rollback()
+
+	imageID, err := r.eventMediaImage(tx, eventID, mediaID)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *int64
+	if err := tx.QueryRow("SELECT image_id FROM events WHERE id = ?", eventID).Scan(&previous); err != nil {
+		return nil, fmt.Errorf("failed to get image of event id %d: %w", eventID, err)
+	}
+	if _, err := tx.Exec("UPDATE events SET image_id = ?, image = NULL, version = version + 1 WHERE id = ?", imageID, eventID); err != nil {
+		return nil, fmt.Errorf("failed to set cover of event id %d: %w", eventID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit cover of event id %d: %w", eventID, err)
+	}
+	return previous, nil
+}
+
+func (r *EventRepository) requireLiveEvent(tx *sql.Tx, eventID int64) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM events WHERE id = ? AND deleted_at IS NULL"+orgFilter(r.org, "organization_id")+")", eventID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check event id %d: %w", eventID, err)
+	}
+	if !exists {
+		return fmt.Errorf("no event found with id %d: %w", eventID, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *EventRepository) eventMediaImage(tx *sql.Tx, eventID, mediaID int64) (int64, error) {
+	var imageID int64
+	err := tx.QueryRow(
+		`SELECT m.image_id FROM event_media m
+		 JOIN events e ON e.id = m.event_id
+		 WHERE m.id = ? AND m.event_id = ? AND e.deleted_at IS NULL`+orgFilter(r.org, "e.organization_id"),
+		mediaID, eventID,
+	).Scan(&imageID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("no media %d found on event id %d: %w", mediaID, eventID, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get media id %d: %w", mediaID, err)
+	}
+	return imageID, nil
+}
+
+func insertEventMediaTranslations(tx *sql.Tx, mediaID int64, translations []EventMediaTranslation) error {
+	for _, t := range translations {
+		_, err := tx.Exec(
+			`INSERT INTO event_media_translations (media_id, language, caption, alt_text)
+			 VALUES (?, ?, ?, ?)`,
+			mediaID, t.Language, t.Caption, t.AltText,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create %s media translation: %w", t.Language, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EventOrganizer is a user who manages an event, Owner being set for the one
+// who created it.
+type EventOrganizer struct {
+	UserID   int64   `json:"userId"`
+	Username string  `json:"username"`
+	Owner    bool    `json:"owner"`
+	AddedAt  *string `json:"addedAt,omitempty"`
+}
+
+// Attendee is a user registered for an event, CheckedInAt being set once they
+// showed up.
+type Attendee struct {
+	UserID       int64   `json:"userId"`
+	Username     string  `json:"username"`
+	RegisteredAt string  `json:"registeredAt"`
+	CheckedInAt  *string `json:"checkedInAt"`
+}
+
+// IsEventOrganizer reports whether userID created the event or was added as a
+// co-organizer. Deleted events count too, so organizers can restore them.
+func (r *EventRepository) IsEventOrganizer(eventID, userID int64) (bool, error) {
+	var organizer bool
+	err := r.db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM events WHERE id = ? AND created_by = ?`+orgFilter(r.org, "organization_id")+`)
+		 OR EXISTS (SELECT 1 FROM event_organizers WHERE event_id = ? AND user_id = ?`+orgEventFilter(r.org, "event_id")+`)`,
+		eventID, userID, eventID, userID,
+	).Scan(&organizer)
+	if err != nil {
+		return false, fmt.Errorf("failed to check organizers of event id %d: %w", eventID, err)
+	}
+	return organizer, nil
+}
+
+// IsEventOwner reports whether userID created the event.
+func (r *EventRepository) IsEventOwner(eventID, userID int64) (bool, error) {
+	var owner bool
+	err := r.db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM events WHERE id = ? AND created_by = ?"+orgFilter(r.org, "organization_id")+")",
+		eventID, userID,
+	).Scan(&owner)
+	if err != nil {
+		return false, fmt.Errorf("failed to check owner of event id %d: %w", eventID, err)
+	}
+	return owner, nil
+}
+
+// GetEventOrganizers lists the owner first, followed by the co-organizers in
+// the order they were added.
+func (r *EventRepository) GetEventOrganizers(eventID int64) ([]EventOrganizer, error) {
+	organizers := []EventOrganizer{}
+
+	owner := EventOrganizer{Owner: true}
+	err := r.db.QueryRow(
+		"SELECT u.id, u.username FROM events e JOIN users u ON u.id = e.created_by WHERE e.id = ?"+orgFilter(r.org, "e.organization_id"),
+		eventID,
+	).Scan(&owner.UserID, &owner.Username)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get owner of event id %d: %w", eventID, err)
+	}
+	if err == nil {
+		organizers = append(organizers, owner)
+	}
+
+	rows, err := r.db.Query(
+		`SELECT u.id, u.username, o.added_at FROM event_organizers o JOIN users u ON u.id = o.user_id
+		 WHERE o.event_id = ?`+orgEventFilter(r.org, "o.event_id")+`
+		 ORDER BY o.added_at, u.id`,
+		eventID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organizers of event id %d: %w", eventID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o EventOrganizer
+		if err := rows.Scan(&o.UserID, &o.Username, &o.AddedAt); err != nil {
+			return nil, fmt.Errorf("error scanning event organizer row: %w", err)
+		}
+		organizers = append(organizers, o)
+	}
+	return organizers, rows.Err()
+}
+
+// AddEventOrganizer returns ErrAlreadyExists when the user already organizes
+// the event.
+func (r *EventRepository) AddEventOrganizer(eventID, userID int64) error {
+	organizer, err := r.IsEventOrganizer(eventID, userID)
+	if err != nil {
+		return err
+	}
+	if organizer {
+		return fmt.Errorf("user %d organizes event id %d: %w", userID, eventID, ErrAlreadyExists)
+	}
+
+	_, err = r.db.Exec(
+		"INSERT INTO event_organizers (event_id, user_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+		eventID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add organizer %d to event id %d: %w", userID, eventID, err)
+	}
+	return nil
+}
+
+// RemoveEventOrganizer only removes co-organizers, the owner stays.
+func (r *EventRepository) RemoveEventOrganizer(eventID, userID int64) error {
+	result, err := r.db.Exec("DELETE FROM event_organizers WHERE event_id = ? AND user_id = ?", eventID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove organizer %d from event id %d: %w", userID, eventID, err)
+	}
+	return requireAffected(result, fmt.Errorf("user %d doesn't co-organize event id %d: %w", userID, eventID, ErrNotFound))
+}
+
+func (r *EventRepository) GetOrganizedEvents(userID int64) ([]Event, error) {
+	rows, err := r.db.Query(
+		`SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, i.hash
+		 FROM events e
+		 LEFT JOIN images i ON i.id = e.image_id
+		 WHERE e.deleted_at IS NULL AND (e.created_by = ?
+		 OR e.id IN (SELECT event_id FROM event_organizers WHERE user_id = ?))`+orgFilter(r.org, "e.organization_id")+`
+		 ORDER BY e.date`,
+		userID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events organized by user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.ImageHash); err != nil {
+			return nil, fmt.Errorf("error scanning organized event row: %w", err)
+		}
+		e.setImageURL()
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r *EventRepository) GetEventAttendees(eventID int64) ([]Attendee, error) {
+	rows, err := r.db.Query(
+		`SELECT u.id, u.username, reg.registered_at, reg.checked_in_at
+		 FROM registrations reg
+		 JOIN users u ON u.id = reg.user_id
+		 WHERE reg.event_id = ? AND u.deleted_at IS NULL`+orgEventFilter(r.org, "reg.event_id")+`
+		 ORDER BY reg.registered_at, u.id`,
+		eventID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attendees of event id %d: %w", eventID, err)
+	}
+	defer rows.Close()
+
+	attendees := []Attendee{}
+	for rows.Next() {
+		var a Attendee
+		if err := rows.Scan(&a.UserID, &a.Username, &a.RegisteredAt, &a.CheckedInAt); err != nil {
+			return nil, fmt.Errorf("error scanning attendee row: %w", err)
+		}
+		attendees = append(attendees, a)
+	}
+	return attendees, rows.Err()
+}
+
+// CheckInAttendee returns ErrNotFound when the user isn't registered for the
+// event and ErrAlreadyExists when they were checked in before.
+func (r *EventRepository) CheckInAttendee(eventID, userID int64) error {
+	var checkedInAt sql.NullString
+	err := r.db.QueryRow(
+		"SELECT checked_in_at FROM registrations WHERE event_id = ? AND user_id = ?"+orgEventFilter(r.org, "event_id"),
+		eventID, userID,
+	).Scan(&checkedInAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("user %d isn't registered for event id %d: %w", userID, eventID, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get registration of user %d: %w", userID, err)
+	}
+	if checkedInAt.Valid {
+		return fmt.Errorf("user %d was checked in at %s: %w", userID, checkedInAt.String, ErrAlreadyExists)
+	}
+
+	result, err := r.db.Exec(
+		"UPDATE registrations SET checked_in_at = CURRENT_TIMESTAMP WHERE event_id = ? AND user_id = ? AND checked_in_at IS NULL",
+		eventID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to check in user %d: %w", userID, err)
+	}
+	return requireAffected(result, fmt.Errorf("user %d was just checked in: %w", userID, ErrAlreadyExists))
+}
+
+// UndoCheckIn returns ErrNotFound when the user wasn't checked in.
+func (r *EventRepository) UndoCheckIn(eventID, userID int64) error {
+	result, err := r.db.Exec(
+		"UPDATE registrations SET checked_in_at = NULL WHERE event_id = ? AND user_id = ? AND checked_in_at IS NOT NULL"+orgEventFilter(r.org, "event_id"),
+		eventID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to undo check in of user %d: %w", userID, err)
+	}
+	return requireAffected(result, fmt.Errorf("user %d isn't checked in at event id %d: %w", userID, eventID, ErrNotFound))
+}
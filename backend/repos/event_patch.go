@@ -0,0 +1,148 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EventPatch describes a partial update, fields left nil are not touched.
+type EventPatch struct {
+	Name         *string
+	Description  *string
+	Category     *string
+	Date         *string
+	Venue        *string
+	Price        *float64
+	Translations map[string]*EventTranslationPatch
+}
+
+// EventTranslationPatch patches a single language, a nil entry in
+// EventPatch.Translations deletes that language instead.
+type EventTranslationPatch struct {
+	Name        *string
+	Description *string
+	Venue       *string
+}
+
+// PatchEvent applies the patch when the stored version still equals version,
+// bumping it, with the event row and its translations changed atomically.
+func (r *EventRepository) PatchEvent(id, version int64, patch EventPatch) error {
+	assignments := []string{}
+	args := []any{}
+	set := func(column string, value any) {
+		assignments = append(assignments, column+" = ?")
+		args = append(args, value)
+	}
+
+	if patch.Name != nil {
+		set("name", *patch.Name)
+	}
+	if patch.Description != nil {
+		set("description", *patch.Description)
+	}
+	if patch.Category != nil {
+		set("category", *patch.Category)
+	}
+	if patch.Date != nil {
+		set("date", *patch.Date)
+	}
+	if patch.Venue != nil {
+		set("venue", *patch.Venue)
+	}
+	if patch.Price != nil {
+		set("price", *patch.Price)
+	}
+	if patch.Name != nil || patch.Description != nil || patch.Venue != nil {
+		assignments = append(assignments, touchTextUpdatedAt)
+		args = append(args, patch.Name, patch.Description, patch.Venue)
+	}
+	assignments = append(assignments, "version = version + 1")
+	args = append(args, id, version)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start patching event id %d: %w", id, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		fmt.Sprintf("UPDATE events SET %s WHERE id = ? AND version = ? AND deleted_at IS NULL%s", strings.Join(assignments, ", "), orgFilter(r.org, "organization_id")),
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to patch event id %d: %w", id, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("couldn't verify patch result: %w", err)
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return r.versionMismatchError(id, version)
+	}
+
+	languages := make([]string, 0, len(patch.Translations))
+	for lang := range patch.Translations {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+
+	for _, lang := range languages {
+		if err := patchEventTranslation(tx, id, lang, patch.Translations[lang]); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit patch of event id %d: %w", id, err)
+	}
+	return nil
+}
+
+func patchEventTranslation(tx *sql.Tx, eventId int64, language string, tp *EventTranslationPatch) error {
+	if tp == nil {
+		_, err := tx.Exec("DELETE FROM event_translations WHERE event_id = ? AND language = ?", eventId, language)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s translation: %w", language, err)
+		}
+		return nil
+	}
+
+	result, err := tx.Exec(
+		`UPDATE event_translations
+		 SET updated_at = CASE
+			WHEN name IS NOT COALESCE(?, name) OR description IS NOT COALESCE(?, description) OR venue IS NOT COALESCE(?, venue)
+			THEN CURRENT_TIMESTAMP ELSE updated_at END,
+		 name = COALESCE(?, name), description = COALESCE(?, description), venue = COALESCE(?, venue)
+		 WHERE event_id = ? AND language = ?`,
+		tp.Name, tp.Description, tp.Venue, tp.Name, tp.Description, tp.Venue, eventId, language,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to patch %s translation: %w", language, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("couldn't verify translation patch result: %w", err)
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	if tp.Name == nil || tp.Description == nil || tp.Venue == nil {
+		return fmt.Errorf("%w: new %s translation needs a name, description and venue", ErrInvalidInput, language)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO event_translations (event_id, language, name, description, venue, updated_at)
+		 VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		eventId, language, *tp.Name, *tp.Description, *tp.Venue,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create %s translation: %w", language, err)
+	}
+	return nil
+}
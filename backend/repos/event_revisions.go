@@ -0,0 +1,213 @@
+package repos
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const (
+	RevisionActionCreate   = "create"
+	RevisionActionUpdate   = "update"
+	RevisionActionRollback = "rollback"
+)
+
+// EventSnapshot is the state of an event at the time a revision was recorded.
+// The image itself is not copied, only its hash, so a diff can tell it changed.
+type EventSnapshot struct {
+	Name         string             `json:"name"`
+	Description  string             `json:"description"`
+	Category     string             `json:"category"`
+	Date         string             `json:"date"`
+	Venue        string             `json:"venue"`
+	Price        float64            `json:"price"`
+	ImageHash    string             `json:"imageHash,omitempty"`
+	Translations []EventTranslation `json:"translations"`
+}
+
+type EventRevision struct {
+	EventID   int64         `json:"eventId"`
+	Revision  int64         `json:"revision"`
+	Action    string        `json:"action"`
+	Author    string        `json:"author"`
+	CreatedAt string        `json:"createdAt"`
+	Snapshot  EventSnapshot `json:"snapshot"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+func NewEventSnapshot(e *Event) EventSnapshot {
+	snapshot := EventSnapshot{
+		Name:         e.Name,
+		Description:  e.Description,
+		Category:     e.Category,
+		Date:         e.Date,
+		Venue:        e.Venue,
+		Price:        e.Price,
+		Translations: e.Translations,
+	}
+	if e.ImageHash != nil {
+		snapshot.ImageHash = *e.ImageHash
+	}
+	if snapshot.Translations == nil {
+		snapshot.Translations = []EventTranslation{}
+	}
+	return snapshot
+}
+
+// RecordEventRevision snapshots the current state of the event as its next revision.
+func (r *EventRepository) RecordEventRevision(eventID int64, action, author string) (int64, error) {
+	event, err := r.GetEventById(eventID)
+	if err != nil {
+		return 0, err
+	}
+	if event == nil {
+		return 0, fmt.Errorf("no event found with id %d: %w", eventID, ErrNotFound)
+	}
+
+	snapshot, err := json.Marshal(NewEventSnapshot(event))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode event snapshot: %w", err)
+	}
+
+	var revision int64
+	err = r.db.QueryRow(
+		`INSERT INTO event_revisions (event_id, revision, action, author, snapshot)
+		 SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ? FROM event_revisions WHERE event_id = ?
+		 RETURNING revision`,
+		eventID, action, author, string(snapshot), eventID,
+	).Scan(&revision)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record revision for event %d: %w", eventID, err)
+	}
+
+	return revision, nil
+}
+
+func (r *EventRepository) GetEventRevisions(eventID int64) ([]EventRevision, error) {
+	rows, err := r.db.Query(
+		`SELECT event_id, revision, action, author, created_at, snapshot
+		 FROM event_revisions
+		 WHERE event_id = ?`+orgEventFilter(r.org, "event_id")+`
+		 ORDER BY revision ASC`,
+		eventID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revisions for event %d: %w", eventID, err)
+	}
+	defer rows.Close()
+
+	revisions := []EventRevision{}
+	for rows.Next() {
+		rev, err := scanEventRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (r *EventRepository) GetEventRevision(eventID, revision int64) (*EventRevision, error) {
+	row := r.db.QueryRow(
+		`SELECT event_id, revision, action, author, created_at, snapshot
+		 FROM event_revisions
+		 WHERE event_id = ? AND revision = ?`+orgEventFilter(r.org, "event_id"),
+		eventID, revision,
+	)
+
+	rev, err := scanEventRevision(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEventRevision(row rowScanner) (*EventRevision, error) {
+	var rev EventRevision
+	var snapshot string
+	if err := row.Scan(&rev.EventID, &rev.Revision, &rev.Action, &rev.Author, &rev.CreatedAt, &snapshot); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error scanning event revision: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(snapshot), &rev.Snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot of revision %d: %w", rev.Revision, err)
+	}
+	return &rev, nil
+}
+
+// DiffEventSnapshots lists every field that differs between two snapshots.
+// Translations are compared per language, e.g. "translations.ar.venue".
+func DiffEventSnapshots(from, to EventSnapshot) []FieldChange {
+	changes := []FieldChange{}
+	addIfChanged := func(field string, a, b any) {
+		if a != b {
+			changes = append(changes, FieldChange{Field: field, From: a, To: b})
+		}
+	}
+
+	addIfChanged("name", from.Name, to.Name)
+	addIfChanged("description", from.Description, to.Description)
+	addIfChanged("category", from.Category, to.Category)
+	addIfChanged("date", from.Date, to.Date)
+	addIfChanged("venue", from.Venue, to.Venue)
+	addIfChanged("price", from.Price, to.Price)
+	addIfChanged("imageHash", from.ImageHash, to.ImageHash)
+
+	fromTranslations := translationsByLanguage(from.Translations)
+	toTranslations := translationsByLanguage(to.Translations)
+
+	languages := []string{}
+	for lang := range fromTranslations {
+		languages = append(languages, lang)
+	}
+	for lang := range toTranslations {
+		if _, ok := fromTranslations[lang]; !ok {
+			languages = append(languages, lang)
+		}
+	}
+	sort.Strings(languages)
+
+	for _, lang := range languages {
+		a, hadBefore := fromTranslations[lang]
+		b, hasAfter := toTranslations[lang]
+		prefix := "translations." + lang
+
+		switch {
+		case !hadBefore:
+			changes = append(changes, FieldChange{Field: prefix, From: nil, To: b})
+		case !hasAfter:
+			changes = append(changes, FieldChange{Field: prefix, From: a, To: nil})
+		default:
+			addIfChanged(prefix+".name", a.Name, b.Name)
+			addIfChanged(prefix+".description", a.Description, b.Description)
+			addIfChanged(prefix+".venue", a.Venue, b.Venue)
+		}
+	}
+
+	return changes
+}
+
+func translationsByLanguage(translations []EventTranslation) map[string]EventTranslation {
+	byLanguage := make(map[string]EventTranslation, len(translations))
+	for _, t := range translations {
+		byLanguage[t.Language] = t
+	}
+	return byLanguage
+}
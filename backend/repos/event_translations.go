@@ -0,0 +1,307 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// touchTextUpdatedAt is an events SET clause that moves text_updated_at when
+// the name, description or venue, in that order as arguments, actually change.
+// A NULL argument leaves that field out of the comparison.
+const touchTextUpdatedAt = `text_updated_at = CASE
+	WHEN name IS NOT COALESCE(?, name) OR description IS NOT COALESCE(?, description) OR venue IS NOT COALESCE(?, venue)
+	THEN CURRENT_TIMESTAMP ELSE text_updated_at END`
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// upsertEventTranslation writes one translation, only moving its updated_at
+// when the text is different from what is stored.
+func upsertEventTranslation(db execer, eventID int64, t EventTranslation) error {
+	_, err := db.Exec(
+		`INSERT INTO event_translations (event_id, language, name, description, venue, updated_at)
+		 VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT (event_id, language) DO UPDATE SET
+		 updated_at = CASE
+			WHEN name IS NOT excluded.name OR description IS NOT excluded.description OR venue IS NOT excluded.venue
+			THEN CURRENT_TIMESTAMP ELSE updated_at END,
+		 name = excluded.name, description = excluded.description, venue = excluded.venue`,
+		eventID, t.Language, t.Name, t.Description, t.Venue,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save %s translation of event id %d: %w", t.Language, eventID, err)
+	}
+	return nil
+}
+
+// GetEventTranslation returns nil when the event has no translation for language.
+func (r *EventRepository) GetEventTranslation(eventID int64, language string) (*EventTranslation, error) {
+	var t EventTranslation
+	err := r.db.QueryRow(
+		`SELECT t.language, t.name, t.description, t.venue
+		 FROM event_translations t
+		 JOIN events e ON e.id = t.event_id
+		 WHERE t.event_id = ? AND t.language = ? AND e.deleted_at IS NULL`+orgFilter(r.org, "e.organization_id"),
+		eventID, language,
+	).Scan(&t.Language, &t.Name, &t.Description, &t.Venue)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s translation of event id %d: %w", language, eventID, err)
+	}
+	return &t, nil
+}
+
+// SaveEventTranslation creates or replaces one translation and bumps the event
+// version, reporting whether the translation is new.
+func (r *EventRepository) SaveEventTranslation(eventID int64, t EventTranslation) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to start saving %s translation: %w", t.Language, err)
+	}
+	defer tx.Rollback()
+
+	if err := r.requireLiveEvent(tx, eventID); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM event_translations WHERE event_id = ? AND language = ?)",
+		eventID, t.Language,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s translation of event id %d: %w", t.Language, eventID, err)
+	}
+
+	if err := upsertEventTranslation(tx, eventID, t); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("UPDATE events SET version = version + 1 WHERE id = ?", eventID); err != nil {
+		return false, fmt.Errorf("failed to bump version of event id %d: %w", eventID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit %s translation of event id %d: %w", t.Language, eventID, err)
+	}
+	return !exists, nil
+}
+
+func (r *EventRepository) DeleteEventTranslation(eventID int64, language string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start deleting %s translation: %w", language, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`DELETE FROM event_translations
+		 WHERE event_id = ? AND language = ?
+		 AND event_id IN (SELECT id FROM events WHERE deleted_at IS NULL`+orgFilter(r.org, "organization_id")+`)`,
+		eventID, language,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s translation of event id %d: %w", language, eventID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("couldn't verify translation deletion result: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no %s translation found for event id %d: %w", language, eventID, ErrNotFound)
+	}
+
+	if _, err := tx.Exec("UPDATE events SET version = version + 1 WHERE id = ?", eventID); err != nil {
+		return fmt.Errorf("failed to bump version of event id %d: %w", eventID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit deletion of %s translation: %w", language, err)
+	}
+	return nil
+}
+
+// GetTranslationsForEvents loads the translations of several events at once,
+// keyed by event id, for listings that don't carry them.
+func (r *EventRepository) GetTranslationsForEvents(eventIDs []int64) (map[int64][]EventTranslation, error) {
+	translations := map[int64][]EventTranslation{}
+	if len(eventIDs) == 0 {
+		return translations, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(eventIDs)), ", ")
+	args := make([]any, len(eventIDs))
+	for i, id := range eventIDs {
+		args[i] = id
+	}
+
+	rows, err := r.db.Query(
+		fmt.Sprintf(
+			`SELECT event_id, language, name, description, venue
+			 FROM event_translations
+			 WHERE event_id IN (%s)%s
+			 ORDER BY event_id, language`,
+			placeholders, orgEventFilter(r.org, "event_id"),
+		),
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch event translations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eventID int64
+		var t EventTranslation
+		if err := rows.Scan(&eventID, &t.Language, &t.Name, &t.Description, &t.Venue); err != nil {
+			return nil, fmt.Errorf("error scanning event translation: %w", err)
+		}
+		translations[eventID] = append(translations[eventID], t)
+	}
+	return translations, rows.Err()
+}
+
+// Localize shows the event in language by merging its translation into the
+// top-level fields. Without a translation for it the fields are left as they
+// are, which is the default language.
+func (e *Event) Localize(language string) {
+	e.Language = language
+	for _, t := range e.Translations {
+		if t.Language == language {
+			e.Name = t.Name
+			e.Description = t.Description
+			e.Venue = t.Venue
+			return
+		}
+	}
+}
+
+// Languages lists the languages the event can be shown in, starting with the
+// default one its own fields are written in.
+func (e *Event) Languages(defaultLanguage string) []string {
+	languages := []string{defaultLanguage}
+	for _, t := range e.Translations {
+		if t.Language != defaultLanguage {
+			languages = append(languages, t.Language)
+		}
+	}
+	return languages
+}
+
+const (
+	TranslationComplete = "complete"
+	TranslationMissing  = "missing"
+	TranslationStale    = "stale"
+)
+
+// EventTranslationReport tells how far each language of an event lags behind
+// the event's own text.
+type EventTranslationReport struct {
+	EventID       int64                       `json:"eventId"`
+	Name          string                      `json:"name"`
+	TextUpdatedAt *string                     `json:"textUpdatedAt"`
+	Complete      bool                        `json:"complete"`
+	Languages     []LanguageTranslationStatus `json:"languages"`
+}
+
+// LanguageTranslationStatus maps every translatable field to complete, missing
+// or stale. A field is stale when the translation was last edited before the
+// event text was.
+type LanguageTranslationStatus struct {
+	Language  string            `json:"language"`
+	UpdatedAt *string           `json:"updatedAt"`
+	Complete  bool              `json:"complete"`
+	Fields    map[string]string `json:"fields"`
+}
+
+// GetTranslationReport checks every live event against the given languages.
+func (r *EventRepository) GetTranslationReport(languages []string) ([]EventTranslationReport, error) {
+	rows, err := r.db.Query(
+		`SELECT e.id, e.name, e.text_updated_at, t.language, t.name, t.description, t.venue, t.updated_at,
+		        COALESCE(e.text_updated_at IS NOT NULL AND (t.updated_at IS NULL OR t.updated_at < e.text_updated_at), 0)
+		 FROM events e
+		 LEFT JOIN event_translations t ON t.event_id = e.id
+		 WHERE e.deleted_at IS NULL` + orgFilter(r.org, "e.organization_id") + `
+		 ORDER BY e.id, t.language`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch translation status: %w", err)
+	}
+	defer rows.Close()
+
+	type translationRow struct {
+		name, description, venue string
+		updatedAt                *string
+		stale                    bool
+	}
+
+	reports := []EventTranslationReport{}
+	translations := map[int64]map[string]translationRow{}
+	for rows.Next() {
+		var (
+			eventID                                int64
+			eventName                              string
+			textUpdatedAt, language                *string
+			name, description, venue, translatedAt *string
+			stale                                  bool
+		)
+		if err := rows.Scan(&eventID, &eventName, &textUpdatedAt, &language, &name, &description, &venue, &translatedAt, &stale); err != nil {
+			return nil, fmt.Errorf("error scanning translation status: %w", err)
+		}
+
+		if len(reports) == 0 || reports[len(reports)-1].EventID != eventID {
+			reports = append(reports, EventTranslationReport{EventID: eventID, Name: eventName, TextUpdatedAt: textUpdatedAt})
+			translations[eventID] = map[string]translationRow{}
+		}
+		if language != nil {
+			translations[eventID][*language] = translationRow{
+				name:        *name,
+				description: *description,
+				venue:       *venue,
+				updatedAt:   translatedAt,
+				stale:       stale,
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch translation status: %w", err)
+	}
+
+	for i := range reports {
+		report := &reports[i]
+		report.Complete = true
+		report.Languages = []LanguageTranslationStatus{}
+
+		for _, language := range languages {
+			status := LanguageTranslationStatus{Language: language, Complete: true, Fields: map[string]string{}}
+			t, ok := translations[report.EventID][language]
+			if ok {
+				status.UpdatedAt = t.updatedAt
+			}
+
+			for field, value := range map[string]string{"name": t.name, "description": t.description, "venue": t.venue} {
+				switch {
+				case !ok || value == "":
+					status.Fields[field] = TranslationMissing
+				case t.stale:
+					status.Fields[field] = TranslationStale
+				default:
+					status.Fields[field] = TranslationComplete
+				}
+				if status.Fields[field] != TranslationComplete {
+					status.Complete = false
+				}
+			}
+
+			report.Complete = report.Complete && status.Complete
+			report.Languages = append(report.Languages, status)
+		}
+	}
+
+	return reports, nil
+}
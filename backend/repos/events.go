@@ -3,6 +3,8 @@ package repos
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 )
 
 type Event struct {
@@ -13,8 +15,13 @@ type Event struct {
 	Date         string             `json:"date"`
 	Venue        string             `json:"venue"`
 	Price        float64            `json:"price"`
-	Image        []byte             `json:"image,omitempty"`
+	ImageURL     string             `json:"imageUrl,omitempty"`
+	ImageID      *int64             `json:"-"`
+	ImageHash    *string            `json:"-"`
+	Language     string             `json:"language,omitempty"`
 	Translations []EventTranslation `json:"translations"`
+	Version      int64              `json:"version,omitempty"`
+	DeletedAt    *string            `json:"deletedAt,omitempty"`
 }
 
 type EventTranslation struct {
@@ -24,30 +31,70 @@ type EventTranslation struct {
 	Venue       string `json:"venue"`
 }
 
+// EventRepository sees every event, or only those of one organization once
+// scoped with InOrganization.
 type EventRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	org *int64
 }
 
 type EventInterface interface {
 	GetAllEvents() ([]Event, error)
 	GetEventById(id int64) (*Event, error)
-	CreateEvent(name, description, category, date, venue string, price float64, image []byte, eventTranslations []EventTranslation) (int64, error)
-	UpdateEvent(id int64, name, description, category, date, venue string, price float64, image []byte, eventTranslations []EventTranslation) error
+	CreateEvent(name, description, category, date, venue string, price float64, eventTranslations []EventTranslation, createdBy int64) (int64, error)
+	UpdateEvent(id, version int64, name, description, category, date, venue string, price float64, eventTranslations []EventTranslation) error
+	PatchEvent(id, version int64, patch EventPatch) error
 	GetEventsByCategory(category string) ([]Event, error)
 	GetUpcomingEvents() ([]Event, error)
 	GetEventsForUser(userID int64) ([]Event, error)
-	DeleteEvent(id int64) error
+	DeleteEvent(id, version int64) error
+	SetEventImage(id, version int64, imageID *int64) (*int64, error)
+	GetLegacyImageEventIds() ([]int64, error)
+	GetLegacyEventImage(id int64) ([]byte, error)
+	GetDeletedEvents() ([]Event, error)
+	RestoreEvent(id int64) error
+	PurgeDeletedEvents(deletedBefore time.Time) (int64, error)
 	SearchEvents(query string) ([]Event, error)
 	GetEventTranslations(id int64) ([]EventTranslation, error)
+	GetEventTranslation(eventID int64, language string) (*EventTranslation, error)
+	SaveEventTranslation(eventID int64, t EventTranslation) (bool, error)
+	DeleteEventTranslation(eventID int64, language string) error
+	GetTranslationsForEvents(eventIDs []int64) (map[int64][]EventTranslation, error)
+	GetTranslationReport(languages []string) ([]EventTranslationReport, error)
 	RegisterUserToEvent(userID, eventID int64) error
+	RecordEventRevision(eventID int64, action, author string) (int64, error)
+	GetEventRevisions(eventID int64) ([]EventRevision, error)
+	GetEventRevision(eventID, revision int64) (*EventRevision, error)
+	AddEventMedia(eventID, imageID int64, caption, altText string, translations []EventMediaTranslation) (int64, error)
+	GetEventMedia(eventID int64) ([]EventMedia, error)
+	GetEventMediaItem(eventID, mediaID int64) (*EventMedia, error)
+	UpdateEventMedia(eventID, mediaID int64, caption, altText string, translations []EventMediaTranslation) error
+	ReorderEventMedia(eventID int64, mediaIDs []int64) error
+	DeleteEventMedia(eventID, mediaID int64) (int64, error)
+	SetEventCover(eventID, mediaID int64) (*int64, error)
+	IsEventOrganizer(eventID, userID int64) (bool, error)
+	IsEventOwner(eventID, userID int64) (bool, error)
+	GetEventOrganizers(eventID int64) ([]EventOrganizer, error)
+	AddEventOrganizer(eventID, userID int64) error
+	RemoveEventOrganizer(eventID, userID int64) error
+	GetOrganizedEvents(userID int64) ([]Event, error)
+	GetEventAttendees(eventID int64) ([]Attendee, error)
+	CheckInAttendee(eventID, userID int64) error
+	UndoCheckIn(eventID, userID int64) error
 }
 
 func NewEventRepository(db *sql.DB) *EventRepository {
 	return &EventRepository{db: db}
 }
 
+// InOrganization returns a copy of the repository that only reads and writes
+// the events of orgID, and creates new ones in it.
+func (r *EventRepository) InOrganization(orgID int64) *EventRepository {
+	return &EventRepository{db: r.db, org: &orgID}
+}
+
 func (r *EventRepository) GetAllEvents() ([]Event, error) {
-	rows, err := r.db.Query("SELECT id, name, description, category, date, venue, price FROM events")
+	rows, err := r.db.Query("SELECT id, name, description, category, date, venue, price, version FROM events WHERE deleted_at IS NULL" + orgFilter(r.org, "organization_id"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch events: %w", err)
 	}
@@ -56,7 +103,7 @@ func (r *EventRepository) GetAllEvents() ([]Event, error) {
 	events := []Event{}
 	for rows.Next() {
 		var e Event
-		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price); err != nil {
+		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.Version); err != nil {
 			return nil, fmt.Errorf("error scanning event row: %w", err)
 		}
 		events = append(events, e)
@@ -67,14 +114,18 @@ func (r *EventRepository) GetAllEvents() ([]Event, error) {
 
 func (r *EventRepository) GetEventById(id int64) (*Event, error) {
 	var e Event
-	query := "SELECT id, name, description, category, date, venue, price, image FROM events WHERE id = ?"
-	err := r.db.QueryRow(query, id).Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.Image)
+	query := `SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, e.version, e.image_id, i.hash 
+		 FROM events e 
+		 LEFT JOIN images i ON i.id = e.image_id 
+		 WHERE e.id = ? AND e.deleted_at IS NULL` + orgFilter(r.org, "e.organization_id")
+	err := r.db.QueryRow(query, id).Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.Version, &e.ImageID, &e.ImageHash)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to get event by id %d: %w", id, err)
 	}
+	e.setImageURL()
 
 	e.Translations, err = r.GetEventTranslations(id)
 	if err != nil {
@@ -84,7 +135,7 @@ func (r *EventRepository) GetEventById(id int64) (*Event, error) {
 }
 
 func (r *EventRepository) GetEventsByCategory(category string) ([]Event, error) {
-	rows, err := r.db.Query("SELECT id, name, description, category, date, venue, price FROM events WHERE category = ?", category)
+	rows, err := r.db.Query("SELECT id, name, description, category, date, venue, price, version FROM events WHERE category = ? AND deleted_at IS NULL"+orgFilter(r.org, "organization_id"), category)
 	if err != nil {
 		return nil, fmt.Errorf("fetching events by category failed: %w", err)
 	}
@@ -93,7 +144,7 @@ func (r *EventRepository) GetEventsByCategory(category string) ([]Event, error)
 	events := []Event{}
 	for rows.Next() {
 		var e Event
-		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price); err != nil {
+		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.Version); err != nil {
 			return nil, fmt.Errorf("error scanning event by category: %w", err)
 		}
 		events = append(events, e)
@@ -102,11 +153,11 @@ func (r *EventRepository) GetEventsByCategory(category string) ([]Event, error)
 	return events, rows.Err()
 }
 
-func (r *EventRepository) CreateEvent(name, description, category, date, venue string, price float64, image []byte, eventTranslations []EventTranslation) (int64, error) {
+func (r *EventRepository) CreateEvent(name, description, category, date, venue string, price float64, eventTranslations []EventTranslation, createdBy int64) (int64, error) {
 	result, err := r.db.Exec(
-		`INSERT INTO events (name, description, category, date, venue, price, image) 
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		name, description, category, date, venue, price, image,
+		`INSERT INTO events (name, description, category, date, venue, price, created_by, organization_id, text_updated_at) 
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		name, description, category, date, venue, price, createdBy, r.org,
 	)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create event: %w", err)
@@ -119,8 +170,8 @@ func (r *EventRepository) CreateEvent(name, description, category, date, venue s
 
 	for _, et := range eventTranslations {
 		_, err := r.db.Exec(
-			`INSERT INTO event_translations (event_id, language, name, description, venue) 
-			 VALUES (?, ?, ?, ?, ?)`,
+			`INSERT INTO event_translations (event_id, language, name, description, venue, updated_at) 
+			 VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
 			eventId, et.Language, et.Name, et.Description, et.Venue,
 		)
 		if err != nil {
@@ -130,50 +181,221 @@ func (r *EventRepository) CreateEvent(name, description, category, date, venue s
 	return result.LastInsertId()
 }
 
-func (r *EventRepository) UpdateEvent(id int64, name, description, category, date, venue string, price float64, image []byte, eventTranslations []EventTranslation) error {
-	_, err := r.db.Exec(
+// UpdateEvent only applies when the stored version still equals version, and
+// bumps it, with the event row and its translations changed atomically.
+func (r *EventRepository) UpdateEvent(id, version int64, name, description, category, date, venue string, price float64, eventTranslations []EventTranslation) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start updating event id %d: %w", id, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
 		`UPDATE events 
-		 SET name = ?, description = ?, category = ?, date = ?, venue = ?, price = ?, image = ? 
-		 WHERE id = ?`,
-		name, description, category, date, venue, price, image, id,
+		 SET `+touchTextUpdatedAt+`, 
+		 name = ?, description = ?, category = ?, date = ?, venue = ?, price = ?, version = version + 1 
+		 WHERE id = ? AND version = ? AND deleted_at IS NULL`+orgFilter(r.org, "organization_id"),
+		name, description, venue, name, description, category, date, venue, price, id, version,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update event id %d: %w", id, err)
 	}
 
-	_, err = r.db.Exec(`DELETE FROM event_translations WHERE event_id = ?`, id)
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to delete existing event translations: %w", err)
+		return fmt.Errorf("couldn't verify update result: %w", err)
+	}
+	if rowsAffected == 0 {
+		tx.Rollback()
+		return r.versionMismatchError(id, version)
 	}
 
+	// Translations are upserted rather than recreated so the ones that did not
+	// change keep their updated_at, which the completeness report relies on
+	languages := make([]any, 0, len(eventTranslations)+1)
+	languages = append(languages, id)
 	for _, et := range eventTranslations {
-		_, err := r.db.Exec(
-			`INSERT INTO event_translations (event_id, language, name, description, venue) 
-         VALUES (?, ?, ?, ?, ?)`,
-			id, et.Language, et.Name, et.Description, et.Venue,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert event translation: %w", err)
+		languages = append(languages, et.Language)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(eventTranslations)), ", ")
+	_, err = tx.Exec(
+		fmt.Sprintf("DELETE FROM event_translations WHERE event_id = ? AND language NOT IN (%s)", placeholders),
+		languages...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete removed event translations: %w", err)
+	}
+
+	for _, et := range eventTranslations {
+		if err := upsertEventTranslation(tx, id, et); err != nil {
+			return err
 		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit update of event id %d: %w", id, err)
+	}
 	return nil
 }
 
-func (r *EventRepository) DeleteEvent(id int64) error {
-	_, err := r.db.Exec("DELETE FROM events WHERE id = ?", id)
+func (r *EventRepository) DeleteEvent(id, version int64) error {
+	result, err := r.db.Exec("UPDATE events SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND version = ? AND deleted_at IS NULL"+orgFilter(r.org, "organization_id"), id, version)
 	if err != nil {
 		return fmt.Errorf("failed to delete event id %d: %w", id, err)
 	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("couldn't verify deletion result: %w", err)
+	}
+	if rowsAffected == 0 {
+		return r.versionMismatchError(id, version)
+	}
+
 	return nil
 }
 
-func (r *EventRepository) GetUpcomingEvents() ([]Event, error) {
+// SetEventImage points the event at a stored image, or at none when imageID is
+// nil, and returns the image it replaced so the caller can delete it. The image
+// is part of the event, so like UpdateEvent this only applies when the stored
+// version still equals version, and bumps it.
+func (r *EventRepository) SetEventImage(id, version int64, imageID *int64) (*int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start setting image of event id %d: %w", id, err)
+	}
+	defer tx.Rollback()
+
+	// Both statements check the version, so the image read is the one replaced
+	var previous *int64
+	err = tx.QueryRow("SELECT image_id FROM events WHERE id = ? AND version = ? AND deleted_at IS NULL"+orgFilter(r.org, "organization_id"), id, version).Scan(&previous)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, r.versionMismatchError(id, version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image of event id %d: %w", id, err)
+	}
+
+	result, err := tx.Exec("UPDATE events SET image_id = ?, image = NULL, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL"+orgFilter(r.org, "organization_id"), imageID, id, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set image of event id %d: %w", id, err)
+	}
+	if err := requireAffected(result, fmt.Errorf("event id %d is no longer at version %d: %w", id, version, ErrVersionConflict)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit image of event id %d: %w", id, err)
+	}
+	return previous, nil
+}
+
+// GetLegacyImageEventIds lists live events whose image still sits in the old
+// BLOB column. Deleted events can't be changed, their image is migrated once
+// they are restored.
+func (r *EventRepository) GetLegacyImageEventIds() ([]int64, error) {
+	rows, err := r.db.Query("SELECT id FROM events WHERE image IS NOT NULL AND image_id IS NULL AND deleted_at IS NULL" + orgFilter(r.org, "organization_id"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch events with legacy images: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning event id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *EventRepository) GetLegacyEventImage(id int64) ([]byte, error) {
+	var image []byte
+	err := r.db.QueryRow("SELECT image FROM events WHERE id = ?"+orgFilter(r.org, "organization_id"), id).Scan(&image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get legacy image of event id %d: %w", id, err)
+	}
+	return image, nil
+}
+
+// versionMismatchError tells apart a conditional write that missed because the
+// event is gone from one that missed because somebody else changed it first.
+func (r *EventRepository) versionMismatchError(id, version int64) error {
+	var current int64
+	err := r.db.QueryRow("SELECT version FROM events WHERE id = ? AND deleted_at IS NULL"+orgFilter(r.org, "organization_id"), id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no event found with id %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get version of event id %d: %w", id, err)
+	}
+	return fmt.Errorf("event id %d is at version %d, not %d: %w", id, current, version, ErrVersionConflict)
+}
+
+func (r *EventRepository) GetDeletedEvents() ([]Event, error) {
 	rows, err := r.db.Query(
-		`SELECT id, name, description, category, date, venue, price, image 
+		`SELECT id, name, description, category, date, venue, price, deleted_at 
 		 FROM events 
-		 WHERE date >= datetime('now') 
-		 ORDER BY date ASC`,
+		 WHERE deleted_at IS NOT NULL` + orgFilter(r.org, "organization_id") + `
+		 ORDER BY deleted_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deleted events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.DeletedAt); err != nil {
+			return nil, fmt.Errorf("error scanning deleted event: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *EventRepository) RestoreEvent(id int64) error {
+	result, err := r.db.Exec("UPDATE events SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL"+orgFilter(r.org, "organization_id"), id)
+	if err != nil {
+		return fmt.Errorf("failed to restore event id %d: %w", id, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("couldn't verify restore result: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no deleted event found with id %d: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// PurgeDeletedEvents hard-deletes events soft-deleted before the given time,
+// translations and registrations go with them through the foreign key cascade.
+func (r *EventRepository) PurgeDeletedEvents(deletedBefore time.Time) (int64, error) {
+	result, err := r.db.Exec(
+		"DELETE FROM events WHERE deleted_at IS NOT NULL AND deleted_at < ?"+orgFilter(r.org, "organization_id"),
+		deletedBefore.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *EventRepository) GetUpcomingEvents() ([]Event, error) {
+	rows, err := r.db.Query(
+		`SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, i.hash 
+		 FROM events e 
+		 LEFT JOIN images i ON i.id = e.image_id 
+		 WHERE e.deleted_at IS NULL AND e.date >= datetime('now')` + orgFilter(r.org, "e.organization_id") + `
+		 ORDER BY e.date ASC`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch upcoming events: %w", err)
@@ -183,9 +405,10 @@ func (r *EventRepository) GetUpcomingEvents() ([]Event, error) {
 	events := []Event{}
 	for rows.Next() {
 		var e Event
-		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.Image); err != nil {
+		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.ImageHash); err != nil {
 			return nil, fmt.Errorf("error scanning upcoming event: %w", err)
 		}
+		e.setImageURL()
 		events = append(events, e)
 	}
 
@@ -195,9 +418,9 @@ func (r *EventRepository) GetUpcomingEvents() ([]Event, error) {
 func (r *EventRepository) SearchEvents(keyword string) ([]Event, error) {
 	searchTerm := "%" + keyword + "%"
 	rows, err := r.db.Query(
-		`SELECT id, name, description, category, date, venue, price 
+		`SELECT id, name, description, category, date, venue, price, version
 		 FROM events 
-		 WHERE name LIKE ?`,
+		 WHERE name LIKE ? AND deleted_at IS NULL`+orgFilter(r.org, "organization_id"),
 		searchTerm, searchTerm, searchTerm,
 	)
 	if err != nil {
@@ -208,7 +431,7 @@ func (r *EventRepository) SearchEvents(keyword string) ([]Event, error) {
 	events := []Event{}
 	for rows.Next() {
 		var e Event
-		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price); err != nil {
+		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.Version); err != nil {
 			return nil, fmt.Errorf("error scanning search result: %w", err)
 		}
 		events = append(events, e)
@@ -216,23 +439,39 @@ func (r *EventRepository) SearchEvents(keyword string) ([]Event, error) {
 	return events, rows.Err()
 }
 
+// RegisterUserToEvent returns ErrNotFound when the repository is scoped and
+// the event or the user aren't part of its organization.
 func (r *EventRepository) RegisterUserToEvent(userID, eventID int64) error {
-	_, err := r.db.Exec(`
+	if r.org == nil {
+		_, err := r.db.Exec(`
 		INSERT INTO registrations (user_id, event_id)
 		VALUES (?, ?)
 	`, userID, eventID)
+		if err != nil {
+			return fmt.Errorf("failed to register user %d to event %d: %w", userID, eventID, err)
+		}
+		return nil
+	}
+
+	result, err := r.db.Exec(
+		`INSERT INTO registrations (user_id, event_id)
+		 SELECT u.id, e.id FROM users u, events e
+		 WHERE u.id = ? AND e.id = ?`+orgMemberFilter(r.org, "u.id")+orgFilter(r.org, "e.organization_id"),
+		userID, eventID,
+	)
 	if err != nil {
 		return fmt.Errorf("failed to register user %d to event %d: %w", userID, eventID, err)
 	}
-	return nil
+	return requireAffected(result, fmt.Errorf("user %d or event %d isn't in organization %d: %w", userID, eventID, *r.org, ErrNotFound))
 }
 
 func (r *EventRepository) GetEventsForUser(userID int64) ([]Event, error) {
 	rows, err := r.db.Query(
-		`SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, e.image
+		`SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, i.hash
 		 FROM events e
 		 JOIN registrations r ON e.id = r.event_id
-		 WHERE r.user_id = ?`, userID)
+		 LEFT JOIN images i ON i.id = e.image_id
+		 WHERE r.user_id = ? AND e.deleted_at IS NULL`+orgFilter(r.org, "e.organization_id"), userID)
 	if err != nil {
 		return nil, err
 	}
@@ -241,16 +480,23 @@ func (r *EventRepository) GetEventsForUser(userID int64) ([]Event, error) {
 	events := []Event{}
 	for rows.Next() {
 		var e Event
-		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.Image); err != nil {
+		if err := rows.Scan(&e.ID, &e.Name, &e.Description, &e.Category, &e.Date, &e.Venue, &e.Price, &e.ImageHash); err != nil {
 			return nil, err
 		}
+		e.setImageURL()
 		events = append(events, e)
 	}
 	return events, nil
 }
 
+func (e *Event) setImageURL() {
+	if e.ImageHash != nil {
+		e.ImageURL = fmt.Sprintf("/events/%d/image?v=%s", e.ID, ImageVersion(*e.ImageHash))
+	}
+}
+
 func (r *EventRepository) GetEventTranslations(eventId int64) ([]EventTranslation, error) {
-	rows, err := r.db.Query("SELECT language, name, description, venue FROM event_translations WHERE event_id = ?", eventId)
+	rows, err := r.db.Query("SELECT language, name, description, venue FROM event_translations WHERE event_id = ?"+orgEventFilter(r.org, "event_id"), eventId)
 	if err != nil {
 		return nil, fmt.Errorf("fetching events by category failed: %w", err)
 	}
@@ -0,0 +1,127 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// OIDCLogin is a single sign-on login waiting for the identity provider to
+// redirect back. UserID is set when a logged in user links their account.
+// BrowserHash is the hash of the cookie given to the browser that started it.
+type OIDCLogin struct {
+	StateHash    string
+	CodeVerifier string
+	Nonce        string
+	UserID       *int64
+	BrowserHash  string
+	ExpiresAt    time.Time
+}
+
+func (r *AuthRepository) CreateOIDCLogin(login OIDCLogin) error {
+	_, err := r.db.Exec(
+		"INSERT INTO oidc_logins (state_hash, code_verifier, nonce, user_id, browser_hash, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		login.StateHash, login.CodeVerifier, login.Nonce, login.UserID, login.BrowserHash, login.ExpiresAt.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create single sign-on login: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCLogin removes the login so its state can't be replayed,
+// returning ErrNotFound for unknown or expired states.
+func (r *AuthRepository) ConsumeOIDCLogin(stateHash string) (*OIDCLogin, error) {
+	login := OIDCLogin{StateHash: stateHash}
+	var userID sql.NullInt64
+	err := r.db.QueryRow(
+		`DELETE FROM oidc_logins WHERE state_hash = ? AND expires_at > CURRENT_TIMESTAMP
+		 RETURNING code_verifier, nonce, user_id, browser_hash, expires_at`,
+		stateHash,
+	).Scan(&login.CodeVerifier, &login.Nonce, &userID, &login.BrowserHash, &login.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no pending single sign-on login: %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get single sign-on login: %w", err)
+	}
+
+	if userID.Valid {
+		login.UserID = &userID.Int64
+	}
+	return &login, nil
+}
+
+// GetAuthUserByIdentity finds the user an identity provider account is
+// linked to, nil when there is none.
+func (r *AuthRepository) GetAuthUserByIdentity(issuer, subject string) (*AuthUser, error) {
+	var u AuthUser
+	err := r.db.QueryRow(
+		`SELECT u.id, u.username, u.role, u.created_at, u.password_hash
+		 FROM user_identities i JOIN users u ON u.id = i.user_id
+		 WHERE i.issuer = ? AND i.subject = ? AND u.deleted_at IS NULL`,
+		issuer, subject,
+	).Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.PasswordHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by identity: %w", err)
+	}
+	return &u, nil
+}
+
+// LinkIdentity returns ErrAlreadyExists when the identity provider account
+// is linked already, to this user or another one.
+func (r *AuthRepository) LinkIdentity(userID int64, issuer, subject string) error {
+	return linkIdentity(r.db, userID, issuer, subject)
+}
+
+func linkIdentity(db execer, userID int64, issuer, subject string) error {
+	result, err := db.Exec(
+		"INSERT INTO user_identities (user_id, issuer, subject) VALUES (?, ?, ?) ON CONFLICT (issuer, subject) DO NOTHING",
+		userID, issuer, subject,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to link identity to user id %d: %w", userID, err)
+	}
+	return requireAffected(result, fmt.Errorf("identity is linked to an account already: %w", ErrAlreadyExists))
+}
+
+// ProvisionUser creates a user for a first time single sign-on login. It gets
+// no password, so it can only log in through the identity provider until
+// one is set with a password reset.
+func (r *AuthRepository) ProvisionUser(username, role, issuer, subject string) (*AuthUser, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start provisioning user: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE username = ?)", username).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check username: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("user '%s' already exists: %w", username, ErrAlreadyExists)
+	}
+
+	result, err := tx.Exec("INSERT INTO users (username, password_hash, role) VALUES (?, '', ?)", username, role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get id of the new user: %w", err)
+	}
+
+	if err := linkIdentity(tx, userID, issuer, subject); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit new user: %w", err)
+	}
+
+	return &AuthUser{ID: userID, Username: username, Role: role}, nil
+}
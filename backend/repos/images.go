@@ -0,0 +1,231 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"immodi/submission-backend/imaging"
+	"immodi/submission-backend/storage"
+	"log"
+	"time"
+)
+
+// imageInUse matches images still referenced by an event, a gallery or an avatar.
+const imageInUse = `(EXISTS (SELECT 1 FROM events WHERE events.image_id = images.id)
+	OR EXISTS (SELECT 1 FROM event_media WHERE event_media.image_id = images.id)
+	OR EXISTS (SELECT 1 FROM users WHERE users.avatar_image_id = images.id))`
+
+// ImageVersion is the part of the image hash that goes into image URLs as ?v=.
+// Images are served without authentication, so knowing it is what allows
+// fetching one.
+func ImageVersion(hash string) string {
+	return hash[:16]
+}
+
+type Image struct {
+	ID          int64          `json:"id"`
+	Hash        string         `json:"hash"`
+	ContentType string         `json:"contentType"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Variants    []ImageVariant `json:"variants"`
+}
+
+type ImageVariant struct {
+	ImageID     int64  `json:"-"`
+	ImageHash   string `json:"-"`
+	BlobKey     string `json:"-"`
+	Size        string `json:"size"`
+	ContentType string `json:"contentType"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// ImageRepository keeps image metadata in the database and the bytes of every
+// variant in the BlobStore.
+type ImageRepository struct {
+	db    *sql.DB
+	store storage.BlobStore
+}
+
+type ImageInterface interface {
+	SaveImage(processed *imaging.ProcessedImage) (*Image, error)
+	GetImageVariant(imageID int64, size string) (*ImageVariant, []byte, error)
+	DeleteImage(imageID int64) error
+	DeleteImageIfUnused(imageID int64) error
+	DeleteOrphanedImages(createdBefore time.Time) (int64, error)
+}
+
+func NewImageRepository(db *sql.DB, store storage.BlobStore) *ImageRepository {
+	return &ImageRepository{db: db, store: store}
+}
+
+func (r *ImageRepository) SaveImage(processed *imaging.ProcessedImage) (*Image, error) {
+	result, err := r.db.Exec(
+		"INSERT INTO images (hash, content_type, width, height) VALUES (?, ?, ?, ?)",
+		processed.Hash, processed.ContentType, processed.Width, processed.Height,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image: %w", err)
+	}
+
+	imageID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	img := &Image{
+		ID:          imageID,
+		Hash:        processed.Hash,
+		ContentType: processed.ContentType,
+		Width:       processed.Width,
+		Height:      processed.Height,
+		Variants:    []ImageVariant{},
+	}
+
+	for _, v := range processed.Variants {
+		key := fmt.Sprintf("images/%d/%s.%s", imageID, v.Size, v.Extension)
+		if err := r.store.Put(key, v.Data); err != nil {
+			r.cleanupFailedSave(imageID)
+			return nil, err
+		}
+
+		_, err := r.db.Exec(
+			`INSERT INTO image_variants (image_id, size, blob_key, content_type, width, height, byte_size)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			imageID, v.Size, key, v.ContentType, v.Width, v.Height, len(v.Data),
+		)
+		if err != nil {
+			r.store.Delete(key)
+			r.cleanupFailedSave(imageID)
+			return nil, fmt.Errorf("failed to create image variant: %w", err)
+		}
+
+		img.Variants = append(img.Variants, ImageVariant{
+			ImageID:     imageID,
+			ImageHash:   processed.Hash,
+			BlobKey:     key,
+			Size:        v.Size,
+			ContentType: v.ContentType,
+			Width:       v.Width,
+			Height:      v.Height,
+			Bytes:       int64(len(v.Data)),
+		})
+	}
+
+	return img, nil
+}
+
+func (r *ImageRepository) cleanupFailedSave(imageID int64) {
+	if err := r.DeleteImage(imageID); err != nil {
+		log.Printf("Failed to clean up partially saved image %d: %v", imageID, err)
+	}
+}
+
+func (r *ImageRepository) GetImageVariant(imageID int64, size string) (*ImageVariant, []byte, error) {
+	var v ImageVariant
+	err := r.db.QueryRow(
+		`SELECT v.image_id, i.hash, v.blob_key, v.size, v.content_type, v.width, v.height, v.byte_size
+		 FROM image_variants v
+		 JOIN images i ON i.id = v.image_id
+		 WHERE v.image_id = ? AND v.size = ?`,
+		imageID, size,
+	).Scan(&v.ImageID, &v.ImageHash, &v.BlobKey, &v.Size, &v.ContentType, &v.Width, &v.Height, &v.Bytes)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get %s variant of image %d: %w", size, imageID, err)
+	}
+
+	data, err := r.store.Get(v.BlobKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &v, data, nil
+}
+
+func (r *ImageRepository) DeleteImage(imageID int64) error {
+	rows, err := r.db.Query("SELECT blob_key FROM image_variants WHERE image_id = ?", imageID)
+	if err != nil {
+		return fmt.Errorf("failed to get variants of image %d: %w", imageID, err)
+	}
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return fmt.Errorf("error scanning image variant: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get variants of image %d: %w", imageID, err)
+	}
+
+	for _, key := range keys {
+		if err := r.store.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.db.Exec("DELETE FROM images WHERE id = ?", imageID); err != nil {
+		return fmt.Errorf("failed to delete image %d: %w", imageID, err)
+	}
+	return nil
+}
+
+// DeleteImageIfUnused deletes the image unless an event, gallery or avatar still uses it.
+func (r *ImageRepository) DeleteImageIfUnused(imageID int64) error {
+	var unused bool
+	err := r.db.QueryRow("SELECT NOT "+imageInUse+" FROM images WHERE id = ?", imageID).Scan(&unused)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check usage of image %d: %w", imageID, err)
+	}
+	if !unused {
+		return nil
+	}
+	return r.DeleteImage(imageID)
+}
+
+// DeleteOrphanedImages removes images nothing points at anymore, such as ones
+// replaced by a newer upload or left over from a failed request. Only images
+// older than createdBefore are considered so in-flight uploads are left alone.
+func (r *ImageRepository) DeleteOrphanedImages(createdBefore time.Time) (int64, error) {
+	rows, err := r.db.Query(
+		"SELECT id FROM images WHERE created_at < ? AND NOT "+imageInUse,
+		createdBefore.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find orphaned images: %w", err)
+	}
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning orphaned image: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find orphaned images: %w", err)
+	}
+
+	var deleted int64
+	for _, id := range ids {
+		if err := r.DeleteImage(id); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
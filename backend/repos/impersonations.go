@@ -0,0 +1,75 @@
+package repos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ImpersonationEntry is one request an admin made as another user. The log
+// keeps the ids of both, so it outlives them being deleted.
+type ImpersonationEntry struct {
+	ID            int64   `json:"id"`
+	ActorID       int64   `json:"actorId"`
+	ActorUsername *string `json:"actorUsername"`
+	UserID        int64   `json:"userId"`
+	Username      *string `json:"username"`
+	TokenID       string  `json:"tokenId"`
+	Method        string  `json:"method"`
+	Path          string  `json:"path"`
+	Status        int     `json:"status"`
+	IP            string  `json:"ip"`
+	CreatedAt     string  `json:"createdAt"`
+}
+
+func (r *AuthRepository) RecordImpersonation(e ImpersonationEntry) error {
+	_, err := r.db.Exec(
+		`INSERT INTO impersonation_log (actor_id, user_id, token_id, method, path, status, ip)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.ActorID, e.UserID, e.TokenID, e.Method, e.Path, e.Status, e.IP,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record impersonation of user id %d by user id %d: %w", e.UserID, e.ActorID, err)
+	}
+	return nil
+}
+
+// GetImpersonationLog lists the latest limit entries, newest first, only those
+// of actorID and userID when they aren't 0.
+func (r *AuthRepository) GetImpersonationLog(actorID, userID int64, limit int) ([]ImpersonationEntry, error) {
+	where := []string{"1 = 1"}
+	args := []any{}
+	if actorID != 0 {
+		where = append(where, "l.actor_id = ?")
+		args = append(args, actorID)
+	}
+	if userID != 0 {
+		where = append(where, "l.user_id = ?")
+		args = append(args, userID)
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.Query(
+		`SELECT l.id, l.actor_id, a.username, l.user_id, u.username, l.token_id, l.method, l.path, l.status, l.ip, l.created_at
+		 FROM impersonation_log l
+		 LEFT JOIN users a ON a.id = l.actor_id
+		 LEFT JOIN users u ON u.id = l.user_id
+		 WHERE `+strings.Join(where, " AND ")+`
+		 ORDER BY l.id DESC
+		 LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch impersonation log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []ImpersonationEntry{}
+	for rows.Next() {
+		var e ImpersonationEntry
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.ActorUsername, &e.UserID, &e.Username, &e.TokenID, &e.Method, &e.Path, &e.Status, &e.IP, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning impersonation log row: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
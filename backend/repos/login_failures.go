@@ -0,0 +1,179 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	LoginFailureUsername = "username"
+	LoginFailureIP       = "ip"
+)
+
+// LockoutPolicy decides how failed logins slow down further attempts. Once
+// Threshold failures are reached every failure blocks the next attempt for
+// BaseDelay, doubling each time, and MaxFailures locks out for Lockout.
+// Failures older than Window are forgotten.
+type LockoutPolicy struct {
+	Threshold   int
+	MaxFailures int
+	BaseDelay   time.Duration
+	Lockout     time.Duration
+	Window      time.Duration
+}
+
+// Delay is how long to wait after the given number of consecutive failures.
+func (p LockoutPolicy) Delay(failures int) time.Duration {
+	if failures >= p.MaxFailures {
+		return p.Lockout
+	}
+	if failures < p.Threshold {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures && delay < p.Lockout; i++ {
+		delay *= 2
+	}
+	return min(delay, p.Lockout)
+}
+
+// LoginFailures are the recent failed logins for a username or an IP address.
+type LoginFailures struct {
+	Kind         string     `json:"kind"`
+	Value        string     `json:"value"`
+	Failures     int        `json:"failures"`
+	LastFailedAt time.Time  `json:"lastFailedAt"`
+	LockedUntil  *time.Time `json:"lockedUntil"`
+}
+
+// GetLoginLockout returns until when logins for the username or IP are
+// blocked, nil when they aren't.
+func (r *AuthRepository) GetLoginLockout(kind, value string) (*time.Time, error) {
+	var lockedUntil sql.NullTime
+	err := r.db.QueryRow(
+		"SELECT locked_until FROM login_failures WHERE kind = ? AND value = ?",
+		kind, value,
+	).Scan(&lockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login lockout of %s %s: %w", kind, value, err)
+	}
+
+	if !lockedUntil.Valid || !lockedUntil.Time.After(time.Now()) {
+		return nil, nil
+	}
+	return &lockedUntil.Time, nil
+}
+
+// RecordLoginFailure counts a failed login and returns until when further
+// attempts are blocked by policy, nil when they aren't.
+func (r *AuthRepository) RecordLoginFailure(kind, value string, policy LockoutPolicy) (*time.Time, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start recording login failure: %w", err)
+	}
+	defer tx.Rollback()
+
+	var failures int
+	var lastFailedAt time.Time
+	err = tx.QueryRow(
+		"SELECT failures, last_failed_at FROM login_failures WHERE kind = ? AND value = ?",
+		kind, value,
+	).Scan(&failures, &lastFailedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get login failures of %s %s: %w", kind, value, err)
+	}
+
+	now := time.Now().UTC()
+	if now.Sub(lastFailedAt) > policy.Window {
+		failures = 0
+	}
+	failures++
+
+	var lockedUntil *time.Time
+	var lockedUntilValue any
+	if delay := policy.Delay(failures); delay > 0 {
+		until := now.Add(delay)
+		lockedUntil = &until
+		lockedUntilValue = until.Format(time.DateTime)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO login_failures (kind, value, failures, last_failed_at, locked_until)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (kind, value) DO UPDATE SET
+		 failures = excluded.failures, last_failed_at = excluded.last_failed_at, locked_until = excluded.locked_until`,
+		kind, value, failures, now.Format(time.DateTime), lockedUntilValue,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record login failure of %s %s: %w", kind, value, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit login failure: %w", err)
+	}
+	return lockedUntil, nil
+}
+
+// ClearLoginFailures forgets the failed logins of a username or IP, lifting
+// any lockout. It reports whether there was anything to clear.
+func (r *AuthRepository) ClearLoginFailures(kind, value string) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM login_failures WHERE kind = ? AND value = ?", kind, value)
+	if err != nil {
+		return false, fmt.Errorf("failed to clear login failures of %s %s: %w", kind, value, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("couldn't verify login failure deletion result: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// GetLoginFailures lists usernames and IPs with failed logins since
+// failedSince, or that are still locked out, locked ones first.
+func (r *AuthRepository) GetLoginFailures(failedSince time.Time) ([]LoginFailures, error) {
+	rows, err := r.db.Query(
+		`SELECT kind, value, failures, last_failed_at, locked_until
+		 FROM login_failures
+		 WHERE last_failed_at > ? OR locked_until > CURRENT_TIMESTAMP
+		 ORDER BY locked_until IS NULL, locked_until DESC, last_failed_at DESC`,
+		failedSince.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch login failures: %w", err)
+	}
+	defer rows.Close()
+
+	failures := []LoginFailures{}
+	for rows.Next() {
+		var f LoginFailures
+		var lockedUntil sql.NullTime
+		if err := rows.Scan(&f.Kind, &f.Value, &f.Failures, &f.LastFailedAt, &lockedUntil); err != nil {
+			return nil, fmt.Errorf("error scanning login failure row: %w", err)
+		}
+		if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+			f.LockedUntil = &lockedUntil.Time
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+// DeleteStaleLoginFailures drops failures from before failedBefore whose
+// lockout, if any, is over.
+func (r *AuthRepository) DeleteStaleLoginFailures(failedBefore time.Time) (int64, error) {
+	result, err := r.db.Exec(
+		`DELETE FROM login_failures
+		 WHERE last_failed_at < ? AND (locked_until IS NULL OR locked_until <= CURRENT_TIMESTAMP)`,
+		failedBefore.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale login failures: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,294 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// Organization is a tenant of the deployment, its events and members are
+// hidden from everybody outside of it.
+type Organization struct {
+	ID        int64  `json:"id"`
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+	Members   int64  `json:"members"`
+	Events    int64  `json:"events"`
+}
+
+// Membership is the role a user has in an organization. Role is empty when
+// the user isn't a member.
+type Membership struct {
+	OrganizationID int64  `json:"organizationId"`
+	Slug           string `json:"slug"`
+	Name           string `json:"name"`
+	Role           string `json:"role"`
+}
+
+type Member struct {
+	UserID   int64  `json:"userId"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	JoinedAt string `json:"joinedAt"`
+}
+
+type OrganizationRepository struct {
+	db *sql.DB
+}
+
+type OrganizationInterface interface {
+	GetOrganizations() ([]Organization, error)
+	GetOrganization(ref string) (*Organization, error)
+	CreateOrganization(slug, name string) (int64, error)
+	DeleteOrganization(id int64) error
+	GetMembership(userID int64, ref string) (*Membership, error)
+	GetUserMemberships(userID int64) ([]Membership, error)
+	GetMembers(orgID int64) ([]Member, error)
+	AddMember(orgID, userID int64, role string) error
+	UpdateMemberRole(orgID, userID int64, role string) error
+	RemoveMember(orgID, userID int64) error
+}
+
+func NewOrganizationRepository(db *sql.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// orgFilter narrows a query to the organization a repository is scoped to,
+// column holding an organization id. The id is no argument so the filter can
+// be appended to any query, unscoped repositories get an empty one.
+func orgFilter(org *int64, column string) string {
+	if org == nil {
+		return ""
+	}
+	return fmt.Sprintf(" AND %s = %d", column, *org)
+}
+
+// orgEventFilter is orgFilter for a column holding an event id.
+func orgEventFilter(org *int64, column string) string {
+	if org == nil {
+		return ""
+	}
+	return fmt.Sprintf(" AND %s IN (SELECT id FROM events WHERE organization_id = %d)", column, *org)
+}
+
+// orgMemberFilter is orgFilter for a column holding a user id.
+func orgMemberFilter(org *int64, column string) string {
+	if org == nil {
+		return ""
+	}
+	return fmt.Sprintf(" AND %s IN (SELECT user_id FROM organization_members WHERE organization_id = %d)", column, *org)
+}
+
+// orgRef matches an organization by id or slug, whichever ref is. Slugs start
+// with a letter, so they never look like an id.
+func orgRef(ref string) (string, []any) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return "o.id = ?", []any{id}
+	}
+	return "o.slug = ?", []any{ref}
+}
+
+const organizationColumns = `SELECT o.id, o.slug, o.name, o.created_at,
+	(SELECT COUNT(*) FROM organization_members m JOIN users u ON u.id = m.user_id WHERE m.organization_id = o.id AND u.deleted_at IS NULL),
+	(SELECT COUNT(*) FROM events e WHERE e.organization_id = o.id AND e.deleted_at IS NULL)
+	FROM organizations o`
+
+func scanOrganization(row rowScanner) (*Organization, error) {
+	var o Organization
+	if err := row.Scan(&o.ID, &o.Slug, &o.Name, &o.CreatedAt, &o.Members, &o.Events); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (r *OrganizationRepository) GetOrganizations() ([]Organization, error) {
+	rows, err := r.db.Query(organizationColumns + " ORDER BY o.id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve organizations: %w", err)
+	}
+	defer rows.Close()
+
+	organizations := []Organization{}
+	for rows.Next() {
+		o, err := scanOrganization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning organization row: %w", err)
+		}
+		organizations = append(organizations, *o)
+	}
+	return organizations, rows.Err()
+}
+
+// GetOrganization finds an organization by id or slug, returning ErrNotFound
+// when there is none.
+func (r *OrganizationRepository) GetOrganization(ref string) (*Organization, error) {
+	condition, args := orgRef(ref)
+	o, err := scanOrganization(r.db.QueryRow(organizationColumns+" WHERE "+condition, args...))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no organization %s: %w", ref, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization %s: %w", ref, err)
+	}
+	return o, nil
+}
+
+// CreateOrganization returns ErrAlreadyExists when the slug is taken.
+func (r *OrganizationRepository) CreateOrganization(slug, name string) (int64, error) {
+	result, err := r.db.Exec(
+		"INSERT INTO organizations (slug, name) VALUES (?, ?) ON CONFLICT (slug) DO NOTHING",
+		slug, name,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create organization %s: %w", slug, err)
+	}
+	if err := requireAffected(result, fmt.Errorf("organization %s exists: %w", slug, ErrAlreadyExists)); err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// DeleteOrganization returns ErrInUse while the organization still has
+// events, deleted ones included, since those would go with it. Memberships are
+// removed along with it.
+func (r *OrganizationRepository) DeleteOrganization(id int64) error {
+	var events int64
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM events WHERE organization_id = ?", id).Scan(&events); err != nil {
+		return fmt.Errorf("failed to count events of organization %d: %w", id, err)
+	}
+	if events > 0 {
+		return fmt.Errorf("organization %d has %d events: %w", id, events, ErrInUse)
+	}
+
+	result, err := r.db.Exec("DELETE FROM organizations WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete organization %d: %w", id, err)
+	}
+	return requireAffected(result, fmt.Errorf("no organization %d: %w", id, ErrNotFound))
+}
+
+// GetMembership returns nil when the organization doesn't exist.
+func (r *OrganizationRepository) GetMembership(userID int64, ref string) (*Membership, error) {
+	condition, args := orgRef(ref)
+	var m Membership
+	err := r.db.QueryRow(
+		`SELECT o.id, o.slug, o.name, COALESCE(m.role, '')
+		 FROM organizations o
+		 LEFT JOIN organization_members m ON m.organization_id = o.id AND m.user_id = ?
+		 WHERE `+condition,
+		append([]any{userID}, args...)...,
+	).Scan(&m.OrganizationID, &m.Slug, &m.Name, &m.Role)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get membership of user %d in organization %s: %w", userID, ref, err)
+	}
+	return &m, nil
+}
+
+// GetUserMemberships lists the organizations of a user in the order they
+// joined them, the first one being their default.
+func (r *OrganizationRepository) GetUserMemberships(userID int64) ([]Membership, error) {
+	rows, err := r.db.Query(
+		`SELECT o.id, o.slug, o.name, m.role
+		 FROM organization_members m
+		 JOIN organizations o ON o.id = m.organization_id
+		 WHERE m.user_id = ?
+		 ORDER BY m.joined_at, o.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organizations of user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	memberships := []Membership{}
+	for rows.Next() {
+		var m Membership
+		if err := rows.Scan(&m.OrganizationID, &m.Slug, &m.Name, &m.Role); err != nil {
+			return nil, fmt.Errorf("error scanning membership row: %w", err)
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+func (r *OrganizationRepository) GetMembers(orgID int64) ([]Member, error) {
+	rows, err := r.db.Query(
+		`SELECT u.id, u.username, m.role, m.joined_at
+		 FROM organization_members m
+		 JOIN users u ON u.id = m.user_id
+		 WHERE m.organization_id = ? AND u.deleted_at IS NULL
+		 ORDER BY u.username`,
+		orgID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members of organization %d: %w", orgID, err)
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &m.JoinedAt); err != nil {
+			return nil, fmt.Errorf("error scanning member row: %w", err)
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// AddMember returns ErrAlreadyExists when the user is a member already.
+func (r *OrganizationRepository) AddMember(orgID, userID int64, role string) error {
+	result, err := r.db.Exec(
+		"INSERT INTO organization_members (organization_id, user_id, role) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+		orgID, userID, role,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add user %d to organization %d: %w", userID, orgID, err)
+	}
+	return requireAffected(result, fmt.Errorf("user %d is a member of organization %d: %w", userID, orgID, ErrAlreadyExists))
+}
+
+// UpdateMemberRole returns ErrNotFound when the user isn't a member.
+func (r *OrganizationRepository) UpdateMemberRole(orgID, userID int64, role string) error {
+	result, err := r.db.Exec(
+		"UPDATE organization_members SET role = ? WHERE organization_id = ? AND user_id = ?",
+		role, orgID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to change role of user %d in organization %d: %w", userID, orgID, err)
+	}
+	return requireAffected(result, fmt.Errorf("user %d isn't a member of organization %d: %w", userID, orgID, ErrNotFound))
+}
+
+// RemoveMember returns ErrNotFound when the user isn't a member.
+func (r *OrganizationRepository) RemoveMember(orgID, userID int64) error {
+	result, err := r.db.Exec(
+		"DELETE FROM organization_members WHERE organization_id = ? AND user_id = ?",
+		orgID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove user %d from organization %d: %w", userID, orgID, err)
+	}
+	return requireAffected(result, fmt.Errorf("user %d isn't a member of organization %d: %w", userID, orgID, ErrNotFound))
+}
+
+// GetDefaultOrganization is the organization the access tokens of a user are
+// issued for, the first one they joined. It's 0 for users without any.
+func (r *AuthRepository) GetDefaultOrganization(userID int64) (int64, error) {
+	var orgID int64
+	err := r.db.QueryRow(
+		"SELECT organization_id FROM organization_members WHERE user_id = ? ORDER BY joined_at, organization_id LIMIT 1",
+		userID,
+	).Scan(&orgID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get default organization of user %d: %w", userID, err)
+	}
+	return orgID, nil
+}
@@ -0,0 +1,135 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"time"
+)
+
+// ChangePassword sets a new password for the user and ends all of their
+// sessions, so whoever knew the old password is logged out. A password that
+// breaks the policy gets a *helpers.PasswordPolicyError.
+func (r *AuthRepository) ChangePassword(userID int64, password string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start changing password of user id %d: %w", userID, err)
+	}
+	defer tx.Rollback()
+
+	if err := setPassword(tx, userID, password); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit password of user id %d: %w", userID, err)
+	}
+	return nil
+}
+
+// CreatePasswordResetToken stores a reset token for the user, replacing any
+// earlier one that wasn't used yet.
+func (r *AuthRepository) CreatePasswordResetToken(userID int64, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start creating password reset token: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM password_reset_tokens WHERE user_id = ? AND used_at IS NULL", userID); err != nil {
+		return fmt.Errorf("failed to delete earlier password reset tokens: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, created_at)
+		 VALUES (?, ?, ?, CURRENT_TIMESTAMP)`,
+		userID, tokenHash, expiresAt.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset token for user id %d: %w", userID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit password reset token: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword uses up a reset token to set a new password, ending all the
+// sessions of its user. ErrNotFound is returned for unknown, used or expired
+// tokens, and the token stays usable when the password breaks the policy.
+func (r *AuthRepository) ResetPassword(tokenHash, password string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start resetting password: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRow(
+		`UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP
+		 WHERE token_hash = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		 AND user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)
+		 RETURNING user_id`,
+		tokenHash,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("password reset token is invalid or expired: %w", ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to use password reset token: %w", err)
+	}
+
+	if err := setPassword(tx, userID, password); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit password reset: %w", err)
+	}
+	return nil
+}
+
+func setPassword(tx *sql.Tx, userID int64, password string) error {
+	var username string
+	err := tx.QueryRow("SELECT username FROM users WHERE id = ? AND deleted_at IS NULL", userID).Scan(&username)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user id %d: %w", userID, err)
+	}
+
+	if err := helpers.CheckPassword(username, password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := helpers.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE users SET password_hash = ? WHERE id = ?", hashedPassword, userID); err != nil {
+		return fmt.Errorf("failed to update password of user id %d: %w", userID, err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM password_reset_tokens WHERE user_id = ? AND used_at IS NULL", userID); err != nil {
+		return fmt.Errorf("failed to delete pending password reset tokens: %w", err)
+	}
+
+	return revokeFamilies(tx, "family_id IN (SELECT family_id FROM sessions WHERE user_id = ?)", userID)
+}
+
+// RehashPassword swaps the stored hash for one of the same password made with
+// the current hashing settings. Nothing happens if the password was changed
+// since oldHash was read.
+func (r *AuthRepository) RehashPassword(userID int64, oldHash, newHash string) error {
+	_, err := r.db.Exec(
+		"UPDATE users SET password_hash = ? WHERE id = ? AND password_hash = ?",
+		newHash, userID, oldHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to rehash password of user id %d: %w", userID, err)
+	}
+	return nil
+}
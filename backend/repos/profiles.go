@@ -0,0 +1,66 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ProfilePatch describes a partial update of the profile fields a user edits
+// themselves, fields left nil are not touched and empty strings clear them.
+type ProfilePatch struct {
+	DisplayName       *string
+	Organization      *string
+	Bio               *string
+	PreferredLanguage *string
+	Timezone          *string
+}
+
+func (r *UserRepository) UpdateProfile(id int64, patch ProfilePatch) error {
+	assignments := []string{}
+	args := []any{}
+	set := func(column string, value *string) {
+		if value != nil {
+			assignments = append(assignments, column+" = ?")
+			args = append(args, *value)
+		}
+	}
+
+	set("display_name", patch.DisplayName)
+	set("organization", patch.Organization)
+	set("bio", patch.Bio)
+	set("preferred_language", patch.PreferredLanguage)
+	set("timezone", patch.Timezone)
+	if len(assignments) == 0 {
+		return nil
+	}
+	args = append(args, id)
+
+	result, err := r.db.Exec(
+		"UPDATE users SET "+strings.Join(assignments, ", ")+" WHERE id = ? AND deleted_at IS NULL"+orgMemberFilter(r.org, "id"),
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update profile of user id %d: %w", id, err)
+	}
+	return requireAffected(result, fmt.Errorf("no user found with id %d: %w", id, ErrNotFound))
+}
+
+// SetAvatar points the user at a stored image, or at none when imageID is nil,
+// and returns the image it replaced so the caller can delete it.
+func (r *UserRepository) SetAvatar(id int64, imageID *int64) (*int64, error) {
+	var previous *int64
+	err := r.db.QueryRow("SELECT avatar_image_id FROM users WHERE id = ? AND deleted_at IS NULL"+orgMemberFilter(r.org, "id"), id).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no user found with id %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get avatar of user id %d: %w", id, err)
+	}
+
+	_, err = r.db.Exec("UPDATE users SET avatar_image_id = ? WHERE id = ? AND deleted_at IS NULL"+orgMemberFilter(r.org, "id"), imageID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set avatar of user id %d: %w", id, err)
+	}
+	return previous, nil
+}
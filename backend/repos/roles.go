@@ -0,0 +1,238 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Role is a named set of permissions users can be given. Built-in roles can't
+// be deleted.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Builtin     bool     `json:"builtin"`
+	Permissions []string `json:"permissions"`
+	Users       int64    `json:"users"`
+}
+
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RoleRepository struct {
+	db *sql.DB
+}
+
+type RoleInterface interface {
+	GetRoles() ([]Role, error)
+	GetRole(name string) (*Role, error)
+	GetPermissions() ([]Permission, error)
+	CreateRole(role Role) error
+	UpdateRole(role Role) error
+	DeleteRole(name string) error
+	RoleHasPermission(role, permission string) (bool, error)
+}
+
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+const roleColumns = `SELECT r.name, r.description, r.builtin,
+	(SELECT COUNT(*) FROM users u WHERE u.role = r.name AND u.deleted_at IS NULL)
+	FROM roles r`
+
+func (r *RoleRepository) GetRoles() ([]Role, error) {
+	rows, err := r.db.Query(roleColumns + " ORDER BY r.builtin DESC, r.name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		role := Role{Permissions: []string{}}
+		if err := rows.Scan(&role.Name, &role.Description, &role.Builtin, &role.Users); err != nil {
+			return nil, fmt.Errorf("error scanning role row: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to retrieve roles: %w", err)
+	}
+
+	permissions, err := r.rolePermissions("")
+	if err != nil {
+		return nil, err
+	}
+	for i := range roles {
+		if granted, ok := permissions[roles[i].Name]; ok {
+			roles[i].Permissions = granted
+		}
+	}
+	return roles, nil
+}
+
+// GetRole returns ErrNotFound when there is no role called name.
+func (r *RoleRepository) GetRole(name string) (*Role, error) {
+	role := Role{Permissions: []string{}}
+	err := r.db.QueryRow(roleColumns+" WHERE r.name = ?", name).
+		Scan(&role.Name, &role.Description, &role.Builtin, &role.Users)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no role named %s: %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role %s: %w", name, err)
+	}
+
+	permissions, err := r.rolePermissions(name)
+	if err != nil {
+		return nil, err
+	}
+	if granted, ok := permissions[name]; ok {
+		role.Permissions = granted
+	}
+	return &role, nil
+}
+
+// rolePermissions maps roles to their permissions, only loading role when set.
+func (r *RoleRepository) rolePermissions(role string) (map[string][]string, error) {
+	rows, err := r.db.Query(
+		"SELECT role, permission FROM role_permissions WHERE ? = '' OR role = ? ORDER BY role, permission",
+		role, role,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := map[string][]string{}
+	for rows.Next() {
+		var name, permission string
+		if err := rows.Scan(&name, &permission); err != nil {
+			return nil, fmt.Errorf("error scanning role permission row: %w", err)
+		}
+		permissions[name] = append(permissions[name], permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (r *RoleRepository) GetPermissions() ([]Permission, error) {
+	rows, err := r.db.Query("SELECT name, description FROM permissions ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := []Permission{}
+	for rows.Next() {
+		var p Permission
+		if err := rows.Scan(&p.Name, &p.Description); err != nil {
+			return nil, fmt.Errorf("error scanning permission row: %w", err)
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, rows.Err()
+}
+
+// CreateRole returns ErrAlreadyExists when the name is taken.
+func (r *RoleRepository) CreateRole(role Role) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start creating role: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO roles (name, description) VALUES (?, ?) ON CONFLICT (name) DO NOTHING",
+		role.Name, role.Description,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create role %s: %w", role.Name, err)
+	}
+	if err := requireAffected(result, fmt.Errorf("role %s: %w", role.Name, ErrAlreadyExists)); err != nil {
+		return err
+	}
+
+	if err := insertRolePermissions(tx, role.Name, role.Permissions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit role %s: %w", role.Name, err)
+	}
+	return nil
+}
+
+// UpdateRole replaces the description and permissions of a role.
+func (r *RoleRepository) UpdateRole(role Role) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start updating role: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE roles SET description = ? WHERE name = ?", role.Description, role.Name)
+	if err != nil {
+		return fmt.Errorf("failed to update role %s: %w", role.Name, err)
+	}
+	if err := requireAffected(result, fmt.Errorf("no role named %s: %w", role.Name, ErrNotFound)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = ?", role.Name); err != nil {
+		return fmt.Errorf("failed to clear permissions of role %s: %w", role.Name, err)
+	}
+	if err := insertRolePermissions(tx, role.Name, role.Permissions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit role %s: %w", role.Name, err)
+	}
+	return nil
+}
+
+func insertRolePermissions(tx *sql.Tx, role string, permissions []string) error {
+	for _, permission := range permissions {
+		_, err := tx.Exec("INSERT OR IGNORE INTO role_permissions (role, permission) VALUES (?, ?)", role, permission)
+		if err != nil {
+			return fmt.Errorf("failed to grant %s to role %s: %w", permission, role, err)
+		}
+	}
+	return nil
+}
+
+// DeleteRole returns ErrInUse while any user, deleted ones included since
+// they can be restored, still has the role, globally or in an organization.
+func (r *RoleRepository) DeleteRole(name string) error {
+	var users int64
+	err := r.db.QueryRow(
+		"SELECT (SELECT COUNT(*) FROM users WHERE role = ?) + (SELECT COUNT(*) FROM organization_members WHERE role = ?)",
+		name, name,
+	).Scan(&users)
+	if err != nil {
+		return fmt.Errorf("failed to count users of role %s: %w", name, err)
+	}
+	if users > 0 {
+		return fmt.Errorf("role %s is given to %d users: %w", name, users, ErrInUse)
+	}
+
+	result, err := r.db.Exec("DELETE FROM roles WHERE name = ? AND builtin = 0", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete role %s: %w", name, err)
+	}
+	return requireAffected(result, fmt.Errorf("no role named %s: %w", name, ErrNotFound))
+}
+
+func (r *RoleRepository) RoleHasPermission(role, permission string) (bool, error) {
+	var granted bool
+	err := r.db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM role_permissions WHERE role = ? AND permission = ?)",
+		role, permission,
+	).Scan(&granted)
+	if err != nil {
+		return false, fmt.Errorf("failed to check permission %s of role %s: %w", permission, role, err)
+	}
+	return granted, nil
+}
@@ -0,0 +1,131 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Session is one login of a user, lasting as long as its refresh token
+// family. Current marks the session the request was made with.
+type Session struct {
+	ID         int64  `json:"id"`
+	UserID     int64  `json:"userId"`
+	FamilyID   string `json:"-"`
+	Device     string `json:"device"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"userAgent"`
+	CreatedAt  string `json:"createdAt"`
+	LastSeenAt string `json:"lastSeenAt"`
+	Current    bool   `json:"current"`
+}
+
+// CreateSession starts a session with its first refresh token.
+func (r *AuthRepository) CreateSession(s Session, t RefreshToken) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start creating session: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`INSERT INTO sessions (user_id, family_id, device, ip, user_agent, created_at, last_seen_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?)`,
+		s.UserID, t.FamilyID, s.Device, s.IP, s.UserAgent, t.ExpiresAt.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create session for user id %d: %w", s.UserID, err)
+	}
+
+	sessionID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get session id: %w", err)
+	}
+
+	if err := insertRefreshToken(tx, t); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit session for user id %d: %w", s.UserID, err)
+	}
+	return sessionID, nil
+}
+
+// GetUserSessions lists the sessions of a user that are still active, most
+// recently seen first. currentTokenID is the access token of the request,
+// used to tell which session is the current one.
+func (r *AuthRepository) GetUserSessions(userID int64, currentTokenID string) ([]Session, error) {
+	rows, err := r.db.Query(
+		`SELECT s.id, s.user_id, s.family_id, s.device, s.ip, s.user_agent, s.created_at, s.last_seen_at,
+		        EXISTS (SELECT 1 FROM refresh_tokens t WHERE t.family_id = s.family_id AND t.access_token_id = ?)
+		 FROM sessions s
+		 WHERE s.user_id = ? AND s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP
+		 ORDER BY s.last_seen_at DESC, s.id DESC`,
+		currentTokenID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sessions of user id %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.FamilyID, &s.Device, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt, &s.Current); err != nil {
+			return nil, fmt.Errorf("error scanning session row: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession ends one active session of the user, ErrNotFound when the user
+// has no such session.
+func (r *AuthRepository) RevokeSession(userID, sessionID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start revoking session id %d: %w", sessionID, err)
+	}
+	defer tx.Rollback()
+
+	var familyID string
+	err = tx.QueryRow(
+		"SELECT family_id FROM sessions WHERE id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP",
+		sessionID, userID,
+	).Scan(&familyID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no session %d found for user id %d: %w", sessionID, userID, ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get session id %d: %w", sessionID, err)
+	}
+
+	if err := revokeFamilies(tx, "family_id = ?", familyID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit revocation of session id %d: %w", sessionID, err)
+	}
+	return nil
+}
+
+// RevokeUserSessions ends every session of the user, logging them out
+// everywhere once their current access tokens are rejected.
+func (r *AuthRepository) RevokeUserSessions(userID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start revoking sessions of user id %d: %w", userID, err)
+	}
+	defer tx.Rollback()
+
+	if err := revokeFamilies(tx, "family_id IN (SELECT family_id FROM sessions WHERE user_id = ?)", userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit revocation of sessions of user id %d: %w", userID, err)
+	}
+	return nil
+}
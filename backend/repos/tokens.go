@@ -0,0 +1,240 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RefreshToken is one link of a refresh token family. Every refresh replaces
+// the token with a new one in the same family, and a token presented after it
+// was replaced means it leaked, so the whole family gets revoked.
+type RefreshToken struct {
+	ID              int64
+	UserID          int64
+	Username        string
+	Role            string
+	FamilyID        string
+	TokenHash       string
+	AccessTokenID   string
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	Used            bool
+	Revoked         bool
+	Expired         bool
+}
+
+func insertRefreshToken(db execer, t RefreshToken) error {
+	_, err := db.Exec(
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, access_token_id, access_expires_at, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		t.UserID, t.FamilyID, t.TokenHash, t.AccessTokenID,
+		t.AccessExpiresAt.UTC().Format(time.DateTime), t.ExpiresAt.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token for user id %d: %w", t.UserID, err)
+	}
+	return nil
+}
+
+// GetRefreshToken looks a token up by its hash, returning nil when it is
+// unknown or its user was deleted.
+func (r *AuthRepository) GetRefreshToken(tokenHash string) (*RefreshToken, error) {
+	var t RefreshToken
+	err := r.db.QueryRow(
+		`SELECT t.id, t.user_id, u.username, u.role, t.family_id, t.token_hash, t.access_token_id,
+		        t.used_at IS NOT NULL, t.revoked_at IS NOT NULL, t.expires_at <= CURRENT_TIMESTAMP
+		 FROM refresh_tokens t
+		 JOIN users u ON u.id = t.user_id
+		 WHERE t.token_hash = ? AND u.deleted_at IS NULL`,
+		tokenHash,
+	).Scan(&t.ID, &t.UserID, &t.Username, &t.Role, &t.FamilyID, &t.TokenHash, &t.AccessTokenID, &t.Used, &t.Revoked, &t.Expired)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return &t, nil
+}
+
+// RotateRefreshToken marks the token as used and stores next in its place,
+// recording ip as where the session was last seen. ErrTokenReused is returned
+// when the token was used, revoked or expired in the meantime.
+func (r *AuthRepository) RotateRefreshToken(tokenHash string, next RefreshToken, ip string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start rotating refresh token: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP
+		 WHERE token_hash = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP`,
+		tokenHash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark refresh token as used: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("couldn't verify refresh token rotation result: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("refresh token can't be used again: %w", ErrTokenReused)
+	}
+
+	if err := insertRefreshToken(tx, next); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP, ip = ?, expires_at = ? WHERE family_id = ?",
+		ip, next.ExpiresAt.UTC().Format(time.DateTime), next.FamilyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit refresh token rotation: %w", err)
+	}
+	return nil
+}
+
+// RevokeTokenFamily revokes every refresh token of the family along with the
+// access tokens that were issued with them and haven't expired yet, ending
+// the session they belong to.
+func (r *AuthRepository) RevokeTokenFamily(familyID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start revoking token family: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := revokeFamilies(tx, "family_id = ?", familyID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit token family revocation: %w", err)
+	}
+	return nil
+}
+
+// Logout revokes the access token and the refresh token family it was issued
+// with, if any.
+func (r *AuthRepository) Logout(tokenID string, expiresAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start logout: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT OR IGNORE INTO revoked_tokens (token_id, expires_at) VALUES (?, ?)",
+		tokenID, expiresAt.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	if err := revokeFamilies(tx, "family_id IN (SELECT family_id FROM refresh_tokens WHERE access_token_id = ?)", tokenID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit logout: %w", err)
+	}
+	return nil
+}
+
+func revokeFamilies(tx *sql.Tx, where string, args ...any) error {
+	_, err := tx.Exec(
+		`INSERT OR IGNORE INTO revoked_tokens (token_id, expires_at)
+		 SELECT access_token_id, access_expires_at FROM refresh_tokens
+		 WHERE `+where+` AND access_expires_at > CURRENT_TIMESTAMP`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+		 WHERE `+where+` AND revoked_at IS NULL`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+		 WHERE `+where+` AND revoked_at IS NULL`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// RevokeToken denylists a single token id until it expires.
+func (r *AuthRepository) RevokeToken(tokenID string, expiresAt time.Time) error {
+	_, err := r.db.Exec(
+		"INSERT OR IGNORE INTO revoked_tokens (token_id, expires_at) VALUES (?, ?)",
+		tokenID, expiresAt.UTC().Format(time.DateTime),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+func (r *AuthRepository) IsTokenRevoked(tokenID string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE token_id = ?)", tokenID).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+	return revoked, nil
+}
+
+// DeleteExpiredTokens drops refresh tokens and revocations that would be
+// rejected for having expired anyway.
+func (r *AuthRepository) DeleteExpiredTokens() (int64, error) {
+	refreshed, err := r.db.Exec("DELETE FROM refresh_tokens WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+	refreshTokens, err := refreshed.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("couldn't verify refresh token deletion result: %w", err)
+	}
+
+	if _, err := r.db.Exec("DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP"); err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	if _, err := r.db.Exec("DELETE FROM password_reset_tokens WHERE expires_at <= CURRENT_TIMESTAMP"); err != nil {
+		return 0, fmt.Errorf("failed to delete expired password reset tokens: %w", err)
+	}
+	if _, err := r.db.Exec("DELETE FROM email_verification_tokens WHERE expires_at <= CURRENT_TIMESTAMP"); err != nil {
+		return 0, fmt.Errorf("failed to delete expired email verification tokens: %w", err)
+	}
+	if _, err := r.db.Exec("DELETE FROM oidc_logins WHERE expires_at <= CURRENT_TIMESTAMP"); err != nil {
+		return 0, fmt.Errorf("failed to delete expired single sign-on logins: %w", err)
+	}
+
+	revoked, err := r.db.Exec("DELETE FROM revoked_tokens WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired token revocations: %w", err)
+	}
+	revokedTokens, err := revoked.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("couldn't verify token revocation deletion result: %w", err)
+	}
+
+	return refreshTokens + revokedTokens, nil
+}
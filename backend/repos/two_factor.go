@@ -0,0 +1,246 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TwoFactor is the TOTP state of a user. PendingSecret is a secret handed out
+// by enrollment that becomes Secret once a code from it was confirmed.
+type TwoFactor struct {
+	Enabled           bool
+	Secret            string
+	PendingSecret     string
+	LastStep          int64
+	RecoveryCodesLeft int
+}
+
+const twoFactorRequiredRolesSetting = "two_factor_required_roles"
+
+func (r *AuthRepository) GetAuthUserById(id int64) (*AuthUser, error) {
+	var u AuthUser
+	err := r.db.QueryRow(
+		"SELECT id, username, role, created_at, password_hash FROM users WHERE id = ? AND deleted_at IS NULL",
+		id,
+	).Scan(&u.ID, &u.Username, &u.Role, &u.CreatedAt, &u.PasswordHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	return &u, nil
+}
+
+func (r *AuthRepository) GetTwoFactor(userID int64) (*TwoFactor, error) {
+	var tf TwoFactor
+	var secret, pendingSecret sql.NullString
+	err := r.db.QueryRow(
+		`SELECT totp_enabled, totp_secret, totp_pending_secret, totp_last_step,
+		        (SELECT COUNT(*) FROM recovery_codes WHERE user_id = users.id AND used_at IS NULL)
+		 FROM users WHERE id = ? AND deleted_at IS NULL`,
+		userID,
+	).Scan(&tf.Enabled, &secret, &pendingSecret, &tf.LastStep, &tf.RecoveryCodesLeft)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor state of user id %d: %w", userID, err)
+	}
+
+	tf.Secret = secret.String
+	tf.PendingSecret = pendingSecret.String
+	return &tf, nil
+}
+
+// SetPendingTOTPSecret starts an enrollment, replacing any unconfirmed one.
+func (r *AuthRepository) SetPendingTOTPSecret(userID int64, secret string) error {
+	result, err := r.db.Exec(
+		"UPDATE users SET totp_pending_secret = ? WHERE id = ? AND deleted_at IS NULL",
+		secret, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store totp secret of user id %d: %w", userID, err)
+	}
+	return requireAffected(result, fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound))
+}
+
+// EnableTwoFactor turns the pending secret into the active one, step being the
+// step of the code that confirmed it, and replaces the recovery codes.
+func (r *AuthRepository) EnableTwoFactor(userID int64, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start enabling two-factor authentication: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE users SET totp_secret = totp_pending_secret, totp_pending_secret = NULL, totp_enabled = 1, totp_last_step = ?
+		 WHERE id = ? AND totp_pending_secret IS NOT NULL AND deleted_at IS NULL`,
+		step, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication of user id %d: %w", userID, err)
+	}
+	if err := requireAffected(result, fmt.Errorf("no pending enrollment for user id %d: %w", userID, ErrNotFound)); err != nil {
+		return err
+	}
+
+	if err := insertRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit two-factor authentication of user id %d: %w", userID, err)
+	}
+	return nil
+}
+
+// DisableTwoFactor removes the secret and the recovery codes of the user.
+func (r *AuthRepository) DisableTwoFactor(userID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start disabling two-factor authentication: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE users SET totp_secret = NULL, totp_pending_secret = NULL, totp_enabled = 0, totp_last_step = 0
+		 WHERE id = ? AND deleted_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication of user id %d: %w", userID, err)
+	}
+	if err := requireAffected(result, fmt.Errorf("no user found with id %d: %w", userID, ErrNotFound)); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes of user id %d: %w", userID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit disabling two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+// UseTOTPStep records that the code of step was used, reporting false when
+// that step or a later one was used already so a code works only once.
+func (r *AuthRepository) UseTOTPStep(userID int64, step int64) (bool, error) {
+	result, err := r.db.Exec(
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ? AND totp_enabled = 1",
+		step, userID, step,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record totp step of user id %d: %w", userID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("couldn't verify totp step result: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// UseRecoveryCode spends one recovery code, reporting false when the user has
+// no such unused code.
+func (r *AuthRepository) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	result, err := r.db.Exec(
+		"UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		userID, codeHash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code of user id %d: %w", userID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("couldn't verify recovery code result: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func (r *AuthRepository) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start replacing recovery codes: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit recovery codes of user id %d: %w", userID, err)
+	}
+	return nil
+}
+
+func insertRecoveryCodes(tx *sql.Tx, userID int64, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes of user id %d: %w", userID, err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash); err != nil {
+			return fmt.Errorf("failed to create recovery code for user id %d: %w", userID, err)
+		}
+	}
+	return nil
+}
+
+// GetTwoFactorRequiredRoles lists the roles that can't log in without a
+// second factor.
+func (r *AuthRepository) GetTwoFactorRequiredRoles() ([]string, error) {
+	var value string
+	err := r.db.QueryRow("SELECT value FROM settings WHERE key = ?", twoFactorRequiredRolesSetting).Scan(&value)
+	if err == sql.ErrNoRows {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor required roles: %w", err)
+	}
+
+	roles := []string{}
+	for _, role := range strings.Split(value, ",") {
+		if role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+func (r *AuthRepository) SetTwoFactorRequiredRoles(roles []string) error {
+	_, err := r.db.Exec(
+		"INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value",
+		twoFactorRequiredRolesSetting, strings.Join(roles, ","),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set two-factor required roles: %w", err)
+	}
+	return nil
+}
+
+// IsTwoFactorRequired reports whether users with role need a second factor.
+func (r *AuthRepository) IsTwoFactorRequired(role string) (bool, error) {
+	roles, err := r.GetTwoFactorRequiredRoles()
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(roles, role), nil
+}
+
+func requireAffected(result sql.Result, notFound error) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("couldn't verify update result: %w", err)
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
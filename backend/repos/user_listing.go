@@ -0,0 +1,107 @@
+package repos
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UserSortColumns are the fields a user listing can be sorted by.
+var UserSortColumns = map[string]string{
+	"id":        "id",
+	"username":  "username",
+	"role":      "role",
+	"tickets":   "tickets",
+	"createdAt": "created_at",
+}
+
+// UserQuery narrows down and orders a page of users. Filters left at their
+// zero value aren't applied, Sort is one of UserSortColumns and defaults to id.
+type UserQuery struct {
+	// Search matches the start of the username or the email address
+	Search        string
+	Role          string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	MinTickets    *int64
+	MaxTickets    *int64
+	Sort          string
+	Descending    bool
+	Limit         int
+	Offset        int
+}
+
+// ListUsers returns one page of the users matching q along with how many
+// match in total.
+func (r *UserRepository) ListUsers(q UserQuery) ([]User, int, error) {
+	where := []string{"deleted_at IS NULL"}
+	args := []any{}
+	if q.Search != "" {
+		prefix := escapeLike(q.Search) + "%"
+		where = append(where, `(username LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\')`)
+		args = append(args, prefix, prefix)
+	}
+	if q.Role != "" {
+		where = append(where, "role = ?")
+		args = append(args, q.Role)
+	}
+	if q.CreatedAfter != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, q.CreatedAfter.UTC().Format(time.DateTime))
+	}
+	if q.CreatedBefore != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, q.CreatedBefore.UTC().Format(time.DateTime))
+	}
+	if q.MinTickets != nil {
+		where = append(where, "tickets >= ?")
+		args = append(args, *q.MinTickets)
+	}
+	if q.MaxTickets != nil {
+		where = append(where, "tickets <= ?")
+		args = append(args, *q.MaxTickets)
+	}
+	filter := " WHERE " + strings.Join(where, " AND ") + orgMemberFilter(r.org, "id")
+
+	var count int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM users"+filter, args...).Scan(&count); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	column, ok := UserSortColumns[q.Sort]
+	if !ok {
+		column = "id"
+	}
+	direction := "ASC"
+	if q.Descending {
+		direction = "DESC"
+	}
+	order := fmt.Sprintf(" ORDER BY %s %s", column, direction)
+	if column != "id" {
+		// Ties are broken by id so pages don't overlap
+		order += ", id " + direction
+	}
+
+	rows, err := r.db.Query("SELECT "+userColumns+" FROM users"+filter+order+" LIMIT ? OFFSET ?", append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error scanning user row: %w", err)
+		}
+		users = append(users, *u)
+	}
+
+	return users, count, rows.Err()
+}
+
+// escapeLike makes the LIKE wildcards in s match themselves, for patterns
+// using ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -4,36 +4,85 @@ import (
 	"database/sql"
 	"fmt"
 	"immodi/submission-backend/helpers"
+	"time"
 )
 
 type User struct {
-	ID        int64  `json:"id"`
-	Username  string `json:"username"`
-	CreatedAt string `json:"createdAt"`
-	Tickets   int64  `json:"tickets"`
-	Role      string `json:"role"`
+	ID          int64   `json:"id"`
+	Username    string  `json:"username"`
+	DisplayName string  `json:"displayName"`
+	Email       *string `json:"email"`
+	// EmailVerified is only true for the address the user has now
+	EmailVerified bool `json:"emailVerified"`
+	// Organization is the company or affiliation the user fills in on their
+	// profile, unrelated to the organizations they are a member of
+	Organization      string  `json:"organization"`
+	Bio               string  `json:"bio"`
+	PreferredLanguage string  `json:"preferredLanguage"`
+	Timezone          string  `json:"timezone"`
+	AvatarImageID     *int64  `json:"-"`
+	AvatarURL         string  `json:"avatarUrl,omitempty"`
+	CreatedAt         string  `json:"createdAt"`
+	Tickets           int64   `json:"tickets"`
+	Role              string  `json:"role"`
+	DeletedAt         *string `json:"deletedAt,omitempty"`
 }
 
+const userColumns = "id, username, role, tickets, created_at, display_name, email, email_verified_at, " +
+	"organization, bio, preferred_language, timezone, avatar_image_id, (SELECT hash FROM images WHERE images.id = users.avatar_image_id)"
+
+func scanUser(row rowScanner, extra ...any) (*User, error) {
+	var u User
+	var verifiedAt, avatarHash *string
+	dest := append([]any{
+		&u.ID, &u.Username, &u.Role, &u.Tickets, &u.CreatedAt, &u.DisplayName, &u.Email, &verifiedAt,
+		&u.Organization, &u.Bio, &u.PreferredLanguage, &u.Timezone, &u.AvatarImageID, &avatarHash,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	u.EmailVerified = verifiedAt != nil
+	if avatarHash != nil {
+		u.AvatarURL = fmt.Sprintf("/users/%d/avatar?v=%s", u.ID, ImageVersion(*avatarHash))
+	}
+	return &u, nil
+}
+
+// UserRepository sees every user, or only the members of one organization
+// once scoped with InOrganization.
 type UserRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	org *int64
 }
 
 type UserInterface interface {
 	GetAllUsers() ([]User, error)
-	CreateUser(username, password string) (int64, error)
+	ListUsers(q UserQuery) ([]User, int, error)
+	CreateUser(username, password, email, displayName string) (int64, error)
 	GetUserById(id int64) (*User, error)
 	GetUserByUsername(username string) (*User, error)
 	UpdateUserRole(id int64, role string) error
 	RemoveOneTicketFromUser(id int64) error
 	DeleteUser(id int64) error
+	GetDeletedUsers() ([]User, error)
+	RestoreUser(id int64) error
+	PurgeDeletedUsers(deletedBefore time.Time) (int64, error)
+	UpdateProfile(id int64, patch ProfilePatch) error
+	SetAvatar(id int64, imageID *int64) (*int64, error)
 }
 
 func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// InOrganization returns a copy of the repository that only finds and changes
+// the members of orgID. Usernames stay unique across organizations.
+func (r *UserRepository) InOrganization(orgID int64) *UserRepository {
+	return &UserRepository{db: r.db, org: &orgID}
+}
+
 func (r *UserRepository) GetAllUsers() ([]User, error) {
-	rows, err := r.db.Query("SELECT id, username, role, tickets, created_at FROM users")
+	rows, err := r.db.Query("SELECT " + userColumns + " FROM users WHERE deleted_at IS NULL" + orgMemberFilter(r.org, "id"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve users: %w", err)
 	}
@@ -41,30 +90,53 @@ func (r *UserRepository) GetAllUsers() ([]User, error) {
 
 	users := []User{}
 	for rows.Next() {
-		var u User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Role, &u.Tickets, &u.CreatedAt); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, fmt.Errorf("error scanning user row: %w", err)
 		}
-		users = append(users, u)
+		users = append(users, *u)
 	}
 
 	return users, rows.Err()
 }
 
-func (r *UserRepository) CreateUser(username, password string) (int64, error) {
-	// Check for existing user
-	if existing, _ := r.GetUserByUsername(username); existing != nil {
+// CreateUser adds a user with an optional email address, which has to be
+// normalized already and is left unverified.
+func (r *UserRepository) CreateUser(username, password, email, displayName string) (int64, error) {
+	// Check for existing user, in any organization. Deleted users keep their
+	// username so they can be restored, so those count as well
+	var exists bool
+	if err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE username = ?)", username).Scan(&exists); err != nil {
+		return 500, fmt.Errorf("failed to check username: %w", err)
+	}
+	if exists {
 		return 400, fmt.Errorf("user '%s' already exists", username)
 	}
 
+	var emailValue *string
+	if email != "" {
+		var taken bool
+		if err := r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE email = ?)", email).Scan(&taken); err != nil {
+			return 500, fmt.Errorf("failed to check email: %w", err)
+		}
+		if taken {
+			return 400, fmt.Errorf("email '%s' is already in use", email)
+		}
+		emailValue = &email
+	}
+
+	if err := helpers.CheckPassword(username, password); err != nil {
+		return 400, err
+	}
+
 	hashedPassword, err := helpers.HashPassword(password)
 	if err != nil {
 		return 500, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	result, err := r.db.Exec(
-		"INSERT INTO users (username, password_hash) VALUES (?, ?)",
-		username, hashedPassword,
+		"INSERT INTO users (username, password_hash, email, display_name) VALUES (?, ?, ?, ?)",
+		username, hashedPassword, emailValue, displayName,
 	)
 	if err != nil {
 		return 500, fmt.Errorf("failed to create user: %w", err)
@@ -74,11 +146,10 @@ func (r *UserRepository) CreateUser(username, password string) (int64, error) {
 }
 
 func (r *UserRepository) GetUserByUsername(username string) (*User, error) {
-	var u User
-	err := r.db.QueryRow(
-		"SELECT id, username, role, tickets, created_at FROM users WHERE username = ?",
+	u, err := scanUser(r.db.QueryRow(
+		"SELECT "+userColumns+" FROM users WHERE username = ? AND deleted_at IS NULL"+orgMemberFilter(r.org, "id"),
 		username,
-	).Scan(&u.ID, &u.Username, &u.Role, &u.Tickets, &u.CreatedAt)
+	))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -87,15 +158,14 @@ func (r *UserRepository) GetUserByUsername(username string) (*User, error) {
 		return nil, fmt.Errorf("failed to get user by username: %w", err)
 	}
 
-	return &u, nil
+	return u, nil
 }
 
 func (r *UserRepository) GetUserById(id int64) (*User, error) {
-	var u User
-	err := r.db.QueryRow(
-		"SELECT id, username, role, tickets, created_at FROM users WHERE id = ?",
+	u, err := scanUser(r.db.QueryRow(
+		"SELECT "+userColumns+" FROM users WHERE id = ? AND deleted_at IS NULL"+orgMemberFilter(r.org, "id"),
 		id,
-	).Scan(&u.ID, &u.Username, &u.Role, &u.Tickets, &u.CreatedAt)
+	))
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -104,11 +174,11 @@ func (r *UserRepository) GetUserById(id int64) (*User, error) {
 		return nil, fmt.Errorf("failed to get user by id: %w", err)
 	}
 
-	return &u, nil
+	return u, nil
 }
 
 func (r *UserRepository) DeleteUser(id int64) error {
-	result, err := r.db.Exec("DELETE FROM users WHERE id = ?", id)
+	result, err := r.db.Exec("UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL"+orgMemberFilter(r.org, "id"), id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user id %d: %w", id, err)
 	}
@@ -118,52 +188,82 @@ func (r *UserRepository) DeleteUser(id int64) error {
 		return fmt.Errorf("couldn't verify deletion result: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("no user found with id %d", id)
+		return fmt.Errorf("no user found with id %d: %w", id, ErrNotFound)
 	}
 
 	return nil
 }
 
-func (r *UserRepository) UpdateUserRole(id int64, role string) error {
-	_, err := r.db.Exec(
-		"UPDATE users SET role = ? WHERE id = ?",
-		role, id,
+func (r *UserRepository) GetDeletedUsers() ([]User, error) {
+	rows, err := r.db.Query(
+		`SELECT ` + userColumns + `, deleted_at
+		 FROM users
+		 WHERE deleted_at IS NOT NULL` + orgMemberFilter(r.org, "id") + `
+		 ORDER BY deleted_at DESC`,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update user id %d: %w", id, err)
+		return nil, fmt.Errorf("failed to retrieve deleted users: %w", err)
 	}
-	return nil
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var deletedAt *string
+		u, err := scanUser(rows, &deletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning deleted user row: %w", err)
+		}
+		u.DeletedAt = deletedAt
+		users = append(users, *u)
+	}
+
+	return users, rows.Err()
 }
 
-func (r *UserRepository) IsAdmin(username string) bool {
-	user, err := r.GetUserByUsername(username)
+func (r *UserRepository) RestoreUser(id int64) error {
+	result, err := r.db.Exec("UPDATE users SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL"+orgMemberFilter(r.org, "id"), id)
 	if err != nil {
-		return false
+		return fmt.Errorf("failed to restore user id %d: %w", id, err)
 	}
 
-	if user == nil {
-		return false
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("couldn't verify restore result: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no deleted user found with id %d: %w", id, ErrNotFound)
 	}
-	return user.Role == "admin"
-}
 
-func (r *UserRepository) IsSameUser(username string, resourceOwnerId int64) bool {
-	user, err := r.GetUserById(resourceOwnerId)
+	return nil
+}
 
+// PurgeDeletedUsers hard-deletes users soft-deleted before the given time,
+// their registrations go with them through the foreign key cascade.
+func (r *UserRepository) PurgeDeletedUsers(deletedBefore time.Time) (int64, error) {
+	result, err := r.db.Exec(
+		"DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < ?"+orgMemberFilter(r.org, "id"),
+		deletedBefore.UTC().Format(time.DateTime),
+	)
 	if err != nil {
-		return false
+		return 0, fmt.Errorf("failed to purge deleted users: %w", err)
 	}
+	return result.RowsAffected()
+}
 
-	if user == nil {
-		return false
+func (r *UserRepository) UpdateUserRole(id int64, role string) error {
+	result, err := r.db.Exec(
+		"UPDATE users SET role = ? WHERE id = ? AND deleted_at IS NULL"+orgMemberFilter(r.org, "id"),
+		role, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user id %d: %w", id, err)
 	}
-
-	return user.Username == username
+	return requireAffected(result, fmt.Errorf("no user found with id %d: %w", id, ErrNotFound))
 }
 
 func (r *UserRepository) RemoveOneTicketFromUser(id int64) error {
 	_, err := r.db.Exec(
-		"UPDATE users SET tickets = tickets - 1 WHERE id = ?",
+		"UPDATE users SET tickets = tickets - 1 WHERE id = ? AND deleted_at IS NULL"+orgMemberFilter(r.org, "id"),
 		id,
 	)
 	if err != nil {
@@ -0,0 +1,171 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"immodi/submission-backend/routes/responses"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// apiKeyPrefixLength is how much of a key is kept in the clear, enough to
+// recognize it in the list of keys.
+const apiKeyPrefixLength = len(helpers.APIKeyPrefix) + 6
+
+func writeAPIKeys(w http.ResponseWriter, authRepo repos.AuthInterface, userID int64) {
+	keys, err := authRepo.GetAPIKeys(userID)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the API keys")
+		return
+	}
+
+	res := &responses.APIKeysResponse{
+		Keys:  keys,
+		Count: len(keys),
+	}
+
+	helpers.HttpJson(w, http.StatusOK, res)
+}
+
+func GetOwnAPIKeys(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeAPIKeys(w, authRepo, helpers.GetPrincipal(r).ID)
+	}
+}
+
+// CreateAPIKey hands out a key that acts as the user in the organization they
+// are acting in, limited to the scopes asked for. Users can't give a key
+// permissions they don't have themselves.
+func CreateAPIKey(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.APIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		name := strings.TrimSpace(req.Name)
+		if name == "" || len(name) > 100 {
+			helpers.HttpError(w, http.StatusBadRequest, "name must be between 1 and 100 characters")
+			return
+		}
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			helpers.HttpError(w, http.StatusBadRequest, "expiresAt must be in the future")
+			return
+		}
+
+		scopes, err := checkPermissions(req.Scopes)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		principal := helpers.GetPrincipal(r)
+		for _, scope := range scopes {
+			if !principal.Can(scope) {
+				helpers.HttpError(w, http.StatusForbidden, "you don't have the permission '"+scope+"' yourself")
+				return
+			}
+		}
+
+		key, err := helpers.NewAPIKey()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't create the API key")
+			return
+		}
+
+		apiKey := repos.APIKey{
+			UserID: principal.ID,
+			Name:   name,
+			Prefix: key[:apiKeyPrefixLength],
+			Scopes: scopes,
+		}
+		if principal.OrgID != 0 {
+			apiKey.OrganizationID = &principal.OrgID
+		}
+
+		apiKey.ID, err = authRepo.CreateAPIKey(apiKey, helpers.HashToken(key), req.ExpiresAt)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't create the API key")
+			return
+		}
+
+		apiKey.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+		if req.ExpiresAt != nil {
+			expiresAt := req.ExpiresAt.UTC().Format(time.RFC3339)
+			apiKey.ExpiresAt = &expiresAt
+		}
+
+		res := &responses.APIKeyCreatedResponse{
+			Key:    key,
+			APIKey: apiKey,
+		}
+
+		helpers.HttpJson(w, http.StatusCreated, res)
+	}
+}
+
+func RevokeOwnAPIKey(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keyId, err := strconv.ParseInt(chi.URLParam(r, "keyId"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid API key id, pass a valid one")
+			return
+		}
+
+		err = authRepo.RevokeAPIKey(helpers.GetPrincipal(r).ID, keyId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not revoke the API key")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "the API key was revoked",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func GetUserAPIKeys(userRepo repos.UserInterface, authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := parseSessionsUser(w, r, userRepo)
+		if !ok {
+			return
+		}
+
+		writeAPIKeys(w, authRepo, userId)
+	}
+}
+
+// RevokeUserAPIKeys lets admins cut off every script of a user at once.
+func RevokeUserAPIKeys(userRepo repos.UserInterface, authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := parseSessionsUser(w, r, userRepo)
+		if !ok {
+			return
+		}
+
+		if err := authRepo.RevokeUserAPIKeys(userId); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not revoke the API keys")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "all API keys of the user were revoked",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
@@ -3,19 +3,175 @@ package routes
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/mail"
 	"immodi/submission-backend/repos"
 	"immodi/submission-backend/routes/requests"
 	"immodi/submission-backend/routes/responses"
 	helper_structs "immodi/submission-backend/structs"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func AuthRouter(r chi.Router, db *sql.DB, api *helper_structs.API) {
 	r.Post("/login", Login(api.AuthRepo))
-	r.Post("/register", Register(api.UserRepo))
+	r.Post("/register", Register(api.UserRepo, api.AuthRepo, api.OrgRepo, api.Mailer))
+	r.Post("/refresh", Refresh(api.AuthRepo))
+	r.Post("/logout", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.AccessTokenOnly, Logout(api.AuthRepo))
+	})
+
+	r.Post("/password", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, ChangePassword(api.AuthRepo))
+	})
+	r.Post("/forgot", ForgotPassword(api.UserRepo, api.AuthRepo, api.Mailer))
+	r.Post("/reset", ResetPassword(api.AuthRepo))
+
+	r.Put("/email", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, SetOwnEmail(api.UserRepo, api.AuthRepo, api.Mailer))
+	})
+	r.Post("/email/resend", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, nil, ResendEmailVerification(api.UserRepo, api.AuthRepo, api.Mailer))
+	})
+	r.Post("/email/verify", VerifyEmail(api.AuthRepo))
+
+	r.Get("/lockouts", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermAuthLockouts), GetLoginFailures(api.AuthRepo))
+	})
+	r.Delete("/lockouts", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermAuthLockouts), ClearLoginFailures(api.AuthRepo))
+	})
+
+	r.Get("/2fa", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, GetTwoFactorStatus(api.AuthRepo))
+	})
+	r.Post("/2fa/verify", VerifyTwoFactor(api.AuthRepo))
+	r.Post("/2fa/enroll", func(w http.ResponseWriter, r *http.Request) {
+		challengeOrProtected(w, r, EnrollTwoFactor(api.AuthRepo))
+	})
+	r.Post("/2fa/enable", func(w http.ResponseWriter, r *http.Request) {
+		challengeOrProtected(w, r, EnableTwoFactor(api.AuthRepo))
+	})
+	r.Post("/2fa/disable", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, DisableTwoFactor(api.AuthRepo))
+	})
+	r.Post("/2fa/recovery-codes", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, RegenerateRecoveryCodes(api.AuthRepo))
+	})
+	r.Get("/2fa/required-roles", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermAuthSettings), GetTwoFactorRequiredRoles(api.AuthRepo))
+	})
+	r.Put("/2fa/required-roles", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermAuthSettings), SetTwoFactorRequiredRoles(api.AuthRepo, api.RoleRepo))
+	})
+
+	r.Get("/oidc/login", OIDCLogin(api.AuthRepo, api.SSO))
+	r.Get("/oidc/callback", OIDCCallback(api.UserRepo, api.AuthRepo, api.OrgRepo, api.SSO))
+	r.Post("/oidc/link", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, LinkOIDC(api.AuthRepo, api.SSO))
+	})
+
+	r.Get("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, GetOwnSessions(api.AuthRepo))
+	})
+	r.Delete("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, RevokeOwnSessions(api.AuthRepo))
+	})
+	r.Delete("/sessions/{sessionId}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, RevokeOwnSession(api.AuthRepo))
+	})
+
+	r.Get("/impersonations", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermAuditRead), GetImpersonationLog(api.AuthRepo))
+	})
+
+	r.Get("/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, GetOwnAPIKeys(api.AuthRepo))
+	})
+	r.Post("/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, CreateAPIKey(api.AuthRepo))
+	})
+	r.Delete("/api-keys/{keyId}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, RevokeOwnAPIKey(api.AuthRepo))
+	})
+}
+
+// newTokenPair issues an access token for the default organization of the
+// user and a refresh token in familyID, which starts a new family when empty.
+func newTokenPair(authRepo repos.AuthInterface, userID int64, username, role, familyID string) (*responses.AuthResponse, *repos.RefreshToken, error) {
+	orgID, err := authRepo.GetDefaultOrganization(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access, err := helpers.NewAccessToken(userID, username, role, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, err := helpers.RandomToken(32)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if familyID == "" {
+		familyID, err = helpers.RandomToken(16)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	stored := &repos.RefreshToken{
+		UserID:          userID,
+		FamilyID:        familyID,
+		TokenHash:       helpers.HashToken(refreshToken),
+		AccessTokenID:   access.ID,
+		AccessExpiresAt: access.ExpiresAt,
+		ExpiresAt:       time.Now().Add(helpers.RefreshTokenTTL()),
+	}
+
+	res := &responses.AuthResponse{
+		Token:        access.Token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(time.Until(access.ExpiresAt).Seconds()),
+	}
+
+	return res, stored, nil
+}
+
+// createSession starts a new session for the user, remembering where the
+// request came from so the user can recognize it later.
+func createSession(r *http.Request, authRepo repos.AuthInterface, user *repos.AuthUser) (*responses.AuthResponse, error) {
+	res, stored, err := newTokenPair(authRepo, user.ID, user.Username, user.Role, "")
+	if err != nil {
+		return nil, err
+	}
+
+	session := repos.Session{
+		UserID:    user.ID,
+		Device:    helpers.DescribeDevice(r.UserAgent()),
+		IP:        helpers.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	if _, err := authRepo.CreateSession(session, *stored); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func issueTokens(w http.ResponseWriter, r *http.Request, authRepo repos.AuthInterface, user *repos.AuthUser) {
+	res, err := createSession(r, authRepo, user)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+
+	helpers.HttpJson(w, http.StatusCreated, res)
 }
 
 func Login(authRepo repos.AuthInterface) http.HandlerFunc {
@@ -30,65 +186,186 @@ func Login(authRepo repos.AuthInterface) http.HandlerFunc {
 			return
 		}
 
+		ip := helpers.ClientIP(r)
+		if loginLockedOut(w, authRepo, req.Username, ip) {
+			return
+		}
+
 		user, err := authRepo.GetAuthUserByUsername(req.Username)
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "failed to get user")
 			return
 		}
+
+		// Unknown users and wrong passwords look the same, so usernames can't be probed
 		if user == nil {
-			helpers.HttpError(w, http.StatusUnauthorized, "user not found")
+			checkDummyPassword(req.Password)
+		}
+		if user == nil || !helpers.CheckPasswordHash(req.Password, user.PasswordHash) {
+			recordLoginFailure(authRepo, req.Username, ip)
+			helpers.HttpError(w, http.StatusUnauthorized, invalidCredentials)
 			return
 		}
 
-		isValidPassword := helpers.CheckPasswordHash(req.Password, user.PasswordHash)
+		if _, err := authRepo.ClearLoginFailures(repos.LoginFailureUsername, user.Username); err != nil {
+			log.Printf("Failed to clear login failures of user %d: %v", user.ID, err)
+		}
+
+		// The password is only ever known here, so this is when hashes made with
+		// outdated settings get upgraded
+		if helpers.PasswordNeedsRehash(user.PasswordHash) {
+			rehashPassword(authRepo, user, req.Password)
+		}
 
-		if !isValidPassword {
-			helpers.HttpError(w, http.StatusUnauthorized, "invalid password")
+		issueTokensOrChallenge(w, r, authRepo, user)
+	}
+}
+
+// issueTokensOrChallenge finishes a login whose password checked out, users
+// that have to pass or set up a second factor get a challenge instead.
+func issueTokensOrChallenge(w http.ResponseWriter, r *http.Request, authRepo repos.AuthInterface, user *repos.AuthUser) {
+	challenge, err := secondFactorChallenge(authRepo, user)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to get user")
+		return
+	}
+	if challenge != nil {
+		helpers.HttpJson(w, http.StatusOK, challenge)
+		return
+	}
+
+	issueTokens(w, r, authRepo, user)
+}
+
+func rehashPassword(authRepo repos.AuthInterface, user *repos.AuthUser, password string) {
+	newHash, err := helpers.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash password of user %d: %v", user.ID, err)
+		return
+	}
+	if err := authRepo.RehashPassword(user.ID, user.PasswordHash, newHash); err != nil {
+		log.Printf("Failed to rehash password of user %d: %v", user.ID, err)
+	}
+}
+
+func Register(userRepo repos.UserInterface, authRepo repos.AuthInterface, orgRepo repos.OrganizationInterface, mailer mail.Sender) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.UserCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.Username == "" || req.Password == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing username and password")
 			return
 		}
 
-		token, err := helpers.CreateToken(user.Username)
+		var err error
+		if req.Email != "" {
+			if req.Email, err = helpers.NormalizeEmail(req.Email); err != nil {
+				helpers.HttpError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		if req.DisplayName, err = helpers.NormalizeDisplayName(req.DisplayName); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		httpStatus, err := userRepo.CreateUser(req.Username, req.Password, req.Email, req.DisplayName)
+		if writePasswordPolicyError(w, err) {
+			return
+		}
 		if err != nil {
-			helpers.HttpError(w, http.StatusInternalServerError, "failed to generate token")
+			helpers.HttpError(w, int(httpStatus), err.Error())
 			return
 		}
 
-		res := &responses.AuthResponse{
-			Token: token,
+		user, err := authRepo.GetAuthUserByUsername(req.Username)
+		if err != nil || user == nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get user")
+			return
 		}
+		joinDefaultOrganization(orgRepo, user.ID)
 
-		helpers.HttpJson(w, http.StatusCreated, res)
+		// The account exists either way, the user can ask for another link
+		if req.Email != "" {
+			if err := sendEmailVerification(authRepo, mailer, user.ID, user.Username, req.Email); err != nil {
+				log.Printf("Failed to start email verification of user %d: %v", user.ID, err)
+			}
+		}
+
+		// Roles that require two-factor authentication get no session before
+		// it is set up, new accounts included
+		issueTokensOrChallenge(w, r, authRepo, user)
 	}
 }
 
-func Register(userRepo repos.UserInterface) http.HandlerFunc {
+// Refresh trades a refresh token for a new access and refresh token. A refresh
+// token can only be traded once, presenting it again revokes every token of
+// its family since one of the two parties holding it isn't the user.
+func Refresh(authRepo repos.AuthInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req requests.AuthRequest
+		var req requests.RefreshRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
 			return
 		}
-		if req.Username == "" || req.Password == "" {
-			helpers.HttpError(w, http.StatusBadRequest, "missing username and password")
+		if req.RefreshToken == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing refresh token")
 			return
 		}
 
-		httpStatus, err := userRepo.CreateUser(req.Username, req.Password)
+		tokenHash := helpers.HashToken(req.RefreshToken)
+		current, err := authRepo.GetRefreshToken(tokenHash)
 		if err != nil {
-			helpers.HttpError(w, int(httpStatus), err.Error())
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get refresh token")
+			return
+		}
+		if current == nil || current.Expired {
+			helpers.HttpError(w, http.StatusUnauthorized, "invalid or expired refresh token")
 			return
 		}
 
-		token, err := helpers.CreateToken(req.Username)
-		if err != nil {
-			helpers.HttpError(w, http.StatusInternalServerError, "failed to generate token")
+		reused := current.Used || current.Revoked
+		if !reused {
+			res, next, err := newTokenPair(authRepo, current.UserID, current.Username, current.Role, current.FamilyID)
+			if err != nil {
+				helpers.HttpError(w, http.StatusInternalServerError, "failed to generate token")
+				return
+			}
+
+			err = authRepo.RotateRefreshToken(tokenHash, *next, helpers.ClientIP(r))
+			if err == nil {
+				helpers.HttpJson(w, http.StatusCreated, res)
+				return
+			}
+			if !errors.Is(err, repos.ErrTokenReused) {
+				helpers.HttpError(w, http.StatusInternalServerError, "failed to refresh token")
+				return
+			}
+		}
+
+		if err := authRepo.RevokeTokenFamily(current.FamilyID); err != nil {
+			log.Printf("Failed to revoke token family of user %d: %v", current.UserID, err)
+		}
+		helpers.HttpError(w, http.StatusUnauthorized, "refresh token was already used, please log in again")
+	}
+}
+
+// Logout revokes the access token of the request and its refresh token family.
+func Logout(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := helpers.GetPrincipal(r)
+		if err := authRepo.Logout(principal.TokenID, principal.ExpiresAt); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to log out")
 			return
 		}
 
-		res := &responses.AuthResponse{
-			Token: token,
+		res := &responses.MessageResponse{
+			Message: "logged out",
 		}
 
-		helpers.HttpJson(w, http.StatusCreated, res)
+		helpers.HttpJson(w, http.StatusOK, res)
 	}
 }
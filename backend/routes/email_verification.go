@@ -0,0 +1,165 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/mail"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"immodi/submission-backend/routes/responses"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// sendEmailVerification mails a link proving the user can read mail sent to
+// email. The mail goes out in the background, only storing the token can fail.
+func sendEmailVerification(authRepo repos.AuthInterface, mailer mail.Sender, userID int64, username, email string) error {
+	token, err := helpers.RandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	ttl := helpers.GetEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+	if err := authRepo.CreateEmailVerificationToken(userID, email, helpers.HashToken(token), time.Now().Add(ttl)); err != nil {
+		return err
+	}
+
+	msg := mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen %s to confirm this is your email address, the link expires in %s.\n\nIf you didn't sign up, ignore this email.",
+			username, emailVerificationLink(token), ttl,
+		),
+	}
+
+	go func() {
+		if err := mailer.Send(msg); err != nil {
+			log.Printf("Failed to send email verification mail for user %d: %v", userID, err)
+		}
+	}()
+	return nil
+}
+
+func emailVerificationLink(token string) string {
+	return helpers.GetEnv("EMAIL_VERIFICATION_URL", "http://localhost:5173/verify-email") + "?token=" + url.QueryEscape(token)
+}
+
+// SetOwnEmail changes the email address of the user and sends a verification
+// link to the new one. Setting the verified address again changes nothing.
+func SetOwnEmail(userRepo repos.UserInterface, authRepo repos.AuthInterface, mailer mail.Sender) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.EmailRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+
+		email, err := helpers.NormalizeEmail(req.Email)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		principal := helpers.GetPrincipal(r)
+		err = authRepo.SetEmail(principal.ID, email)
+		if errors.Is(err, repos.ErrAlreadyExists) {
+			helpers.HttpError(w, http.StatusConflict, "this email address is already in use")
+			return
+		}
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not change the email address")
+			return
+		}
+
+		user, err := userRepo.GetUserById(principal.ID)
+		if err != nil || user == nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not retrieve user")
+			return
+		}
+
+		if !user.EmailVerified {
+			if err := sendEmailVerification(authRepo, mailer, user.ID, user.Username, email); err != nil {
+				helpers.HttpError(w, http.StatusInternalServerError, "could not send the verification email")
+				return
+			}
+		}
+
+		helpers.HttpJson(w, http.StatusOK, userResponse(user))
+	}
+}
+
+// ResendEmailVerification sends a new verification link, the earlier ones
+// stop working.
+func ResendEmailVerification(userRepo repos.UserInterface, authRepo repos.AuthInterface, mailer mail.Sender) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := userRepo.GetUserById(helpers.GetPrincipal(r).ID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not retrieve user")
+			return
+		}
+		if user == nil {
+			helpers.HttpError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if user.Email == nil {
+			helpers.HttpError(w, http.StatusBadRequest, "you haven't set an email address yet")
+			return
+		}
+		if user.EmailVerified {
+			helpers.HttpError(w, http.StatusConflict, "your email address is already verified")
+			return
+		}
+
+		if err := sendEmailVerification(authRepo, mailer, user.ID, user.Username, *user.Email); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not send the verification email")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "a verification link was sent to " + *user.Email,
+		}
+
+		helpers.HttpJson(w, http.StatusAccepted, res)
+	}
+}
+
+// VerifyEmail marks an email address as verified with a token from the link
+// that was mailed to it, no login needed.
+func VerifyEmail(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.EmailVerifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.Token == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing token")
+			return
+		}
+
+		err := authRepo.VerifyEmail(helpers.HashToken(req.Token))
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusBadRequest, "the verification token is invalid or has expired")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not verify the email address")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "the email address was verified",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,249 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/imaging"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/responses"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func maxImageBytes() int {
+	return helpers.GetEnvInt("MAX_IMAGE_BYTES", 5<<20)
+}
+
+func maxImagePixels() int {
+	return helpers.GetEnvInt("MAX_IMAGE_PIXELS", 40_000_000)
+}
+
+// processImage validates an uploaded image and builds its variants, answering
+// with the matching client error when the upload can't be used.
+func processImage(w http.ResponseWriter, data []byte) (*imaging.ProcessedImage, bool) {
+	if len(data) > maxImageBytes() {
+		helpers.HttpError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("image is larger than %d bytes", maxImageBytes()))
+		return nil, false
+	}
+
+	processed, err := imaging.Process(data, maxImagePixels())
+	if errors.Is(err, imaging.ErrUnsupportedType) {
+		helpers.HttpError(w, http.StatusUnsupportedMediaType, imaging.ErrUnsupportedType.Error())
+		return nil, false
+	}
+	if errors.Is(err, imaging.ErrTooManyPixels) {
+		helpers.HttpError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "could not process the image")
+		return nil, false
+	}
+
+	return processed, true
+}
+
+// readImageUpload reads and processes the "image" file of a multipart upload.
+// The other form values are available through r.FormValue afterwards.
+func readImageUpload(w http.ResponseWriter, r *http.Request) (*imaging.ProcessedImage, bool) {
+	// Leave room for the multipart boundaries and the other form fields
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxImageBytes())+1<<16)
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			helpers.HttpError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("image is larger than %d bytes", maxImageBytes()))
+			return nil, false
+		}
+		helpers.HttpError(w, http.StatusBadRequest, "expected a multipart form with an 'image' file")
+		return nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, int64(maxImageBytes())+1))
+	if err != nil {
+		helpers.HttpError(w, http.StatusBadRequest, "could not read the uploaded image")
+		return nil, false
+	}
+
+	return processImage(w, data)
+}
+
+// attachEventImage stores a processed image and makes it the event's image,
+// removing the one it replaces. The event has to still be at version.
+func attachEventImage(w http.ResponseWriter, eventRepo repos.EventInterface, imageRepo repos.ImageInterface, eventId, version int64, processed *imaging.ProcessedImage) (*repos.Image, bool) {
+	image, err := imageRepo.SaveImage(processed)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "could not store the image")
+		return nil, false
+	}
+
+	if !replaceEventImage(w, eventRepo, imageRepo, eventId, version, &image.ID) {
+		imageRepo.DeleteImage(image.ID)
+		return nil, false
+	}
+	return image, true
+}
+
+func replaceEventImage(w http.ResponseWriter, eventRepo repos.EventInterface, imageRepo repos.ImageInterface, eventId, version int64, imageID *int64) bool {
+	previous, err := eventRepo.SetEventImage(eventId, version, imageID)
+	if errors.Is(err, repos.ErrNotFound) {
+		helpers.HttpError(w, http.StatusNotFound, "Event not found")
+		return false
+	}
+	if errors.Is(err, repos.ErrVersionConflict) {
+		helpers.HttpError(w, http.StatusPreconditionFailed, "the event was changed by someone else, reload it and try again")
+		return false
+	}
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "could not update the event image")
+		return false
+	}
+
+	if previous != nil {
+		// Gallery images stay around when they stop being the cover
+		if err := imageRepo.DeleteImageIfUnused(*previous); err != nil {
+			// The purge job picks up images that are no longer referenced
+			log.Printf("Failed to delete replaced image %d: %v", *previous, err)
+		}
+	}
+	return true
+}
+
+func UploadEventImage(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		// The image is part of the event, so it needs the version the client saw
+		version, ok := eventIfMatch(w, r, eventRepo, eventId)
+		if !ok {
+			return
+		}
+
+		processed, ok := readImageUpload(w, r)
+		if !ok {
+			return
+		}
+
+		image, ok := attachEventImage(w, eventRepo, imageRepo, eventId, version, processed)
+		if !ok {
+			return
+		}
+		recordRevision(eventRepo, r, eventId, repos.RevisionActionUpdate)
+		w.Header().Set("ETag", helpers.VersionETag(version+1))
+
+		res := &responses.EventImageResponse{
+			EventId:  eventId,
+			ImageURL: fmt.Sprintf("/events/%d/image?v=%s", eventId, repos.ImageVersion(image.Hash)),
+			Image:    image,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func DeleteEventImage(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		version, ok := eventIfMatch(w, r, eventRepo, eventId)
+		if !ok {
+			return
+		}
+
+		if !replaceEventImage(w, eventRepo, imageRepo, eventId, version, nil) {
+			return
+		}
+		recordRevision(eventRepo, r, eventId, repos.RevisionActionUpdate)
+		w.Header().Set("ETag", helpers.VersionETag(version+1))
+
+		res := &responses.EventResponse{
+			EventId: eventId,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// GetEventImage serves one size of the event image. It is public so it can be
+// used directly in an <img> tag, the ?v= of the image URL is required and
+// makes it cacheable for good.
+func GetEventImage(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		size := r.URL.Query().Get("size")
+		if size == "" {
+			size = imaging.SizeOriginal
+		}
+		if !imaging.IsValidSize(size) {
+			helpers.HttpError(w, http.StatusBadRequest, "unknown image size")
+			return
+		}
+
+		event, err := eventRepo.GetEventById(eventId)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event")
+			return
+		}
+		if event == nil || event.ImageID == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Event image not found")
+			return
+		}
+
+		serveImageVariant(w, r, imageRepo, *event.ImageID, size)
+	}
+}
+
+func serveImageVariant(w http.ResponseWriter, r *http.Request, imageRepo repos.ImageInterface, imageID int64, size string) {
+	variant, data, err := imageRepo.GetImageVariant(imageID, size)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "couldn't load the image")
+		return
+	}
+	if variant == nil {
+		helpers.HttpError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	// Ids are sequential, the version from the URL handed out with the image
+	// is what keeps images of other organizations from being walked
+	version := repos.ImageVersion(variant.ImageHash)
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("v")), []byte(version)) != 1 {
+		helpers.HttpError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%s"`, version, size)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", variant.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,300 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/imaging"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"immodi/submission-backend/routes/responses"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func parseEventMediaIds(w http.ResponseWriter, r *http.Request) (int64, int64, bool) {
+	eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+		return 0, 0, false
+	}
+
+	mediaId, err := strconv.ParseInt(chi.URLParam(r, "mediaId"), 10, 64)
+	if err != nil {
+		helpers.HttpError(w, http.StatusBadRequest, "Invalid media id, pass a valid one")
+		return 0, 0, false
+	}
+
+	return eventId, mediaId, true
+}
+
+func writeEventMedia(w http.ResponseWriter, eventRepo repos.EventInterface, eventId int64, status int) {
+	media, err := eventRepo.GetEventMedia(eventId)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the event media")
+		return
+	}
+
+	res := &responses.EventMediaResponse{
+		EventId: eventId,
+		Media:   media,
+		Count:   len(media),
+	}
+
+	helpers.HttpJson(w, status, res)
+}
+
+func writeEventMediaItem(w http.ResponseWriter, eventRepo repos.EventInterface, eventId, mediaId int64, status int) {
+	media, err := eventRepo.GetEventMediaItem(eventId, mediaId)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the event media")
+		return
+	}
+	if media == nil {
+		helpers.HttpError(w, http.StatusNotFound, "Event media not found")
+		return
+	}
+
+	helpers.HttpJson(w, status, media)
+}
+
+func GetEventMedia(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		event, err := eventRepo.GetEventById(eventId)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event")
+			return
+		}
+		if event == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+
+		writeEventMedia(w, eventRepo, eventId, http.StatusOK)
+	}
+}
+
+// AddEventMedia appends an uploaded image to the gallery. Besides the "image"
+// file the form may carry "caption", "altText" and "translations", the latter
+// being a JSON array like the one accepted by UpdateEventMedia.
+func AddEventMedia(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		processed, ok := readImageUpload(w, r)
+		if !ok {
+			return
+		}
+
+		req := requests.EventMediaRequest{
+			Caption: r.FormValue("caption"),
+			AltText: r.FormValue("altText"),
+		}
+		if translations := r.FormValue("translations"); translations != "" {
+			if err := json.Unmarshal([]byte(translations), &req.Translations); err != nil {
+				helpers.HttpError(w, http.StatusBadRequest, "translations must be a JSON array")
+				return
+			}
+		}
+		if err := req.Validate(); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		mediaId, ok := addEventMediaImage(w, eventRepo, imageRepo, eventId, processed, &req)
+		if !ok {
+			return
+		}
+
+		writeEventMediaItem(w, eventRepo, eventId, mediaId, http.StatusCreated)
+	}
+}
+
+func addEventMediaImage(w http.ResponseWriter, eventRepo repos.EventInterface, imageRepo repos.ImageInterface, eventId int64, processed *imaging.ProcessedImage, req *requests.EventMediaRequest) (int64, bool) {
+	image, err := imageRepo.SaveImage(processed)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "could not store the image")
+		return 0, false
+	}
+
+	mediaId, err := eventRepo.AddEventMedia(eventId, image.ID, req.Caption, req.AltText, req.Translations)
+	if err != nil {
+		imageRepo.DeleteImage(image.ID)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return 0, false
+		}
+		helpers.HttpError(w, http.StatusInternalServerError, "could not add the image to the event")
+		return 0, false
+	}
+
+	return mediaId, true
+}
+
+func UpdateEventMedia(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, mediaId, ok := parseEventMediaIds(w, r)
+		if !ok {
+			return
+		}
+
+		var req requests.EventMediaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if err := req.Validate(); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err := eventRepo.UpdateEventMedia(eventId, mediaId, req.Caption, req.AltText, req.Translations)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event media not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not update the event media")
+			return
+		}
+
+		writeEventMediaItem(w, eventRepo, eventId, mediaId, http.StatusOK)
+	}
+}
+
+func ReorderEventMedia(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		var req requests.EventMediaOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+
+		err = eventRepo.ReorderEventMedia(eventId, req.MediaIDs)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+		if errors.Is(err, repos.ErrInvalidInput) {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not reorder the event media")
+			return
+		}
+
+		writeEventMedia(w, eventRepo, eventId, http.StatusOK)
+	}
+}
+
+// SetEventCover makes a gallery image the event image, the image it replaces
+// is deleted unless it is part of the gallery too.
+func SetEventCover(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, mediaId, ok := parseEventMediaIds(w, r)
+		if !ok {
+			return
+		}
+
+		previous, err := eventRepo.SetEventCover(eventId, mediaId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event media not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not set the event cover")
+			return
+		}
+		recordRevision(eventRepo, r, eventId, repos.RevisionActionUpdate)
+
+		if previous != nil {
+			if err := imageRepo.DeleteImageIfUnused(*previous); err != nil {
+				log.Printf("Failed to delete replaced image %d: %v", *previous, err)
+			}
+		}
+
+		writeEventMedia(w, eventRepo, eventId, http.StatusOK)
+	}
+}
+
+func DeleteEventMedia(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, mediaId, ok := parseEventMediaIds(w, r)
+		if !ok {
+			return
+		}
+
+		imageId, err := eventRepo.DeleteEventMedia(eventId, mediaId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event media not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not delete the event media")
+			return
+		}
+
+		if err := imageRepo.DeleteImageIfUnused(imageId); err != nil {
+			// The image cleanup job picks it up later
+			log.Printf("Failed to delete image %d of removed media: %v", imageId, err)
+		}
+
+		res := &responses.EventDeletionResponse{
+			Id:      mediaId,
+			Message: "the image was removed from the event gallery",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// GetEventMediaImage serves a gallery image, public for the same reason as GetEventImage.
+func GetEventMediaImage(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, mediaId, ok := parseEventMediaIds(w, r)
+		if !ok {
+			return
+		}
+
+		size := r.URL.Query().Get("size")
+		if size == "" {
+			size = imaging.SizeOriginal
+		}
+		if !imaging.IsValidSize(size) {
+			helpers.HttpError(w, http.StatusBadRequest, "unknown image size")
+			return
+		}
+
+		media, err := eventRepo.GetEventMediaItem(eventId, mediaId)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the event media")
+			return
+		}
+		if media == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Event media not found")
+			return
+		}
+
+		serveImageVariant(w, r, imageRepo, media.ImageID, size)
+	}
+}
@@ -0,0 +1,291 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"immodi/submission-backend/routes/responses"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// organizerOr lets the organizers of the event in the route through, and
+// everybody else with permission.
+func organizerOr(eventRepo repos.EventInterface, r *http.Request, permission string) func(p *helpers.Principal) bool {
+	return func(p *helpers.Principal) bool {
+		if p.Can(permission) {
+			return true
+		}
+
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			return false
+		}
+		organizer, err := eventRepo.IsEventOrganizer(eventId, p.ID)
+		if err != nil {
+			log.Printf("Failed to check organizers of event %d: %v", eventId, err)
+			return false
+		}
+		return organizer
+	}
+}
+
+// ownerOr is the same for the user who created the event, co-organizers
+// aren't enough.
+func ownerOr(eventRepo repos.EventInterface, r *http.Request, permission string) func(p *helpers.Principal) bool {
+	return func(p *helpers.Principal) bool {
+		if p.Can(permission) {
+			return true
+		}
+
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			return false
+		}
+		owner, err := eventRepo.IsEventOwner(eventId, p.ID)
+		if err != nil {
+			log.Printf("Failed to check owner of event %d: %v", eventId, err)
+			return false
+		}
+		return owner
+	}
+}
+
+// liveEventId parses the event id of the route, answering 404 when there is
+// no such event.
+func liveEventId(w http.ResponseWriter, r *http.Request, eventRepo repos.EventInterface) (int64, bool) {
+	eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+		return 0, false
+	}
+
+	event, err := eventRepo.GetEventById(eventId)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event")
+		return 0, false
+	}
+	if event == nil {
+		helpers.HttpError(w, http.StatusNotFound, "Event not found")
+		return 0, false
+	}
+	return eventId, true
+}
+
+func writeEventOrganizers(w http.ResponseWriter, eventRepo repos.EventInterface, eventId int64, status int) {
+	organizers, err := eventRepo.GetEventOrganizers(eventId)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the event organizers")
+		return
+	}
+
+	res := &responses.EventOrganizersResponse{
+		EventId:    eventId,
+		Organizers: organizers,
+		Count:      len(organizers),
+	}
+
+	helpers.HttpJson(w, status, res)
+}
+
+func GetEventOrganizers(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, ok := liveEventId(w, r, eventRepo)
+		if !ok {
+			return
+		}
+
+		writeEventOrganizers(w, eventRepo, eventId, http.StatusOK)
+	}
+}
+
+// AddEventOrganizer lets another user manage the event as a co-organizer.
+func AddEventOrganizer(eventRepo repos.EventInterface, userRepo repos.UserInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, ok := liveEventId(w, r, eventRepo)
+		if !ok {
+			return
+		}
+
+		var req requests.EventOrganizerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.UserID == 0 {
+			helpers.HttpError(w, http.StatusBadRequest, "missing user id")
+			return
+		}
+
+		user, err := userRepo.GetUserById(req.UserID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get user")
+			return
+		}
+		if user == nil {
+			helpers.HttpError(w, http.StatusNotFound, "User not found")
+			return
+		}
+
+		err = eventRepo.AddEventOrganizer(eventId, user.ID)
+		if errors.Is(err, repos.ErrAlreadyExists) {
+			helpers.HttpError(w, http.StatusConflict, "user already organizes this event")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't add the organizer")
+			return
+		}
+
+		writeEventOrganizers(w, eventRepo, eventId, http.StatusCreated)
+	}
+}
+
+func RemoveEventOrganizer(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, ok := liveEventId(w, r, eventRepo)
+		if !ok {
+			return
+		}
+
+		userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid user id, pass a valid one")
+			return
+		}
+
+		err = eventRepo.RemoveEventOrganizer(eventId, userId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "user isn't a co-organizer of this event")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't remove the organizer")
+			return
+		}
+
+		writeEventOrganizers(w, eventRepo, eventId, http.StatusOK)
+	}
+}
+
+// GetOrganizedEvents lists the events the current user created or
+// co-organizes.
+func GetOrganizedEvents(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		events, err := eventRepo.GetOrganizedEvents(helpers.GetPrincipal(r).ID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the organized events")
+			return
+		}
+		if err := localizeEvents(w, r, eventRepo, events); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the event translations")
+			return
+		}
+
+		res := &responses.EventsResponse{
+			Events: events,
+			Count:  len(events),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func writeEventAttendees(w http.ResponseWriter, eventRepo repos.EventInterface, eventId int64) {
+	attendees, err := eventRepo.GetEventAttendees(eventId)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the event attendees")
+		return
+	}
+
+	checkedIn := 0
+	for _, a := range attendees {
+		if a.CheckedInAt != nil {
+			checkedIn++
+		}
+	}
+
+	res := &responses.EventAttendeesResponse{
+		EventId:   eventId,
+		Attendees: attendees,
+		Count:     len(attendees),
+		CheckedIn: checkedIn,
+	}
+
+	helpers.HttpJson(w, http.StatusOK, res)
+}
+
+func GetEventAttendees(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, ok := liveEventId(w, r, eventRepo)
+		if !ok {
+			return
+		}
+
+		writeEventAttendees(w, eventRepo, eventId)
+	}
+}
+
+// CheckInAttendee marks a registered user as present at the event.
+func CheckInAttendee(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, ok := liveEventId(w, r, eventRepo)
+		if !ok {
+			return
+		}
+
+		userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid user id, pass a valid one")
+			return
+		}
+
+		err = eventRepo.CheckInAttendee(eventId, userId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "user isn't registered for this event")
+			return
+		}
+		if errors.Is(err, repos.ErrAlreadyExists) {
+			helpers.HttpError(w, http.StatusConflict, "user is already checked in")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't check in the user")
+			return
+		}
+
+		writeEventAttendees(w, eventRepo, eventId)
+	}
+}
+
+func UndoCheckIn(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, ok := liveEventId(w, r, eventRepo)
+		if !ok {
+			return
+		}
+
+		userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid user id, pass a valid one")
+			return
+		}
+
+		err = eventRepo.UndoCheckIn(eventId, userId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "user isn't checked in at this event")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't undo the check in")
+			return
+		}
+
+		writeEventAttendees(w, eventRepo, eventId)
+	}
+}
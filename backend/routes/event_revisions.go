@@ -0,0 +1,181 @@
+package routes
+
+import (
+	"errors"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/responses"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// recordRevision stores the event's current state as a revision authored by the
+// caller. The write it describes already happened, so a failure is only logged.
+func recordRevision(eventRepo repos.EventInterface, r *http.Request, eventId int64, action string) {
+	author := "unknown"
+	if principal := helpers.GetPrincipal(r); principal != nil {
+		author = principal.Username
+	}
+
+	if _, err := eventRepo.RecordEventRevision(eventId, action, author); err != nil {
+		log.Printf("Failed to record %s revision for event %d: %v", action, eventId, err)
+	}
+}
+
+func GetEventRevisions(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		revisions, err := eventRepo.GetEventRevisions(eventId)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event revisions")
+			return
+		}
+
+		resp := &responses.EventRevisionsResponse{
+			Revisions: revisions,
+			Count:     len(revisions),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, resp)
+	}
+}
+
+func GetEventRevision(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		revisionNumber, err := strconv.ParseInt(chi.URLParam(r, "revision"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid revision, pass a valid one")
+			return
+		}
+
+		revision, err := eventRepo.GetEventRevision(eventId, revisionNumber)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event revision")
+			return
+		}
+		if revision == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Revision not found")
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusOK, revision)
+	}
+}
+
+func DiffEventRevisions(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		from, errFrom := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+		to, errTo := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+		if errFrom != nil || errTo != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "missing or invalid 'from' and 'to' revisions")
+			return
+		}
+
+		fromRevision, err := eventRepo.GetEventRevision(eventId, from)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event revision")
+			return
+		}
+		toRevision, err := eventRepo.GetEventRevision(eventId, to)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event revision")
+			return
+		}
+		if fromRevision == nil || toRevision == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Revision not found")
+			return
+		}
+
+		resp := &responses.EventRevisionDiffResponse{
+			EventId: eventId,
+			From:    from,
+			To:      to,
+			Changes: repos.DiffEventSnapshots(fromRevision.Snapshot, toRevision.Snapshot),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, resp)
+	}
+}
+
+// RollbackEvent writes the fields of an older revision back onto the event and
+// records the result as a new revision. The current image is kept as is.
+func RollbackEvent(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		revisionNumber, err := strconv.ParseInt(chi.URLParam(r, "revision"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid revision, pass a valid one")
+			return
+		}
+
+		event, err := eventRepo.GetEventById(eventId)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event")
+			return
+		}
+		if event == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+
+		revision, err := eventRepo.GetEventRevision(eventId, revisionNumber)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event revision")
+			return
+		}
+		if revision == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Revision not found")
+			return
+		}
+
+		snapshot := revision.Snapshot
+		err = eventRepo.UpdateEvent(eventId, event.Version, snapshot.Name, snapshot.Description, snapshot.Category, snapshot.Date, snapshot.Venue, snapshot.Price, snapshot.Translations)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+		if errors.Is(err, repos.ErrVersionConflict) {
+			helpers.HttpError(w, http.StatusPreconditionFailed, "the event was changed while rolling back, try again")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not roll back the event")
+			return
+		}
+
+		recordRevision(eventRepo, r, eventId, repos.RevisionActionRollback)
+
+		w.Header().Set("ETag", helpers.VersionETag(event.Version+1))
+
+		res := &responses.EventResponse{
+			EventId: eventId,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,223 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"immodi/submission-backend/routes/responses"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// localizeEvent shows the event in the language negotiated from ?lang=, the
+// user's preferred language or Accept-Language, falling back to the default
+// language.
+func localizeEvent(w http.ResponseWriter, r *http.Request, event *repos.Event) {
+	defaultLanguage := helpers.DefaultLanguage()
+	event.Localize(helpers.NegotiateLanguage(helpers.LanguagePreferences(r), event.Languages(defaultLanguage), defaultLanguage))
+
+	w.Header().Add("Vary", "Accept-Language, Authorization")
+	w.Header().Set("Content-Language", event.Language)
+}
+
+// localizeEvents does the same for a listing, loading the translations the
+// listing queries leave out.
+func localizeEvents(w http.ResponseWriter, r *http.Request, eventRepo repos.EventInterface, events []repos.Event) error {
+	ids := make([]int64, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+
+	translations, err := eventRepo.GetTranslationsForEvents(ids)
+	if err != nil {
+		return err
+	}
+
+	defaultLanguage := helpers.DefaultLanguage()
+	preferences := helpers.LanguagePreferences(r)
+	for i := range events {
+		events[i].Translations = translations[events[i].ID]
+		if events[i].Translations == nil {
+			events[i].Translations = []repos.EventTranslation{}
+		}
+		events[i].Localize(helpers.NegotiateLanguage(preferences, events[i].Languages(defaultLanguage), defaultLanguage))
+	}
+
+	w.Header().Add("Vary", "Accept-Language, Authorization")
+	return nil
+}
+
+func parseEventLanguage(w http.ResponseWriter, r *http.Request) (int64, string, bool) {
+	eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+		return 0, "", false
+	}
+
+	language, err := helpers.CanonicalLanguage(chi.URLParam(r, "lang"))
+	if err != nil {
+		helpers.HttpError(w, http.StatusBadRequest, err.Error())
+		return 0, "", false
+	}
+
+	return eventId, language, true
+}
+
+func GetEventTranslations(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		event, err := eventRepo.GetEventById(eventId)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event")
+			return
+		}
+		if event == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusOK, event.Translations)
+	}
+}
+
+func GetEventTranslation(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, language, ok := parseEventLanguage(w, r)
+		if !ok {
+			return
+		}
+
+		translation, err := eventRepo.GetEventTranslation(eventId, language)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the translation")
+			return
+		}
+		if translation == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Translation not found")
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusOK, translation)
+	}
+}
+
+// PutEventTranslation creates or replaces the translation for one language.
+func PutEventTranslation(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, language, ok := parseEventLanguage(w, r)
+		if !ok {
+			return
+		}
+
+		var req requests.EventTranslationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.Name == "" || req.Description == "" || req.Venue == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing name, description or venue")
+			return
+		}
+
+		translation := repos.EventTranslation{
+			Language:    language,
+			Name:        req.Name,
+			Description: req.Description,
+			Venue:       req.Venue,
+		}
+
+		created, err := eventRepo.SaveEventTranslation(eventId, translation)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not save the translation")
+			return
+		}
+
+		recordRevision(eventRepo, r, eventId, repos.RevisionActionUpdate)
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		helpers.HttpJson(w, status, translation)
+	}
+}
+
+func DeleteEventTranslation(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventId, language, ok := parseEventLanguage(w, r)
+		if !ok {
+			return
+		}
+
+		err := eventRepo.DeleteEventTranslation(eventId, language)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Translation not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not delete the translation")
+			return
+		}
+
+		recordRevision(eventRepo, r, eventId, repos.RevisionActionUpdate)
+
+		res := &responses.EventDeletionResponse{
+			Id:      eventId,
+			Message: "the " + language + " translation of the event was deleted",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// GetTranslationReport lists, for every event and every supported language
+// besides the default one, which fields are missing or stale. Pass
+// ?incomplete=true to leave out the events that are fully translated.
+func GetTranslationReport(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defaultLanguage := helpers.DefaultLanguage()
+		languages := []string{}
+		for _, language := range helpers.SupportedLanguages() {
+			if language != defaultLanguage {
+				languages = append(languages, language)
+			}
+		}
+
+		reports, err := eventRepo.GetTranslationReport(languages)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't build the translation report")
+			return
+		}
+
+		if r.URL.Query().Get("incomplete") == "true" {
+			incomplete := []repos.EventTranslationReport{}
+			for _, report := range reports {
+				if !report.Complete {
+					incomplete = append(incomplete, report)
+				}
+			}
+			reports = incomplete
+		}
+
+		res := &responses.TranslationReportResponse{
+			Languages: languages,
+			Events:    reports,
+			Count:     len(reports),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
@@ -3,12 +3,15 @@ package routes
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/imaging"
 	"immodi/submission-backend/repos"
 	"immodi/submission-backend/routes/requests"
 	"immodi/submission-backend/routes/responses"
 	helper_structs "immodi/submission-backend/structs"
+	"mime"
 	"net/http"
 	"strconv"
 	"time"
@@ -19,52 +22,129 @@ import (
 func EventsRouter(r chi.Router, db *sql.DB, api *helper_structs.API) {
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, nil, GetAllEvents(api.EventRepo, r))
+		helpers.ProtectedHandler(w, r, nil, GetAllEvents(orgEvents(api, r), r))
 	})
 
 	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, func(username string) bool {
-			return api.UserRepo.IsAdmin(username)
-		}, CreateEvent(api.EventRepo))
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermEventsCreate), CreateEvent(orgEvents(api, r), api.ImageRepo))
 	})
 	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, nil, GetEvent(api.EventRepo))
+		helpers.ProtectedHandler(w, r, nil, GetEvent(orgEvents(api, r)))
+	})
+
+	r.Get("/trash", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermEventsRestore), GetDeletedEvents(orgEvents(api, r)))
+	})
+	r.Post("/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsRestore), RestoreEvent(orgEvents(api, r)))
+	})
+
+	r.Get("/{id}/revisions", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsHistory), GetEventRevisions(orgEvents(api, r)))
+	})
+	r.Get("/{id}/revisions/diff", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsHistory), DiffEventRevisions(orgEvents(api, r)))
+	})
+	r.Get("/{id}/revisions/{revision}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsHistory), GetEventRevision(orgEvents(api, r)))
+	})
+	r.Post("/{id}/revisions/{revision}/rollback", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), RollbackEvent(orgEvents(api, r)))
 	})
 
 	r.Get("/category/{category}", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, nil, GetEventsByCategory(api.EventRepo, r))
+		helpers.ProtectedHandler(w, r, nil, GetEventsByCategory(orgEvents(api, r), r))
 	})
 	r.Put("/{id}", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, func(username string) bool {
-			return api.UserRepo.IsAdmin(username)
-		}, UpdateEvent(api.EventRepo))
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), UpdateEvent(orgEvents(api, r), api.ImageRepo))
+	})
+	r.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), PatchEvent(orgEvents(api, r), api.ImageRepo))
+	})
+
+	r.Get("/{id}/image", GetEventImage(api.EventRepo, api.ImageRepo))
+	r.Put("/{id}/image", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), UploadEventImage(orgEvents(api, r), api.ImageRepo))
+	})
+	r.Delete("/{id}/image", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), DeleteEventImage(orgEvents(api, r), api.ImageRepo))
+	})
+	r.Get("/translations/report", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermEventsTranslate), GetTranslationReport(orgEvents(api, r)))
+	})
+	r.Get("/{id}/translations", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, nil, GetEventTranslations(orgEvents(api, r)))
+	})
+	r.Get("/{id}/translations/{lang}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, nil, GetEventTranslation(orgEvents(api, r)))
+	})
+	r.Put("/{id}/translations/{lang}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsTranslate), PutEventTranslation(orgEvents(api, r)))
+	})
+	r.Delete("/{id}/translations/{lang}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsTranslate), DeleteEventTranslation(orgEvents(api, r)))
+	})
+
+	r.Get("/{id}/media", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, nil, GetEventMedia(orgEvents(api, r)))
+	})
+	r.Post("/{id}/media", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), AddEventMedia(orgEvents(api, r), api.ImageRepo))
+	})
+	r.Put("/{id}/media/order", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), ReorderEventMedia(orgEvents(api, r)))
+	})
+	r.Put("/{id}/media/{mediaId}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), UpdateEventMedia(orgEvents(api, r)))
+	})
+	r.Delete("/{id}/media/{mediaId}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), DeleteEventMedia(orgEvents(api, r), api.ImageRepo))
 	})
+	r.Put("/{id}/media/{mediaId}/cover", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), SetEventCover(orgEvents(api, r), api.ImageRepo))
+	})
+	r.Get("/{id}/media/{mediaId}/image", GetEventMediaImage(api.EventRepo, api.ImageRepo))
+
 	r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, func(username string) bool {
-			return api.UserRepo.IsAdmin(username)
-		}, DeleteEvent(api.EventRepo))
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsDelete), DeleteEvent(orgEvents(api, r)))
 	})
 
 	// r.Get("/upcoming", func(w http.ResponseWriter, r *http.Request) {
-	// 	helpers.ProtectedHandler(w, r, nil, getUpcomingEvents(api.EventRepo))
+	// 	helpers.ProtectedHandler(w, r, nil, getUpcomingEvents(orgEvents(api, r)))
 	// })
 
 	r.Get("/search/{keyword}", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, nil, SearchEvents(api.EventRepo, r))
+		helpers.ProtectedHandler(w, r, nil, SearchEvents(orgEvents(api, r), r))
+	})
+
+	r.Get("/organized", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, nil, GetOrganizedEvents(orgEvents(api, r)))
+	})
+	r.Get("/{id}/organizers", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), GetEventOrganizers(orgEvents(api, r)))
+	})
+	r.Post("/{id}/organizers", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, ownerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), AddEventOrganizer(orgEvents(api, r), orgUsers(api, r)))
+	})
+	r.Delete("/{id}/organizers/{userId}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, ownerOr(orgEvents(api, r), r, helpers.PermEventsUpdate), RemoveEventOrganizer(orgEvents(api, r)))
+	})
+	r.Get("/{id}/attendees", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsAttendees), GetEventAttendees(orgEvents(api, r)))
+	})
+	r.Post("/{id}/attendees/{userId}/checkin", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsCheckin), CheckInAttendee(orgEvents(api, r)))
+	})
+	r.Delete("/{id}/attendees/{userId}/checkin", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, organizerOr(orgEvents(api, r), r, helpers.PermEventsCheckin), UndoCheckIn(orgEvents(api, r)))
 	})
 
 	r.Post("/assign/{id}", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, func(username string) bool {
-			userId, err := helpers.ParseTheUserIdFromRequest(r)
-			if err != nil {
-				return false
-			}
-			return api.UserRepo.IsSameUser(username, userId) || api.UserRepo.IsAdmin(username)
-		}, AssignEvent(api.EventRepo, api.UserRepo))
+		helpers.ProtectedHandler(w, r, nil, AssignEvent(orgEvents(api, r), orgUsers(api, r)))
 	})
 }
 
-func GetAllEvents(eventRepository repos.EventInterface, r *http.Request) http.HandlerFunc {
+func GetAllEvents(eventRepo repos.EventInterface, r *http.Request) http.HandlerFunc {
 	pageStr := r.URL.Query().Get("page")
 	limitStr := r.URL.Query().Get("limit")
 
@@ -80,7 +160,7 @@ func GetAllEvents(eventRepository repos.EventInterface, r *http.Request) http.Ha
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		events, err := eventRepository.GetAllEvents()
+		events, err := eventRepo.GetAllEvents()
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "Failed to get all events")
 			return
@@ -95,6 +175,10 @@ func GetAllEvents(eventRepository repos.EventInterface, r *http.Request) http.Ha
 		startIndex := (page - 1) * limit
 		endIndex := min(page*limit, len(events))
 		events = events[startIndex:endIndex]
+		if err := localizeEvents(w, r, eventRepo, events); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the event translations")
+			return
+		}
 		resp := &responses.EventsResponse{
 			Events: events,
 			Count:  eventsCount,
@@ -104,8 +188,12 @@ func GetAllEvents(eventRepository repos.EventInterface, r *http.Request) http.Ha
 	}
 }
 
-func CreateEvent(eventRepo repos.EventInterface) http.HandlerFunc {
+func CreateEvent(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireOrganization(w, r) {
+			return
+		}
+
 		var req requests.EventRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
@@ -122,13 +210,34 @@ func CreateEvent(eventRepo repos.EventInterface) http.HandlerFunc {
 			helpers.HttpError(w, http.StatusBadRequest, "missing name, description, category, date, venue or price")
 			return
 		}
+		if err := req.NormalizeTranslations(); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
-		eventId, err := eventRepo.CreateEvent(req.Name, req.Description, req.Category, date.String(), req.Venue, req.Price, req.Image, req.Translations)
+		var processed *imaging.ProcessedImage
+		if len(req.Image) > 0 {
+			var ok bool
+			if processed, ok = processImage(w, req.Image); !ok {
+				return
+			}
+		}
+
+		eventId, err := eventRepo.CreateEvent(req.Name, req.Description, req.Category, date.String(), req.Venue, req.Price, req.Translations, helpers.GetPrincipal(r).ID)
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "could not create event")
 			return
 		}
 
+		if processed != nil {
+			// New events start at version 1
+			if _, ok := attachEventImage(w, eventRepo, imageRepo, eventId, 1, processed); !ok {
+				return
+			}
+		}
+
+		recordRevision(eventRepo, r, eventId, repos.RevisionActionCreate)
+
 		res := &responses.EventResponse{
 			EventId: eventId,
 		}
@@ -137,6 +246,9 @@ func CreateEvent(eventRepo repos.EventInterface) http.HandlerFunc {
 	}
 }
 
+// GetEvent returns the event as stored, in the default language with all its
+// translations, so editors can send it back with PUT or PATCH. Only an explicit
+// ?lang= shows it translated.
 func GetEvent(eventRepo repos.EventInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := chi.URLParam(r, "id")
@@ -156,6 +268,13 @@ func GetEvent(eventRepo repos.EventInterface) http.HandlerFunc {
 			return
 		}
 
+		if r.URL.Query().Get("lang") != "" {
+			localizeEvent(w, r, event)
+		} else {
+			event.Language = helpers.DefaultLanguage()
+			w.Header().Set("Content-Language", event.Language)
+		}
+		w.Header().Set("ETag", helpers.VersionETag(event.Version))
 		helpers.HttpJson(w, http.StatusOK, event)
 	}
 }
@@ -193,6 +312,10 @@ func GetEventsByCategory(eventRepo repos.EventInterface, r *http.Request) http.H
 		startIndex := (page - 1) * limit
 		endIndex := min(page*limit, len(events))
 		events = events[startIndex:endIndex]
+		if err := localizeEvents(w, r, eventRepo, events); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the event translations")
+			return
+		}
 
 		if len(events) == 0 || events == nil {
 			events = []repos.Event{}
@@ -207,7 +330,29 @@ func GetEventsByCategory(eventRepo repos.EventInterface, r *http.Request) http.H
 	}
 }
 
-func UpdateEvent(eventRepo repos.EventInterface) http.HandlerFunc {
+// eventIfMatch is RequireIfMatchVersion for events, resolving "If-Match: *" to
+// the version the event is at now.
+func eventIfMatch(w http.ResponseWriter, r *http.Request, eventRepo repos.EventInterface, eventId int64) (int64, bool) {
+	version, ok := helpers.RequireIfMatchVersion(w, r)
+	if !ok || version != helpers.AnyVersion {
+		return version, ok
+	}
+
+	event, err := eventRepo.GetEventById(eventId)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event")
+		return 0, false
+	}
+	if event == nil {
+		helpers.HttpError(w, http.StatusNotFound, "Event not found")
+		return 0, false
+	}
+	return event.Version, true
+}
+
+// UpdateEvent replaces the event's fields, an image in the body replaces the
+// current one while leaving it out keeps it.
+func UpdateEvent(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := chi.URLParam(r, "id")
 		eventId, err := strconv.ParseInt(idStr, 10, 64)
@@ -216,6 +361,11 @@ func UpdateEvent(eventRepo repos.EventInterface) http.HandlerFunc {
 			return
 		}
 
+		version, ok := eventIfMatch(w, r, eventRepo, eventId)
+		if !ok {
+			return
+		}
+
 		var req requests.EventRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
@@ -232,17 +382,124 @@ func UpdateEvent(eventRepo repos.EventInterface) http.HandlerFunc {
 			helpers.HttpError(w, http.StatusBadRequest, "missing id, name, description, category, date, venue or price")
 			return
 		}
+		if err := req.NormalizeTranslations(); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
-		err = eventRepo.UpdateEvent(eventId, req.Name, req.Description, req.Category, date.String(), req.Venue, req.Price, req.Image, req.Translations)
+		var processed *imaging.ProcessedImage
+		if len(req.Image) > 0 {
+			if processed, ok = processImage(w, req.Image); !ok {
+				return
+			}
+		}
+
+		err = eventRepo.UpdateEvent(eventId, version, req.Name, req.Description, req.Category, date.String(), req.Venue, req.Price, req.Translations)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+		if errors.Is(err, repos.ErrVersionConflict) {
+			helpers.HttpError(w, http.StatusPreconditionFailed, "the event was changed by someone else, reload it and try again")
+			return
+		}
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "could not update the event")
 			return
 		}
 
+		// Replacing the image bumps the version once more
+		newVersion := version + 1
+		if processed != nil {
+			if _, ok := attachEventImage(w, eventRepo, imageRepo, eventId, newVersion, processed); !ok {
+				return
+			}
+			newVersion++
+		}
+
+		recordRevision(eventRepo, r, eventId, repos.RevisionActionUpdate)
+
+		w.Header().Set("ETag", helpers.VersionETag(newVersion))
+
+		res := &responses.EventResponse{
+			EventId: eventId,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func PatchEvent(eventRepo repos.EventInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		eventId, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+			helpers.HttpError(w, http.StatusUnsupportedMediaType, "PATCH expects an application/merge-patch+json body")
+			return
+		}
+
+		version, ok := eventIfMatch(w, r, eventRepo, eventId)
+		if !ok {
+			return
+		}
+
+		patch, err := requests.DecodeEventMergePatch(r.Body)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var processed *imaging.ProcessedImage
+		if patch.Image != nil && len(*patch.Image) > 0 {
+			if processed, ok = processImage(w, *patch.Image); !ok {
+				return
+			}
+		}
+
+		err = eventRepo.PatchEvent(eventId, version, patch.Fields)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+		if errors.Is(err, repos.ErrVersionConflict) {
+			helpers.HttpError(w, http.StatusPreconditionFailed, "the event was changed by someone else, reload it and try again")
+			return
+		}
+		if errors.Is(err, repos.ErrInvalidInput) {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not patch the event")
+			return
+		}
+
+		newVersion := version + 1
+		if processed != nil {
+			if _, ok := attachEventImage(w, eventRepo, imageRepo, eventId, newVersion, processed); !ok {
+				return
+			}
+			newVersion++
+		} else if patch.Image != nil {
+			if !replaceEventImage(w, eventRepo, imageRepo, eventId, newVersion, nil) {
+				return
+			}
+			newVersion++
+		}
+
+		recordRevision(eventRepo, r, eventId, repos.RevisionActionUpdate)
+
 		res := &responses.EventResponse{
 			EventId: eventId,
 		}
 
+		w.Header().Set("ETag", helpers.VersionETag(newVersion))
 		helpers.HttpJson(w, http.StatusOK, res)
 	}
 }
@@ -256,7 +513,20 @@ func DeleteEvent(eventRepo repos.EventInterface) http.HandlerFunc {
 			return
 		}
 
-		err = eventRepo.DeleteEvent(eventId)
+		version, ok := eventIfMatch(w, r, eventRepo, eventId)
+		if !ok {
+			return
+		}
+
+		err = eventRepo.DeleteEvent(eventId, version)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+		if errors.Is(err, repos.ErrVersionConflict) {
+			helpers.HttpError(w, http.StatusPreconditionFailed, "the event was changed by someone else, reload it and try again")
+			return
+		}
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "could not delete the event")
 			return
@@ -264,7 +534,51 @@ func DeleteEvent(eventRepo repos.EventInterface) http.HandlerFunc {
 
 		res := &responses.EventDeletionResponse{
 			Id:      eventId,
-			Message: "the event with the above id was deleted successfully",
+			Message: "the event with the above id was moved to the trash",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func GetDeletedEvents(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		events, err := eventRepo.GetDeletedEvents()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "Failed to get deleted events")
+			return
+		}
+
+		resp := &responses.EventsResponse{
+			Events: events,
+			Count:  len(events),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, resp)
+	}
+}
+
+func RestoreEvent(eventRepo repos.EventInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		eventId, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid id, pass a valid one")
+			return
+		}
+
+		err = eventRepo.RestoreEvent(eventId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found in the trash")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not restore the event")
+			return
+		}
+
+		res := &responses.EventResponse{
+			EventId: eventId,
 		}
 
 		helpers.HttpJson(w, http.StatusOK, res)
@@ -315,6 +629,10 @@ func SearchEvents(eventRepo repos.EventInterface, r *http.Request) http.HandlerF
 		startIndex := (page - 1) * limit
 		endIndex := min(page*limit, len(events))
 		events = events[startIndex:endIndex]
+		if err := localizeEvents(w, r, eventRepo, events); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the event translations")
+			return
+		}
 
 		if len(events) == 0 || events == nil {
 			events = []repos.Event{}
@@ -350,6 +668,22 @@ func AssignEvent(eventRepo repos.EventInterface, userRepo *repos.UserRepository)
 			return
 		}
 
+		// Users book for themselves, assigning somebody else needs a permission
+		if principal := helpers.GetPrincipal(r); principal.ID != req.UserID && !principal.Can(helpers.PermEventsAssign) {
+			helpers.HttpError(w, http.StatusUnauthorized, "you dont have permission for this route")
+			return
+		}
+
+		event, err := eventRepo.GetEventById(eventId)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get event")
+			return
+		}
+		if event == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Event not found")
+			return
+		}
+
 		user, err := userRepo.GetUserById(req.UserID)
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, fmt.Sprintf("could not find the user with id '%d'", req.UserID))
@@ -359,6 +693,10 @@ func AssignEvent(eventRepo repos.EventInterface, userRepo *repos.UserRepository)
 			helpers.HttpError(w, http.StatusNotFound, fmt.Sprintf("user with id '%d' not found", req.UserID))
 			return
 		}
+		if helpers.RequireEmailVerification() && !user.EmailVerified {
+			helpers.HttpError(w, http.StatusForbidden, fmt.Sprintf("user with id '%d' has to verify their email address before booking", req.UserID))
+			return
+		}
 
 		err = eventRepo.RegisterUserToEvent(user.ID, eventId)
 		if err != nil {
@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/responses"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// canImpersonate lets admins that logged in themselves impersonate, which
+// rules out API keys and impersonating from within an impersonation.
+func canImpersonate(p *helpers.Principal) bool {
+	return helpers.SessionOnly(p) && p.Can(helpers.PermUsersImpersonate)
+}
+
+// ImpersonateUser hands out a short-lived access token of the user in the
+// route that names the admin asking for it. Users that may impersonate others
+// can't be impersonated themselves, so nobody gains permissions this way.
+func ImpersonateUser(userRepo repos.UserInterface, authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := parseSessionsUser(w, r, userRepo)
+		if !ok {
+			return
+		}
+
+		principal := helpers.GetPrincipal(r)
+		if userId == principal.ID {
+			helpers.HttpError(w, http.StatusBadRequest, "you can't impersonate yourself")
+			return
+		}
+
+		user, err := authRepo.GetAuthUserById(userId)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "Could not retrieve user")
+			return
+		}
+		if user == nil {
+			helpers.HttpError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if helpers.HasPermission(user.Role, helpers.PermUsersImpersonate) {
+			helpers.HttpError(w, http.StatusForbidden, "users that can impersonate others can't be impersonated")
+			return
+		}
+
+		orgID, err := authRepo.GetDefaultOrganization(user.ID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't start the impersonation")
+			return
+		}
+
+		actor := helpers.Actor{ID: principal.ID, Username: principal.Username}
+		token, err := helpers.NewImpersonationToken(actor, user.ID, user.Username, user.Role, orgID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't start the impersonation")
+			return
+		}
+
+		entry := repos.ImpersonationEntry{
+			ActorID: actor.ID,
+			UserID:  user.ID,
+			TokenID: token.ID,
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Status:  http.StatusCreated,
+			IP:      helpers.ClientIP(r),
+		}
+		// Nobody gets to impersonate without it showing up in the audit log
+		if err := authRepo.RecordImpersonation(entry); err != nil {
+			log.Printf("Failed to record impersonation: %v", err)
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't start the impersonation")
+			return
+		}
+		log.Printf("%s (%d) started impersonating %s (%d)", actor.Username, actor.ID, user.Username, user.ID)
+
+		res := &responses.ImpersonationResponse{
+			Token:     token.Token,
+			ExpiresIn: int64(time.Until(token.ExpiresAt).Seconds()),
+			UserID:    user.ID,
+			Username:  user.Username,
+		}
+
+		helpers.HttpJson(w, http.StatusCreated, res)
+	}
+}
+
+// GetImpersonationLog lists what was done while impersonating, narrowed down
+// by ?actorId= and ?userId=, the latest ?limit= entries, 100 by default.
+func GetImpersonationLog(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ids [2]int64
+		for i, name := range []string{"actorId", "userId"} {
+			value := r.URL.Query().Get(name)
+			if value == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				helpers.HttpError(w, http.StatusBadRequest, "invalid "+name+", pass a valid one")
+				return
+			}
+			ids[i] = id
+		}
+
+		limit := 100
+		if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+			limit = min(l, 1000)
+		}
+
+		entries, err := authRepo.GetImpersonationLog(ids[0], ids[1], limit)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the impersonation log")
+			return
+		}
+
+		res := &responses.ImpersonationLogResponse{
+			Entries: entries,
+			Count:   len(entries),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/responses"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// loginLockoutPolicy is how failed logins of one username are slowed down.
+func loginLockoutPolicy() repos.LockoutPolicy {
+	return repos.LockoutPolicy{
+		Threshold:   helpers.GetEnvInt("LOGIN_BACKOFF_THRESHOLD", 3),
+		MaxFailures: helpers.GetEnvInt("LOGIN_MAX_FAILURES", 10),
+		BaseDelay:   helpers.GetEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+		Lockout:     helpers.GetEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		Window:      helpers.GetEnvDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+	}
+}
+
+// ipLockoutPolicy is the same for one IP address, with higher limits since
+// many users may share an address.
+func ipLockoutPolicy() repos.LockoutPolicy {
+	policy := loginLockoutPolicy()
+	policy.Threshold = helpers.GetEnvInt("LOGIN_IP_BACKOFF_THRESHOLD", 20)
+	policy.MaxFailures = helpers.GetEnvInt("LOGIN_IP_MAX_FAILURES", 100)
+	return policy
+}
+
+const invalidCredentials = "invalid username or password"
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// checkDummyPassword spends the time of a real password check, so a missing
+// user can't be told apart from a wrong password by the response time.
+func checkDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = helpers.HashPassword("not the password of anyone")
+	})
+	helpers.CheckPasswordHash(password, dummyHash)
+}
+
+// loginLockedOut answers 429 when the username or the client IP is blocked
+// from logging in for now.
+func loginLockedOut(w http.ResponseWriter, authRepo repos.AuthInterface, username, ip string) bool {
+	var lockedUntil *time.Time
+	for _, key := range [][2]string{{repos.LoginFailureUsername, username}, {repos.LoginFailureIP, ip}} {
+		until, err := authRepo.GetLoginLockout(key[0], key[1])
+		if err != nil {
+			log.Printf("Failed to check login lockout: %v", err)
+			continue
+		}
+		if until != nil && (lockedUntil == nil || until.After(*lockedUntil)) {
+			lockedUntil = until
+		}
+	}
+
+	if lockedUntil == nil {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(*lockedUntil).Seconds()))))
+	helpers.HttpError(w, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+	return true
+}
+
+func recordLoginFailure(authRepo repos.AuthInterface, username, ip string) {
+	if _, err := authRepo.RecordLoginFailure(repos.LoginFailureUsername, username, loginLockoutPolicy()); err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+	}
+	if _, err := authRepo.RecordLoginFailure(repos.LoginFailureIP, ip, ipLockoutPolicy()); err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+	}
+}
+
+// GetLoginFailures lists the usernames and IPs with recent failed logins,
+// the locked out ones first.
+func GetLoginFailures(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		failures, err := authRepo.GetLoginFailures(time.Now().Add(-loginLockoutPolicy().Window))
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the login failures")
+			return
+		}
+
+		res := &responses.LoginFailuresResponse{
+			Failures: failures,
+			Count:    len(failures),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// ClearLoginFailures lifts the lockout of ?username= or ?ip=.
+func ClearLoginFailures(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kind, value := repos.LoginFailureUsername, r.URL.Query().Get("username")
+		if value == "" {
+			kind, value = repos.LoginFailureIP, r.URL.Query().Get("ip")
+		}
+		if value == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "pass the username or ip to clear")
+			return
+		}
+
+		cleared, err := authRepo.ClearLoginFailures(kind, value)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not clear the login failures")
+			return
+		}
+		if !cleared {
+			helpers.HttpError(w, http.StatusNotFound, "No login failures found")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "the login failures of " + kind + " " + value + " were cleared",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
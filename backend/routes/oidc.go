@@ -0,0 +1,315 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/responses"
+	"immodi/submission-backend/sso"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcBrowserCookie ties a login to the browser that started it, so a login
+// link sent to someone else can't be finished in their browser.
+const oidcBrowserCookie = "oidc_login"
+
+// startOIDCLogin remembers a new login, linking the account of userID when
+// set, and returns where to send the user to sign in.
+func startOIDCLogin(w http.ResponseWriter, r *http.Request, authRepo repos.AuthInterface, provider *sso.Provider, userID *int64) (string, error) {
+	state, err := helpers.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := helpers.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	browser, err := helpers.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	login := repos.OIDCLogin{
+		StateHash:    helpers.HashToken(state),
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		UserID:       userID,
+		BrowserHash:  helpers.HashToken(browser),
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, login.Nonce, login.CodeVerifier)
+	if err != nil {
+		return "", err
+	}
+	if err := authRepo.CreateOIDCLogin(login); err != nil {
+		return "", err
+	}
+
+	setOIDCBrowserCookie(w, provider, browser, oidcLoginTTL)
+	return authURL, nil
+}
+
+// setOIDCBrowserCookie sets the cookie only the callback gets to see. It has
+// to be Lax rather than Strict, the identity provider redirecting back is a
+// cross-site navigation.
+func setOIDCBrowserCookie(w http.ResponseWriter, provider *sso.Provider, value string, maxAge time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcBrowserCookie,
+		Value:    value,
+		Path:     "/auth/oidc/callback",
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(provider.Config.RedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OIDCLogin sends the browser to the identity provider.
+func OIDCLogin(authRepo repos.AuthInterface, provider *sso.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if provider == nil {
+			helpers.HttpError(w, http.StatusNotFound, "single sign-on is not configured")
+			return
+		}
+
+		authURL, err := startOIDCLogin(w, r, authRepo, provider, nil)
+		if err != nil {
+			log.Printf("Failed to start single sign-on login: %v", err)
+			helpers.HttpError(w, http.StatusBadGateway, "failed to reach the identity provider")
+			return
+		}
+
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// LinkOIDC starts a login that links the identity provider account to the
+// current user instead of logging in. The frontend has to send the user to
+// the returned URL itself, as the redirect can't carry the access token.
+func LinkOIDC(authRepo repos.AuthInterface, provider *sso.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if provider == nil {
+			helpers.HttpError(w, http.StatusNotFound, "single sign-on is not configured")
+			return
+		}
+
+		principal := helpers.GetPrincipal(r)
+		authURL, err := startOIDCLogin(w, r, authRepo, provider, &principal.ID)
+		if err != nil {
+			log.Printf("Failed to start single sign-on login: %v", err)
+			helpers.HttpError(w, http.StatusBadGateway, "failed to reach the identity provider")
+			return
+		}
+
+		res := &responses.AuthorizationURLResponse{
+			AuthorizationURL: authURL,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// OIDCCallback finishes a login once the identity provider redirected back.
+// Users are found by their linked identity, or created when there is none
+// and OIDC_AUTO_PROVISION allows it. With OIDC_ADMIN_GROUP set the admin role
+// follows the groups of the user on every login.
+func OIDCCallback(userRepo repos.UserInterface, authRepo repos.AuthInterface, orgRepo repos.OrganizationInterface, provider *sso.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if provider == nil {
+			helpers.HttpError(w, http.StatusNotFound, "single sign-on is not configured")
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Get("state") == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing state")
+			return
+		}
+
+		login, err := authRepo.ConsumeOIDCLogin(helpers.HashToken(query.Get("state")))
+		if errors.Is(err, repos.ErrNotFound) {
+			writeOIDCError(w, r, http.StatusBadRequest, "login expired or was already used, please try again")
+			return
+		}
+		if err != nil {
+			writeOIDCError(w, r, http.StatusInternalServerError, "failed to finish login")
+			return
+		}
+
+		// The state alone only proves someone started this login, not that it
+		// was this browser
+		cookie, err := r.Cookie(oidcBrowserCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(helpers.HashToken(cookie.Value)), []byte(login.BrowserHash)) != 1 {
+			writeOIDCError(w, r, http.StatusBadRequest, "login was started in another browser, please try again")
+			return
+		}
+		setOIDCBrowserCookie(w, provider, "", -time.Second)
+
+		if providerError := query.Get("error"); providerError != "" {
+			message := query.Get("error_description")
+			if message == "" {
+				message = providerError
+			}
+			writeOIDCError(w, r, http.StatusUnauthorized, "identity provider refused the login: "+message)
+			return
+		}
+		if query.Get("code") == "" {
+			writeOIDCError(w, r, http.StatusBadRequest, "missing code")
+			return
+		}
+
+		identity, err := provider.Exchange(r.Context(), query.Get("code"), login.Nonce, login.CodeVerifier)
+		if err != nil {
+			log.Printf("Failed to finish single sign-on login: %v", err)
+			writeOIDCError(w, r, http.StatusUnauthorized, "identity provider login failed")
+			return
+		}
+
+		if login.UserID != nil {
+			linkOIDCIdentity(w, r, authRepo, *login.UserID, identity)
+			return
+		}
+
+		user, status, err := oidcUser(authRepo, orgRepo, identity)
+		if err != nil {
+			writeOIDCError(w, r, status, err.Error())
+			return
+		}
+
+		if role := identity.Role(provider.Config.AdminGroup, user.Role); role != user.Role {
+			if err := userRepo.UpdateUserRole(user.ID, role); err != nil {
+				writeOIDCError(w, r, http.StatusInternalServerError, "failed to update user role")
+				return
+			}
+			// Tokens of other sessions still carry the old role
+			if err := authRepo.RevokeUserSessions(user.ID); err != nil {
+				log.Printf("Failed to revoke sessions of user %d: %v", user.ID, err)
+			}
+			user.Role = role
+		}
+
+		challenge, err := secondFactorChallenge(authRepo, user)
+		if err != nil {
+			writeOIDCError(w, r, http.StatusInternalServerError, "failed to get user")
+			return
+		}
+		if challenge != nil {
+			writeOIDCResult(w, r, http.StatusOK, challenge)
+			return
+		}
+
+		res, err := createSession(r, authRepo, user)
+		if err != nil {
+			writeOIDCError(w, r, http.StatusInternalServerError, "failed to generate token")
+			return
+		}
+
+		writeOIDCResult(w, r, http.StatusCreated, res)
+	}
+}
+
+// oidcUser finds or provisions the user of identity, returning the status to
+// answer with when that's not possible.
+func oidcUser(authRepo repos.AuthInterface, orgRepo repos.OrganizationInterface, identity *sso.Identity) (*repos.AuthUser, int, error) {
+	user, err := authRepo.GetAuthUserByIdentity(identity.Issuer, identity.Subject)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("failed to get user")
+	}
+	if user != nil {
+		return user, 0, nil
+	}
+
+	if helpers.GetEnv("OIDC_AUTO_PROVISION", "true") != "true" {
+		return nil, http.StatusForbidden, errors.New("no account is linked to this identity, log in and link it first")
+	}
+	if identity.Username == "" {
+		return nil, http.StatusBadRequest, errors.New("identity provider didn't send a username")
+	}
+
+	user, err = authRepo.ProvisionUser(identity.Username, "user", identity.Issuer, identity.Subject)
+	if errors.Is(err, repos.ErrAlreadyExists) {
+		return nil, http.StatusConflict, fmt.Errorf("an account named '%s' already exists, log in and link it first", identity.Username)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("failed to create user")
+	}
+	joinDefaultOrganization(orgRepo, user.ID)
+	return user, 0, nil
+}
+
+func linkOIDCIdentity(w http.ResponseWriter, r *http.Request, authRepo repos.AuthInterface, userID int64, identity *sso.Identity) {
+	linked, err := authRepo.GetAuthUserByIdentity(identity.Issuer, identity.Subject)
+	if err != nil {
+		writeOIDCError(w, r, http.StatusInternalServerError, "failed to link account")
+		return
+	}
+
+	if linked == nil {
+		err = authRepo.LinkIdentity(userID, identity.Issuer, identity.Subject)
+		if errors.Is(err, repos.ErrAlreadyExists) {
+			writeOIDCError(w, r, http.StatusConflict, "this identity is linked to another account")
+			return
+		}
+		if err != nil {
+			writeOIDCError(w, r, http.StatusInternalServerError, "failed to link account")
+			return
+		}
+	} else if linked.ID != userID {
+		writeOIDCError(w, r, http.StatusConflict, "this identity is linked to another account")
+		return
+	}
+
+	res := &responses.MessageResponse{
+		Message: "account linked",
+	}
+
+	writeOIDCResult(w, r, http.StatusOK, res)
+}
+
+// writeOIDCResult hands the outcome of the callback to the frontend. With
+// OIDC_FRONTEND_URL set the browser is sent there with the fields of res in
+// the fragment, which never reaches any server log, otherwise it's JSON.
+func writeOIDCResult(w http.ResponseWriter, r *http.Request, status int, res any) {
+	frontendURL := helpers.GetEnv("OIDC_FRONTEND_URL", "")
+	if frontendURL == "" {
+		helpers.HttpJson(w, status, res)
+		return
+	}
+
+	var fields map[string]any
+	raw, err := json.Marshal(res)
+	if err == nil {
+		err = json.Unmarshal(raw, &fields)
+	}
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to finish login")
+		return
+	}
+
+	fragment := url.Values{}
+	for key, value := range fields {
+		fragment.Set(key, fmt.Sprint(value))
+	}
+
+	http.Redirect(w, r, frontendURL+"#"+fragment.Encode(), http.StatusFound)
+}
+
+func writeOIDCError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if helpers.GetEnv("OIDC_FRONTEND_URL", "") == "" {
+		helpers.HttpError(w, status, message)
+		return
+	}
+	writeOIDCResult(w, r, status, map[string]string{"error": message})
+}
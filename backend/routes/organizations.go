@@ -0,0 +1,434 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"immodi/submission-backend/routes/responses"
+	helper_structs "immodi/submission-backend/structs"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func OrganizationsRouter(r chi.Router, db *sql.DB, api *helper_structs.API) {
+	manage := helpers.Require(helpers.PermOrgsManage)
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, manage, GetOrganizations(api.OrgRepo))
+	})
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, manage, CreateOrganization(api.OrgRepo))
+	})
+	r.Get("/mine", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, nil, GetOwnOrganizations(api.OrgRepo))
+	})
+	r.Get("/{org}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, memberOr(api.OrgRepo, r, helpers.PermOrgsManage), GetOrganization(api.OrgRepo))
+	})
+	r.Delete("/{org}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, manage, DeleteOrganization(api.OrgRepo))
+	})
+
+	r.Get("/{org}/members", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, membersManager(api.OrgRepo, r), GetMembers(api.OrgRepo))
+	})
+	r.Post("/{org}/members", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, membersManager(api.OrgRepo, r), AddMember(api.OrgRepo, api.UserRepo, api.RoleRepo))
+	})
+	r.Put("/{org}/members/{userId}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, membersManager(api.OrgRepo, r), UpdateMemberRole(api.OrgRepo, api.RoleRepo))
+	})
+	r.Delete("/{org}/members/{userId}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, membersManager(api.OrgRepo, r), RemoveMember(api.OrgRepo))
+	})
+}
+
+// orgEvents and orgUsers scope the repositories to the organization the
+// request acts in, so handlers only ever see its events and members. Requests
+// acting in none see nothing.
+func orgEvents(api *helper_structs.API, r *http.Request) *repos.EventRepository {
+	return api.EventRepo.InOrganization(activeOrganization(r))
+}
+
+func orgUsers(api *helper_structs.API, r *http.Request) *repos.UserRepository {
+	return api.UserRepo.InOrganization(activeOrganization(r))
+}
+
+func activeOrganization(r *http.Request) int64 {
+	if p := helpers.GetPrincipal(r); p != nil {
+		return p.OrgID
+	}
+	return 0
+}
+
+// requireOrganization answers requests acting in no organization, for handlers
+// that create something in it. Their repositories are scoped to organization
+// 0, which doesn't exist.
+func requireOrganization(w http.ResponseWriter, r *http.Request) bool {
+	if activeOrganization(r) == 0 {
+		helpers.HttpError(w, http.StatusForbidden, "you aren't acting in any organization, join one or pick it with the "+helpers.OrganizationHeader+" header")
+		return false
+	}
+	return true
+}
+
+var organizationSlugPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{1,31}$`)
+
+// memberOr lets the members of the organization in the route through, and
+// everybody else with permission.
+func memberOr(orgRepo repos.OrganizationInterface, r *http.Request, permission string) func(p *helpers.Principal) bool {
+	return func(p *helpers.Principal) bool {
+		if p.Can(permission) {
+			return true
+		}
+
+		membership, err := orgRepo.GetMembership(p.ID, chi.URLParam(r, "org"))
+		if err != nil {
+			log.Printf("Failed to check membership of user %d: %v", p.ID, err)
+			return false
+		}
+		return membership != nil && membership.Role != ""
+	}
+}
+
+// membersManager lets through who may manage members while acting in the
+// organization in the route, and whoever manages every organization.
+func membersManager(orgRepo repos.OrganizationInterface, r *http.Request) func(p *helpers.Principal) bool {
+	return func(p *helpers.Principal) bool {
+		if p.Can(helpers.PermOrgsManage) {
+			return true
+		}
+		if p.OrgID == 0 || !p.Can(helpers.PermMembersManage) {
+			return false
+		}
+
+		org, err := orgRepo.GetOrganization(chi.URLParam(r, "org"))
+		if err != nil {
+			if !errors.Is(err, repos.ErrNotFound) {
+				log.Printf("Failed to get organization: %v", err)
+			}
+			return false
+		}
+		return org.ID == p.OrgID
+	}
+}
+
+// organizationFromRoute answers 404 when the organization in the route
+// doesn't exist.
+func organizationFromRoute(w http.ResponseWriter, r *http.Request, orgRepo repos.OrganizationInterface) (*repos.Organization, bool) {
+	org, err := orgRepo.GetOrganization(chi.URLParam(r, "org"))
+	if errors.Is(err, repos.ErrNotFound) {
+		helpers.HttpError(w, http.StatusNotFound, "organization not found")
+		return nil, false
+	}
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to get organization")
+		return nil, false
+	}
+	return org, true
+}
+
+// joinDefaultOrganization adds a new user to DEFAULT_ORGANIZATION. Without
+// such an organization they start in none and have to be added to one.
+func joinDefaultOrganization(orgRepo repos.OrganizationInterface, userID int64) {
+	slug := helpers.GetEnv("DEFAULT_ORGANIZATION", "default")
+	if slug == "" {
+		return
+	}
+
+	org, err := orgRepo.GetOrganization(slug)
+	if err != nil {
+		log.Printf("Failed to add user %d to organization %s: %v", userID, slug, err)
+		return
+	}
+	if err := orgRepo.AddMember(org.ID, userID, helpers.DefaultRole); err != nil && !errors.Is(err, repos.ErrAlreadyExists) {
+		log.Printf("Failed to add user %d to organization %s: %v", userID, slug, err)
+	}
+}
+
+// checkMemberRole defaults role to the role of new users, failing when there
+// is no such role.
+func checkMemberRole(w http.ResponseWriter, roleRepo repos.RoleInterface, role string) (string, bool) {
+	if role == "" {
+		return helpers.DefaultRole, true
+	}
+
+	_, err := roleRepo.GetRole(role)
+	if errors.Is(err, repos.ErrNotFound) {
+		helpers.HttpError(w, http.StatusBadRequest, fmt.Sprintf("unknown role '%s'", role))
+		return "", false
+	}
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to get role")
+		return "", false
+	}
+	return role, true
+}
+
+func GetOrganizations(orgRepo repos.OrganizationInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		organizations, err := orgRepo.GetOrganizations()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get organizations")
+			return
+		}
+
+		res := &responses.OrganizationsResponse{
+			Organizations: organizations,
+			Count:         len(organizations),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func CreateOrganization(orgRepo repos.OrganizationInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.OrganizationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+
+		if !organizationSlugPattern.MatchString(req.Slug) {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid slug, use 2 to 32 lowercase letters, digits and '-' starting with a letter")
+			return
+		}
+		if req.Name == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing name")
+			return
+		}
+
+		id, err := orgRepo.CreateOrganization(req.Slug, req.Name)
+		if errors.Is(err, repos.ErrAlreadyExists) {
+			helpers.HttpError(w, http.StatusConflict, fmt.Sprintf("organization '%s' already exists", req.Slug))
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to create organization")
+			return
+		}
+
+		org, err := orgRepo.GetOrganization(strconv.FormatInt(id, 10))
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "Organization creation succeeded but fetch failed")
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusCreated, org)
+	}
+}
+
+// GetOwnOrganizations lists the organizations of the current user, which
+// they can pick with the X-Organization header.
+func GetOwnOrganizations(orgRepo repos.OrganizationInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := helpers.GetPrincipal(r)
+		memberships, err := orgRepo.GetUserMemberships(principal.ID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get organizations")
+			return
+		}
+
+		res := &responses.MembershipsResponse{
+			Active:      principal.OrgID,
+			Memberships: memberships,
+			Count:       len(memberships),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func GetOrganization(orgRepo repos.OrganizationInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, ok := organizationFromRoute(w, r, orgRepo)
+		if !ok {
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusOK, org)
+	}
+}
+
+// DeleteOrganization only deletes organizations without events.
+func DeleteOrganization(orgRepo repos.OrganizationInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, ok := organizationFromRoute(w, r, orgRepo)
+		if !ok {
+			return
+		}
+
+		err := orgRepo.DeleteOrganization(org.ID)
+		if errors.Is(err, repos.ErrInUse) {
+			helpers.HttpError(w, http.StatusConflict, "organization still has events, purge them first")
+			return
+		}
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "organization not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to delete organization")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "organization deleted",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func writeMembers(w http.ResponseWriter, orgRepo repos.OrganizationInterface, orgID int64, status int) {
+	members, err := orgRepo.GetMembers(orgID)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to get members")
+		return
+	}
+
+	res := &responses.MembersResponse{
+		OrganizationId: orgID,
+		Members:        members,
+		Count:          len(members),
+	}
+
+	helpers.HttpJson(w, status, res)
+}
+
+func GetMembers(orgRepo repos.OrganizationInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, ok := organizationFromRoute(w, r, orgRepo)
+		if !ok {
+			return
+		}
+
+		writeMembers(w, orgRepo, org.ID, http.StatusOK)
+	}
+}
+
+// AddMember lets a user see and act in the organization with the given role,
+// next to the organizations they are in already.
+func AddMember(orgRepo repos.OrganizationInterface, userRepo repos.UserInterface, roleRepo repos.RoleInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, ok := organizationFromRoute(w, r, orgRepo)
+		if !ok {
+			return
+		}
+
+		var req requests.MemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.UserID == 0 {
+			helpers.HttpError(w, http.StatusBadRequest, "missing user id")
+			return
+		}
+		role, ok := checkMemberRole(w, roleRepo, req.Role)
+		if !ok {
+			return
+		}
+
+		user, err := userRepo.GetUserById(req.UserID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't get user")
+			return
+		}
+		if user == nil {
+			helpers.HttpError(w, http.StatusNotFound, "User not found")
+			return
+		}
+
+		err = orgRepo.AddMember(org.ID, user.ID, role)
+		if errors.Is(err, repos.ErrAlreadyExists) {
+			helpers.HttpError(w, http.StatusConflict, "user is already a member of this organization")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't add the member")
+			return
+		}
+
+		writeMembers(w, orgRepo, org.ID, http.StatusCreated)
+	}
+}
+
+// UpdateMemberRole changes the role of a member in the organization, their
+// own role stays as it is. It applies on their next request.
+func UpdateMemberRole(orgRepo repos.OrganizationInterface, roleRepo repos.RoleInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, ok := organizationFromRoute(w, r, orgRepo)
+		if !ok {
+			return
+		}
+
+		userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid user id, pass a valid one")
+			return
+		}
+
+		var req requests.MemberRoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.Role == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing role")
+			return
+		}
+		role, ok := checkMemberRole(w, roleRepo, req.Role)
+		if !ok {
+			return
+		}
+
+		err = orgRepo.UpdateMemberRole(org.ID, userId, role)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "user isn't a member of this organization")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't change the role of the member")
+			return
+		}
+
+		writeMembers(w, orgRepo, org.ID, http.StatusOK)
+	}
+}
+
+func RemoveMember(orgRepo repos.OrganizationInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, ok := organizationFromRoute(w, r, orgRepo)
+		if !ok {
+			return
+		}
+
+		userId, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid user id, pass a valid one")
+			return
+		}
+
+		err = orgRepo.RemoveMember(org.ID, userId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "user isn't a member of this organization")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "couldn't remove the member")
+			return
+		}
+
+		writeMembers(w, orgRepo, org.ID, http.StatusOK)
+	}
+}
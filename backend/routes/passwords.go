@@ -0,0 +1,189 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/mail"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"immodi/submission-backend/routes/responses"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// writePasswordPolicyError answers 400 with every broken rule when err comes
+// from the password policy.
+func writePasswordPolicyError(w http.ResponseWriter, err error) bool {
+	var policyErr *helpers.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	res := &responses.PasswordPolicyErrorResponse{
+		Message: policyErr.Error(),
+		Errors:  policyErr.Violations,
+	}
+
+	helpers.HttpJson(w, http.StatusBadRequest, res)
+	return true
+}
+
+// ChangePassword replaces the password of the logged in user. Every session is
+// ended, including the current one, so a fresh session is returned in its place.
+func ChangePassword(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.PasswordChangeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.OldPassword == "" || req.NewPassword == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing old or new password")
+			return
+		}
+
+		user, _, ok := currentSessionUser(w, r, authRepo)
+		if !ok {
+			return
+		}
+
+		// A stolen access token mustn't allow guessing the password faster than
+		// logging in does
+		ip := helpers.ClientIP(r)
+		if loginLockedOut(w, authRepo, user.Username, ip) {
+			return
+		}
+		if !helpers.CheckPasswordHash(req.OldPassword, user.PasswordHash) {
+			recordLoginFailure(authRepo, user.Username, ip)
+			helpers.HttpError(w, http.StatusUnauthorized, "invalid password")
+			return
+		}
+
+		err := authRepo.ChangePassword(user.ID, req.NewPassword)
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not change the password")
+			return
+		}
+
+		issueTokens(w, r, authRepo, user)
+	}
+}
+
+// ForgotPassword mails a single use reset link to the verified email address
+// of the user, nothing is sent to users without one. It answers the same
+// whether or not the user exists, so it can't be used to find accounts.
+func ForgotPassword(userRepo repos.UserInterface, authRepo repos.AuthInterface, mailer mail.Sender) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.ForgotPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.Username == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing username")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "if the account exists, a password reset link was sent to it",
+		}
+
+		user, err := authRepo.GetAuthUserByUsername(req.Username)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get user")
+			return
+		}
+		if user == nil {
+			helpers.HttpJson(w, http.StatusAccepted, res)
+			return
+		}
+
+		contact, err := userRepo.GetUserById(user.ID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get user")
+			return
+		}
+		// An address that was never verified may belong to someone else
+		if contact == nil || contact.Email == nil || !contact.EmailVerified {
+			log.Printf("No password reset mail for user %d, they have no verified email address", user.ID)
+			helpers.HttpJson(w, http.StatusAccepted, res)
+			return
+		}
+
+		token, err := helpers.RandomToken(32)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to generate token")
+			return
+		}
+
+		ttl := helpers.GetEnvDuration("PASSWORD_RESET_TTL", time.Hour)
+		if err := authRepo.CreatePasswordResetToken(user.ID, helpers.HashToken(token), time.Now().Add(ttl)); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to generate token")
+			return
+		}
+
+		msg := mail.Message{
+			To:      *contact.Email,
+			Subject: "Reset your password",
+			Body: fmt.Sprintf(
+				"Someone asked to reset the password of %s.\n\nOpen %s to choose a new one, the link works once and expires in %s.\n\nIf it wasn't you, ignore this email.",
+				user.Username, passwordResetLink(token), ttl,
+			),
+		}
+
+		// Sent in the background so the response time doesn't tell whether the user exists
+		go func() {
+			if err := mailer.Send(msg); err != nil {
+				log.Printf("Failed to send password reset mail for user %d: %v", user.ID, err)
+			}
+		}()
+
+		helpers.HttpJson(w, http.StatusAccepted, res)
+	}
+}
+
+func passwordResetLink(token string) string {
+	return helpers.GetEnv("PASSWORD_RESET_URL", "http://localhost:5173/reset-password") + "?token=" + url.QueryEscape(token)
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword, ending
+// every session of the user.
+func ResetPassword(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.ResetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.Token == "" || req.NewPassword == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing token or new password")
+			return
+		}
+
+		err := authRepo.ResetPassword(helpers.HashToken(req.Token), req.NewPassword)
+		if writePasswordPolicyError(w, err) {
+			return
+		}
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusBadRequest, "the reset token is invalid or has expired")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not reset the password")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "the password was reset, please log in again",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"errors"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/imaging"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// UpdateOwnProfile applies a merge patch to the profile of the user, an avatar
+// in it goes through the same image pipeline as event images.
+func UpdateOwnProfile(userRepo repos.UserInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+			helpers.HttpError(w, http.StatusUnsupportedMediaType, "PATCH expects an application/merge-patch+json body")
+			return
+		}
+
+		// Leave room for an avatar the size of the largest image, base64 encoded
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxImageBytes())*4/3+1<<16)
+		patch, err := requests.DecodeProfileMergePatch(r.Body)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var processed *imaging.ProcessedImage
+		if patch.Avatar != nil && len(*patch.Avatar) > 0 {
+			var ok bool
+			if processed, ok = processImage(w, *patch.Avatar); !ok {
+				return
+			}
+		}
+
+		userId := helpers.GetPrincipal(r).ID
+		err = userRepo.UpdateProfile(userId, patch.Fields)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not update the profile")
+			return
+		}
+
+		if processed != nil {
+			image, err := imageRepo.SaveImage(processed)
+			if err != nil {
+				helpers.HttpError(w, http.StatusInternalServerError, "could not store the avatar")
+				return
+			}
+			if !replaceAvatar(w, userRepo, imageRepo, userId, &image.ID) {
+				imageRepo.DeleteImage(image.ID)
+				return
+			}
+		} else if patch.Avatar != nil {
+			if !replaceAvatar(w, userRepo, imageRepo, userId, nil) {
+				return
+			}
+		}
+
+		user, err := userRepo.GetUserById(userId)
+		if err != nil || user == nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "Profile update succeeded but fetch failed")
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusOK, userResponse(user))
+	}
+}
+
+func replaceAvatar(w http.ResponseWriter, userRepo repos.UserInterface, imageRepo repos.ImageInterface, userId int64, imageID *int64) bool {
+	previous, err := userRepo.SetAvatar(userId, imageID)
+	if errors.Is(err, repos.ErrNotFound) {
+		helpers.HttpError(w, http.StatusNotFound, "User not found")
+		return false
+	}
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "could not update the avatar")
+		return false
+	}
+
+	if previous != nil {
+		if err := imageRepo.DeleteImageIfUnused(*previous); err != nil {
+			// The purge job picks up images that are no longer referenced
+			log.Printf("Failed to delete replaced avatar %d: %v", *previous, err)
+		}
+	}
+	return true
+}
+
+// GetUserAvatar serves one size of the avatar of a user. Like event images it
+// is public so it can be used directly in an <img> tag.
+func GetUserAvatar(userRepo repos.UserInterface, imageRepo repos.ImageInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid user ID, pass a valid one")
+			return
+		}
+
+		size := r.URL.Query().Get("size")
+		if size == "" {
+			size = imaging.SizeOriginal
+		}
+		if !imaging.IsValidSize(size) {
+			helpers.HttpError(w, http.StatusBadRequest, "unknown image size")
+			return
+		}
+
+		user, err := userRepo.GetUserById(userId)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "Could not retrieve user")
+			return
+		}
+		if user == nil || user.AvatarImageID == nil {
+			helpers.HttpError(w, http.StatusNotFound, "Avatar not found")
+			return
+		}
+
+		serveImageVariant(w, r, imageRepo, *user.AvatarImageID, size)
+	}
+}
@@ -1,6 +1,14 @@
 package requests
 
-import "immodi/submission-backend/repos"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"io"
+	"time"
+)
 
 type EventRequest struct {
 	Name         string                   `json:"name"`
@@ -13,6 +21,191 @@ type EventRequest struct {
 	Translations []repos.EventTranslation `json:"translations"`
 }
 
+type EventTranslationRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Venue       string `json:"venue"`
+}
+
+// NormalizeTranslations validates the translation languages as BCP 47 tags and
+// stores them in canonical form, each language may only appear once.
+func (req *EventRequest) NormalizeTranslations() error {
+	seen := map[string]bool{}
+	for i, t := range req.Translations {
+		language, err := helpers.CanonicalLanguage(t.Language)
+		if err != nil {
+			return err
+		}
+		if seen[language] {
+			return fmt.Errorf("language %s is translated more than once", language)
+		}
+		seen[language] = true
+		req.Translations[i].Language = language
+	}
+	return nil
+}
+
 type EventAssignRequest struct {
 	UserID int64 `json:"userId"`
 }
+
+type EventMediaRequest struct {
+	Caption      string                        `json:"caption"`
+	AltText      string                        `json:"altText"`
+	Translations []repos.EventMediaTranslation `json:"translations"`
+}
+
+type EventMediaOrderRequest struct {
+	MediaIDs []int64 `json:"mediaIds"`
+}
+
+// Validate checks that every translation names a valid language, and only
+// once, normalizing the languages to their canonical form.
+func (req *EventMediaRequest) Validate() error {
+	seen := map[string]bool{}
+	for i, t := range req.Translations {
+		language, err := helpers.CanonicalLanguage(t.Language)
+		if err != nil {
+			return err
+		}
+		if seen[language] {
+			return fmt.Errorf("language %s is translated more than once", language)
+		}
+		seen[language] = true
+		req.Translations[i].Language = language
+	}
+	return nil
+}
+
+// EventMergePatch is a decoded merge patch, the image is kept apart from the
+// other fields because it goes through the image pipeline rather than the row.
+// A non-nil Image pointing at an empty slice removes the image.
+type EventMergePatch struct {
+	Fields repos.EventPatch
+	Image  *[]byte
+}
+
+// DecodeEventMergePatch reads a JSON Merge Patch (RFC 7396) document for an
+// event. Translations are keyed by language so each one can be patched or
+// removed on its own, e.g. {"price": 20, "translations": {"ar": {"venue": "..."}, "fr": null}}.
+func DecodeEventMergePatch(body io.Reader) (*EventMergePatch, error) {
+	mergePatch := &EventMergePatch{}
+	patch := &mergePatch.Fields
+
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid merge patch document")
+	}
+
+	for field, raw := range doc {
+		var err error
+		switch field {
+		case "name":
+			patch.Name, err = requiredString(field, raw)
+		case "description":
+			patch.Description, err = requiredString(field, raw)
+		case "category":
+			patch.Category, err = requiredString(field, raw)
+		case "venue":
+			patch.Venue, err = requiredString(field, raw)
+		case "date":
+			var date *string
+			date, err = requiredString(field, raw)
+			if err == nil {
+				parsed, parseErr := time.Parse(time.RFC3339, *date)
+				if parseErr != nil {
+					return nil, fmt.Errorf("invalid date format, only RFC3339 is supported")
+				}
+				formatted := parsed.String()
+				patch.Date = &formatted
+			}
+		case "price":
+			var price float64
+			if isJsonNull(raw) || json.Unmarshal(raw, &price) != nil || price == 0 {
+				return nil, fmt.Errorf("price must be a non-zero number")
+			}
+			patch.Price = &price
+		case "image":
+			image := []byte{}
+			if !isJsonNull(raw) {
+				if err := json.Unmarshal(raw, &image); err != nil {
+					return nil, fmt.Errorf("image must be base64 encoded or null")
+				}
+			}
+			mergePatch.Image = &image
+		case "translations":
+			patch.Translations, err = decodeTranslationsPatch(raw)
+		default:
+			err = fmt.Errorf("unknown field '%s'", field)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mergePatch, nil
+}
+
+func decodeTranslationsPatch(raw json.RawMessage) (map[string]*repos.EventTranslationPatch, error) {
+	var byLanguage map[string]json.RawMessage
+	if isJsonNull(raw) || json.Unmarshal(raw, &byLanguage) != nil {
+		return nil, fmt.Errorf("translations must be an object keyed by language, set a language to null to remove it")
+	}
+
+	translations := make(map[string]*repos.EventTranslationPatch, len(byLanguage))
+	for tag, rawTranslation := range byLanguage {
+		language, err := helpers.CanonicalLanguage(tag)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := translations[language]; ok {
+			return nil, fmt.Errorf("language %s is patched more than once", language)
+		}
+		if isJsonNull(rawTranslation) {
+			translations[language] = nil
+			continue
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(rawTranslation, &fields); err != nil {
+			return nil, fmt.Errorf("translation '%s' must be an object or null", language)
+		}
+
+		tp := &repos.EventTranslationPatch{}
+		for field, rawValue := range fields {
+			value, err := requiredString("translations."+language+"."+field, rawValue)
+			if err != nil {
+				return nil, err
+			}
+			switch field {
+			case "name":
+				tp.Name = value
+			case "description":
+				tp.Description = value
+			case "venue":
+				tp.Venue = value
+			default:
+				return nil, fmt.Errorf("unknown field 'translations.%s.%s'", language, field)
+			}
+		}
+		translations[language] = tp
+	}
+
+	return translations, nil
+}
+
+func requiredString(field string, raw json.RawMessage) (*string, error) {
+	var value string
+	if isJsonNull(raw) || json.Unmarshal(raw, &value) != nil || value == "" {
+		return nil, fmt.Errorf("%s must be a non-empty string", field)
+	}
+	return &value, nil
+}
+
+func isJsonNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
+
+type EventOrganizerRequest struct {
+	UserID int64 `json:"userId"`
+}
@@ -0,0 +1,17 @@
+package requests
+
+type OrganizationRequest struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+// MemberRequest adds a user to an organization, Role defaulting to the role
+// of new users.
+type MemberRequest struct {
+	UserID int64  `json:"userId"`
+	Role   string `json:"role"`
+}
+
+type MemberRoleRequest struct {
+	Role string `json:"role"`
+}
@@ -0,0 +1,12 @@
+package requests
+
+type RoleCreateRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleUpdateRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
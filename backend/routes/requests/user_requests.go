@@ -1,11 +1,103 @@
 package requests
 
+import (
+	"encoding/json"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"io"
+	"slices"
+)
+
+// UserCreateRequest registers a user, Email and DisplayName being optional.
 type UserCreateRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Email       string `json:"email"`
+	DisplayName string `json:"displayName"`
 }
 
 type UserRoleUpdateRequest struct {
 	UserId int64  `json:"userId"`
 	Role   string `json:"role"`
 }
+
+// ProfileMergePatch is a decoded merge patch of the user's own profile, the
+// avatar is kept apart like the image of an EventMergePatch. A non-nil Avatar
+// pointing at an empty slice removes the avatar.
+type ProfileMergePatch struct {
+	Fields repos.ProfilePatch
+	Avatar *[]byte
+}
+
+var profileTextFields = []string{"displayName", "organization", "bio", "preferredLanguage", "timezone"}
+
+// DecodeProfileMergePatch reads a JSON Merge Patch (RFC 7396) document for the
+// user's profile, e.g. {"bio": "...", "timezone": "Africa/Cairo", "avatar": null}.
+// Text fields are cleared with null or an empty string.
+func DecodeProfileMergePatch(body io.Reader) (*ProfileMergePatch, error) {
+	mergePatch := &ProfileMergePatch{}
+	patch := &mergePatch.Fields
+
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid merge patch document")
+	}
+
+	for field, raw := range doc {
+		if field == "avatar" {
+			avatar := []byte{}
+			if !isJsonNull(raw) {
+				if err := json.Unmarshal(raw, &avatar); err != nil {
+					return nil, fmt.Errorf("avatar must be base64 encoded or null")
+				}
+			}
+			mergePatch.Avatar = &avatar
+			continue
+		}
+
+		if !slices.Contains(profileTextFields, field) {
+			return nil, fmt.Errorf("unknown field '%s'", field)
+		}
+		value, err := optionalString(field, raw)
+		if err != nil {
+			return nil, err
+		}
+
+		switch field {
+		case "displayName":
+			value, err = helpers.NormalizeDisplayName(value)
+			patch.DisplayName = &value
+		case "organization":
+			value, err = helpers.NormalizeProfileText("organization", value, 100, false)
+			patch.Organization = &value
+		case "bio":
+			value, err = helpers.NormalizeProfileText("bio", value, 500, true)
+			patch.Bio = &value
+		case "preferredLanguage":
+			if value != "" {
+				value, err = helpers.CanonicalLanguage(value)
+			}
+			patch.PreferredLanguage = &value
+		case "timezone":
+			value, err = helpers.NormalizeTimezone(value)
+			patch.Timezone = &value
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mergePatch, nil
+}
+
+func optionalString(field string, raw json.RawMessage) (string, error) {
+	var value string
+	if isJsonNull(raw) {
+		return "", nil
+	}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("%s must be a string or null", field)
+	}
+	return value, nil
+}
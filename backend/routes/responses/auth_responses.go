@@ -1,5 +1,97 @@
 package responses
 
+import (
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+)
+
+// AuthResponse carries a short-lived access token, ExpiresIn being its lifetime
+// in seconds, and the refresh token to get the next one with.
 type AuthResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type SessionsResponse struct {
+	Sessions []repos.Session `json:"sessions"`
+	Count    int             `json:"count"`
+}
+
+type LoginFailuresResponse struct {
+	Failures []repos.LoginFailures `json:"failures"`
+	Count    int                   `json:"count"`
+}
+
+// PasswordPolicyErrorResponse lists every password rule that was broken.
+type PasswordPolicyErrorResponse struct {
+	Message string                      `json:"message"`
+	Errors  []helpers.PasswordViolation `json:"errors"`
+}
+
+// TwoFactorChallengeResponse is returned by Login instead of tokens when the
+// user still has to pass, or first set up, a second factor.
+type TwoFactorChallengeResponse struct {
+	ChallengeToken     string `json:"challengeToken"`
+	ExpiresIn          int64  `json:"expiresIn"`
+	TwoFactorRequired  bool   `json:"twoFactorRequired,omitempty"`
+	EnrollmentRequired bool   `json:"enrollmentRequired,omitempty"`
+}
+
+// TwoFactorEnrollmentResponse carries the new secret both as text and as a
+// QR code data URI of OtpauthURI.
+type TwoFactorEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+	QRCode     string `json:"qrCode"`
+}
+
+// RecoveryCodesResponse carries tokens as well when two-factor authentication
+// was enabled to finish a login.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+	*AuthResponse
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+type TwoFactorRolesResponse struct {
+	Roles []string `json:"roles"`
+}
+
+type AuthorizationURLResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+}
+
+// APIKeyCreatedResponse is the only time the key itself is shown.
+type APIKeyCreatedResponse struct {
+	Key string `json:"key"`
+	repos.APIKey
+}
+
+type APIKeysResponse struct {
+	Keys  []repos.APIKey `json:"keys"`
+	Count int            `json:"count"`
+}
+
+// ImpersonationResponse carries an access token of the impersonated user that
+// can't be refreshed, ExpiresIn being its lifetime in seconds.
+type ImpersonationResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int64  `json:"expiresIn"`
+	UserID    int64  `json:"userId"`
+	Username  string `json:"username"`
+}
+
+type ImpersonationLogResponse struct {
+	Entries []repos.ImpersonationEntry `json:"entries"`
+	Count   int                        `json:"count"`
 }
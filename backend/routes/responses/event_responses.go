@@ -15,3 +15,46 @@ type EventDeletionResponse struct {
 	Id      int64  `json:"id"`
 	Message string `json:"message"`
 }
+
+type EventRevisionsResponse struct {
+	Revisions []repos.EventRevision `json:"revisions"`
+	Count     int                   `json:"count"`
+}
+
+type EventRevisionDiffResponse struct {
+	EventId int64               `json:"eventId"`
+	From    int64               `json:"from"`
+	To      int64               `json:"to"`
+	Changes []repos.FieldChange `json:"changes"`
+}
+
+type EventImageResponse struct {
+	EventId  int64        `json:"eventId"`
+	ImageURL string       `json:"imageUrl"`
+	Image    *repos.Image `json:"image"`
+}
+
+type EventMediaResponse struct {
+	EventId int64              `json:"eventId"`
+	Media   []repos.EventMedia `json:"media"`
+	Count   int                `json:"count"`
+}
+
+type TranslationReportResponse struct {
+	Languages []string                       `json:"languages"`
+	Events    []repos.EventTranslationReport `json:"events"`
+	Count     int                            `json:"count"`
+}
+
+type EventOrganizersResponse struct {
+	EventId    int64                  `json:"eventId"`
+	Organizers []repos.EventOrganizer `json:"organizers"`
+	Count      int                    `json:"count"`
+}
+
+type EventAttendeesResponse struct {
+	EventId   int64            `json:"eventId"`
+	Attendees []repos.Attendee `json:"attendees"`
+	Count     int              `json:"count"`
+	CheckedIn int              `json:"checkedIn"`
+}
@@ -0,0 +1,22 @@
+package responses
+
+import "immodi/submission-backend/repos"
+
+type OrganizationsResponse struct {
+	Organizations []repos.Organization `json:"organizations"`
+	Count         int                  `json:"count"`
+}
+
+// MembershipsResponse lists the organizations of the current user, Active
+// being the one the request acted in.
+type MembershipsResponse struct {
+	Active      int64              `json:"active"`
+	Memberships []repos.Membership `json:"memberships"`
+	Count       int                `json:"count"`
+}
+
+type MembersResponse struct {
+	OrganizationId int64          `json:"organizationId"`
+	Members        []repos.Member `json:"members"`
+	Count          int            `json:"count"`
+}
@@ -0,0 +1,13 @@
+package responses
+
+import "immodi/submission-backend/repos"
+
+type RolesResponse struct {
+	Roles []repos.Role `json:"roles"`
+	Count int          `json:"count"`
+}
+
+type PermissionsResponse struct {
+	Permissions []repos.Permission `json:"permissions"`
+	Count       int                `json:"count"`
+}
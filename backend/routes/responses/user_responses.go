@@ -1,11 +1,27 @@
 package responses
 
+import "immodi/submission-backend/repos"
+
 type UserResponse struct {
-	UserId    int64  `json:"userId"`
-	Role      string `json:"role"`
-	Username  string `json:"username"`
-	CreatedAt string `json:"createdAt"`
-	Tickets   int64  `json:"tickets"`
+	UserId            int64   `json:"userId"`
+	Role              string  `json:"role"`
+	Username          string  `json:"username"`
+	DisplayName       string  `json:"displayName"`
+	Email             *string `json:"email"`
+	EmailVerified     bool    `json:"emailVerified"`
+	Organization      string  `json:"organization"`
+	Bio               string  `json:"bio"`
+	PreferredLanguage string  `json:"preferredLanguage"`
+	Timezone          string  `json:"timezone"`
+	AvatarURL         string  `json:"avatarUrl,omitempty"`
+	CreatedAt         string  `json:"createdAt"`
+	Tickets           int64   `json:"tickets"`
+}
+
+// UsersResponse is one page of users, Count being how many match in total.
+type UsersResponse struct {
+	Users []repos.User `json:"users"`
+	Count int          `json:"count"`
 }
 
 type UserDeletionResponse struct {
@@ -0,0 +1,235 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"immodi/submission-backend/routes/responses"
+	helper_structs "immodi/submission-backend/structs"
+	"net/http"
+	"regexp"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RolesRouter(r chi.Router, db *sql.DB, api *helper_structs.API) {
+	manage := helpers.Require(helpers.PermRolesManage)
+
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, manage, GetRoles(api.RoleRepo))
+	})
+	r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, manage, CreateRole(api.RoleRepo))
+	})
+	r.Get("/permissions", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, manage, GetPermissions(api.RoleRepo))
+	})
+	r.Get("/{name}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, manage, GetRole(api.RoleRepo))
+	})
+	r.Put("/{name}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, manage, UpdateRole(api.RoleRepo))
+	})
+	r.Delete("/{name}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, manage, DeleteRole(api.RoleRepo))
+	})
+}
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// checkPermissions drops duplicates from permissions, failing on the first
+// one that doesn't exist.
+func checkPermissions(permissions []string) ([]string, error) {
+	checked := []string{}
+	for _, permission := range permissions {
+		if !helpers.IsPermission(permission) {
+			return nil, fmt.Errorf("unknown permission '%s'", permission)
+		}
+		if !slices.Contains(checked, permission) {
+			checked = append(checked, permission)
+		}
+	}
+	return checked, nil
+}
+
+func GetRoles(roleRepo repos.RoleInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles, err := roleRepo.GetRoles()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get roles")
+			return
+		}
+
+		res := &responses.RolesResponse{
+			Roles: roles,
+			Count: len(roles),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func GetPermissions(roleRepo repos.RoleInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		permissions, err := roleRepo.GetPermissions()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get permissions")
+			return
+		}
+
+		res := &responses.PermissionsResponse{
+			Permissions: permissions,
+			Count:       len(permissions),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func GetRole(roleRepo repos.RoleInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, err := roleRepo.GetRole(chi.URLParam(r, "name"))
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "role not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get role")
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusOK, role)
+	}
+}
+
+func CreateRole(roleRepo repos.RoleInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.RoleCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+
+		if !roleNamePattern.MatchString(req.Name) {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid role name, use up to 32 lowercase letters, digits, '-' and '_' starting with a letter")
+			return
+		}
+		permissions, err := checkPermissions(req.Permissions)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err = roleRepo.CreateRole(repos.Role{
+			Name:        req.Name,
+			Description: req.Description,
+			Permissions: permissions,
+		})
+		if errors.Is(err, repos.ErrAlreadyExists) {
+			helpers.HttpError(w, http.StatusConflict, fmt.Sprintf("role '%s' already exists", req.Name))
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to create role")
+			return
+		}
+
+		role, err := roleRepo.GetRole(req.Name)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "Role creation succeeded but fetch failed")
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusCreated, role)
+	}
+}
+
+// UpdateRole replaces the description and permissions of a role. Users with
+// the role get the new permissions on their next request.
+func UpdateRole(roleRepo repos.RoleInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+
+		var req requests.RoleUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+
+		if name == helpers.AdminRole {
+			helpers.HttpError(w, http.StatusConflict, "the admin role always has every permission")
+			return
+		}
+		permissions, err := checkPermissions(req.Permissions)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		err = roleRepo.UpdateRole(repos.Role{
+			Name:        name,
+			Description: req.Description,
+			Permissions: permissions,
+		})
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "role not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to update role")
+			return
+		}
+
+		role, err := roleRepo.GetRole(name)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "Role update succeeded but fetch failed")
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusOK, role)
+	}
+}
+
+// DeleteRole only deletes roles that no user has anymore.
+func DeleteRole(roleRepo repos.RoleInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		role, err := roleRepo.GetRole(chi.URLParam(r, "name"))
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "role not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get role")
+			return
+		}
+		if role.Builtin {
+			helpers.HttpError(w, http.StatusConflict, "built-in roles can't be deleted")
+			return
+		}
+
+		err = roleRepo.DeleteRole(role.Name)
+		if errors.Is(err, repos.ErrInUse) {
+			helpers.HttpError(w, http.StatusConflict, "role is still given to users, give them another role first")
+			return
+		}
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "role not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to delete role")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "role deleted",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
@@ -0,0 +1,152 @@
+package routes
+
+import (
+	"errors"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/responses"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// currentSessionUser loads the user the request was made by, for handlers
+// that need more than its principal carries.
+func currentSessionUser(w http.ResponseWriter, r *http.Request, authRepo repos.AuthInterface) (*repos.AuthUser, *helpers.Principal, bool) {
+	principal := helpers.GetPrincipal(r)
+	if principal == nil {
+		helpers.HttpError(w, http.StatusUnauthorized, "invalid token")
+		return nil, nil, false
+	}
+
+	user, err := authRepo.GetAuthUserById(principal.ID)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to get user")
+		return nil, nil, false
+	}
+	if user == nil {
+		helpers.HttpError(w, http.StatusNotFound, "User not found")
+		return nil, nil, false
+	}
+
+	return user, principal, true
+}
+
+func writeSessions(w http.ResponseWriter, authRepo repos.AuthInterface, userID int64, currentTokenID string) {
+	sessions, err := authRepo.GetUserSessions(userID, currentTokenID)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "couldn't get the sessions")
+		return
+	}
+
+	res := &responses.SessionsResponse{
+		Sessions: sessions,
+		Count:    len(sessions),
+	}
+
+	helpers.HttpJson(w, http.StatusOK, res)
+}
+
+func GetOwnSessions(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := helpers.GetPrincipal(r)
+		writeSessions(w, authRepo, principal.ID, principal.TokenID)
+	}
+}
+
+// RevokeOwnSession logs the user out of one of their sessions, which may be
+// the current one.
+func RevokeOwnSession(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionId, err := strconv.ParseInt(chi.URLParam(r, "sessionId"), 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid session id, pass a valid one")
+			return
+		}
+
+		err = authRepo.RevokeSession(helpers.GetPrincipal(r).ID, sessionId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not revoke the session")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "the session was revoked",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// RevokeOwnSessions logs the user out everywhere, including the current session.
+func RevokeOwnSessions(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := authRepo.RevokeUserSessions(helpers.GetPrincipal(r).ID); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not revoke the sessions")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "all sessions were revoked",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func GetUserSessions(userRepo repos.UserInterface, authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := parseSessionsUser(w, r, userRepo)
+		if !ok {
+			return
+		}
+
+		writeSessions(w, authRepo, userId, "")
+	}
+}
+
+// RevokeUserSessions lets admins force a user to log in again everywhere.
+func RevokeUserSessions(userRepo repos.UserInterface, authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, ok := parseSessionsUser(w, r, userRepo)
+		if !ok {
+			return
+		}
+
+		if err := authRepo.RevokeUserSessions(userId); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "could not revoke the sessions")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "the user was logged out of all sessions",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+func parseSessionsUser(w http.ResponseWriter, r *http.Request, userRepo repos.UserInterface) (int64, bool) {
+	userId, err := helpers.ParseUserIdFromRoute(r)
+	if err != nil {
+		helpers.HttpError(w, http.StatusBadRequest, "Invalid user ID, pass a valid one")
+		return 0, false
+	}
+
+	user, err := userRepo.GetUserById(userId)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "Could not retrieve user")
+		return 0, false
+	}
+	if user == nil {
+		helpers.HttpError(w, http.StatusNotFound, "User not found")
+		return 0, false
+	}
+
+	return userId, true
+}
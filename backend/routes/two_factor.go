@@ -0,0 +1,531 @@
+package routes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes/requests"
+	"immodi/submission-backend/routes/responses"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const recoveryCodeCount = 10
+
+// secondFactorChallenge is what a login whose first factor checked out gets
+// instead of tokens when the user has to pass or set up a second factor
+// first, nil when tokens can be issued right away.
+func secondFactorChallenge(authRepo repos.AuthInterface, user *repos.AuthUser) (*responses.TwoFactorChallengeResponse, error) {
+	tf, err := authRepo.GetTwoFactor(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	purpose := helpers.ChallengeTwoFactor
+	if !tf.Enabled {
+		required, err := authRepo.IsTwoFactorRequired(user.Role)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+		purpose = helpers.ChallengeEnrollment
+	}
+
+	challenge, err := helpers.CreateChallengeToken(user.ID, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.TwoFactorChallengeResponse{
+		ChallengeToken:     challenge.Token,
+		ExpiresIn:          int64(time.Until(challenge.ExpiresAt).Seconds()),
+		TwoFactorRequired:  purpose == helpers.ChallengeTwoFactor,
+		EnrollmentRequired: purpose == helpers.ChallengeEnrollment,
+	}, nil
+}
+
+// challengeOrProtected lets users who must enroll before they can log in
+// reach handler with their enrollment challenge token in X-Challenge-Token.
+func challengeOrProtected(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
+	if r.Header.Get("X-Challenge-Token") != "" {
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+		return
+	}
+	helpers.ProtectedHandler(w, r, helpers.SessionOnly, handler)
+}
+
+// twoFactorUser resolves the user from the enrollment challenge token when
+// there is one, the challenge being nil otherwise.
+func twoFactorUser(w http.ResponseWriter, r *http.Request, authRepo repos.AuthInterface) (*repos.AuthUser, *helpers.ChallengeToken, bool) {
+	tokenString := r.Header.Get("X-Challenge-Token")
+	if tokenString == "" {
+		user, _, ok := currentSessionUser(w, r, authRepo)
+		return user, nil, ok
+	}
+
+	challenge, err := helpers.VerifyChallengeToken(tokenString, helpers.ChallengeEnrollment)
+	if err != nil {
+		helpers.HttpError(w, http.StatusUnauthorized, "invalid or expired challenge token")
+		return nil, nil, false
+	}
+
+	user, err := authRepo.GetAuthUserById(challenge.UserID)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to get user")
+		return nil, nil, false
+	}
+	if user == nil {
+		helpers.HttpError(w, http.StatusUnauthorized, "invalid or expired challenge token")
+		return nil, nil, false
+	}
+
+	return user, challenge, true
+}
+
+// checkSecondFactor accepts either a TOTP code or one of the recovery codes,
+// spending whichever was used.
+func checkSecondFactor(authRepo repos.AuthInterface, userID int64, tf *repos.TwoFactor, code, recoveryCode string) (bool, error) {
+	if !tf.Enabled {
+		return false, nil
+	}
+
+	if recoveryCode != "" {
+		hash := helpers.HashToken(helpers.NormalizeRecoveryCode(recoveryCode))
+		return authRepo.UseRecoveryCode(userID, hash)
+	}
+
+	step, ok := helpers.ValidateTOTP(tf.Secret, code, time.Now(), tf.LastStep)
+	if !ok {
+		return false, nil
+	}
+	return authRepo.UseTOTPStep(userID, step)
+}
+
+// newRecoveryCodes returns fresh recovery codes along with the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := helpers.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = helpers.HashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// VerifyTwoFactor finishes a login started with a 2fa challenge token. Wrong
+// codes count as failed logins, so the lockout applies to guessing them too.
+func VerifyTwoFactor(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.TwoFactorVerifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.ChallengeToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+			helpers.HttpError(w, http.StatusBadRequest, "missing challenge token and code or recovery code")
+			return
+		}
+
+		challenge, err := helpers.VerifyChallengeToken(req.ChallengeToken, helpers.ChallengeTwoFactor)
+		if err != nil {
+			helpers.HttpError(w, http.StatusUnauthorized, "invalid or expired challenge token")
+			return
+		}
+
+		user, err := authRepo.GetAuthUserById(challenge.UserID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get user")
+			return
+		}
+		if user == nil {
+			helpers.HttpError(w, http.StatusUnauthorized, "invalid or expired challenge token")
+			return
+		}
+
+		ip := helpers.ClientIP(r)
+		if loginLockedOut(w, authRepo, user.Username, ip) {
+			return
+		}
+
+		tf, err := authRepo.GetTwoFactor(user.ID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get user")
+			return
+		}
+
+		ok, err := checkSecondFactor(authRepo, user.ID, tf, req.Code, req.RecoveryCode)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to verify code")
+			return
+		}
+		if !ok {
+			recordLoginFailure(authRepo, user.Username, ip)
+			helpers.HttpError(w, http.StatusUnauthorized, "invalid code")
+			return
+		}
+
+		// A challenge finishes exactly one login
+		if err := authRepo.RevokeToken(challenge.ID, challenge.ExpiresAt); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to verify code")
+			return
+		}
+		if _, err := authRepo.ClearLoginFailures(repos.LoginFailureUsername, user.Username); err != nil {
+			log.Printf("Failed to clear login failures of user %d: %v", user.ID, err)
+		}
+
+		issueTokens(w, r, authRepo, user)
+	}
+}
+
+func GetTwoFactorStatus(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _, ok := currentSessionUser(w, r, authRepo)
+		if !ok {
+			return
+		}
+
+		tf, err := authRepo.GetTwoFactor(user.ID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get two-factor authentication")
+			return
+		}
+		required, err := authRepo.IsTwoFactorRequired(user.Role)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get two-factor authentication")
+			return
+		}
+
+		res := &responses.TwoFactorStatusResponse{
+			Enabled:           tf.Enabled,
+			Required:          required,
+			RecoveryCodesLeft: tf.RecoveryCodesLeft,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// EnrollTwoFactor hands out a new secret, which only replaces the current one
+// once EnableTwoFactor got a code generated from it.
+func EnrollTwoFactor(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _, ok := twoFactorUser(w, r, authRepo)
+		if !ok {
+			return
+		}
+
+		// Swapping the secret needs it disabled first, which takes a code
+		tf, err := authRepo.GetTwoFactor(user.ID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get two-factor authentication")
+			return
+		}
+		if tf.Enabled {
+			helpers.HttpError(w, http.StatusConflict, "two-factor authentication is already enabled")
+			return
+		}
+
+		secret, err := helpers.GenerateTOTPSecret()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to generate secret")
+			return
+		}
+
+		uri := helpers.TOTPURI(helpers.GetEnv("TOTP_ISSUER", "Technology Competition App"), user.Username, secret)
+		png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to generate QR code")
+			return
+		}
+
+		if err := authRepo.SetPendingTOTPSecret(user.ID, secret); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to store secret")
+			return
+		}
+
+		res := &responses.TwoFactorEnrollmentResponse{
+			Secret:     secret,
+			OtpauthURI: uri,
+			QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// EnableTwoFactor confirms an enrollment with a code from the new secret. When
+// reached with an enrollment challenge it also finishes that login.
+func EnableTwoFactor(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.TwoFactorCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+		if req.Code == "" {
+			helpers.HttpError(w, http.StatusBadRequest, "missing code")
+			return
+		}
+
+		user, challenge, ok := twoFactorUser(w, r, authRepo)
+		if !ok {
+			return
+		}
+
+		tf, err := authRepo.GetTwoFactor(user.ID)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get two-factor authentication")
+			return
+		}
+		if tf.PendingSecret == "" {
+			helpers.HttpError(w, http.StatusConflict, "no enrollment in progress, enroll first")
+			return
+		}
+
+		step, ok := helpers.ValidateTOTP(tf.PendingSecret, req.Code, time.Now(), 0)
+		if !ok {
+			helpers.HttpError(w, http.StatusUnauthorized, "invalid code")
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to generate recovery codes")
+			return
+		}
+
+		err = authRepo.EnableTwoFactor(user.ID, step, hashes)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusConflict, "no enrollment in progress, enroll first")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to enable two-factor authentication")
+			return
+		}
+
+		res := &responses.RecoveryCodesResponse{
+			RecoveryCodes: codes,
+		}
+
+		if challenge != nil {
+			if err := authRepo.RevokeToken(challenge.ID, challenge.ExpiresAt); err != nil {
+				log.Printf("Failed to revoke enrollment challenge of user %d: %v", user.ID, err)
+			}
+
+			res.AuthResponse, err = createSession(r, authRepo, user)
+			if err != nil {
+				helpers.HttpError(w, http.StatusInternalServerError, "failed to generate token")
+				return
+			}
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// DisableTwoFactor turns two-factor authentication off after checking a code,
+// unless the role of the user requires it.
+func DisableTwoFactor(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.TwoFactorVerifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+
+		user, _, ok := currentSessionUser(w, r, authRepo)
+		if !ok {
+			return
+		}
+
+		// Checked first so the code isn't spent on a refused request
+		required, err := authRepo.IsTwoFactorRequired(user.Role)
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+			return
+		}
+		if required {
+			helpers.HttpError(w, http.StatusForbidden, "two-factor authentication is required for your role")
+			return
+		}
+
+		if !confirmSecondFactor(w, r, authRepo, user, req.Code, req.RecoveryCode) {
+			return
+		}
+
+		if err := authRepo.DisableTwoFactor(user.ID); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "two-factor authentication disabled",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user, used or not.
+func RegenerateRecoveryCodes(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.TwoFactorVerifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+
+		user, _, ok := currentSessionUser(w, r, authRepo)
+		if !ok || !confirmSecondFactor(w, r, authRepo, user, req.Code, req.RecoveryCode) {
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to generate recovery codes")
+			return
+		}
+		if err := authRepo.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to store recovery codes")
+			return
+		}
+
+		res := &responses.RecoveryCodesResponse{
+			RecoveryCodes: codes,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// confirmSecondFactor makes a logged in user prove they still hold their
+// second factor before changing it, throttled like logins are.
+func confirmSecondFactor(w http.ResponseWriter, r *http.Request, authRepo repos.AuthInterface, user *repos.AuthUser, code, recoveryCode string) bool {
+	if code == "" && recoveryCode == "" {
+		helpers.HttpError(w, http.StatusBadRequest, "missing code or recovery code")
+		return false
+	}
+
+	tf, err := authRepo.GetTwoFactor(user.ID)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to get two-factor authentication")
+		return false
+	}
+	if !tf.Enabled {
+		helpers.HttpError(w, http.StatusConflict, "two-factor authentication is not enabled")
+		return false
+	}
+
+	ip := helpers.ClientIP(r)
+	if loginLockedOut(w, authRepo, user.Username, ip) {
+		return false
+	}
+
+	ok, err := checkSecondFactor(authRepo, user.ID, tf, code, recoveryCode)
+	if err != nil {
+		helpers.HttpError(w, http.StatusInternalServerError, "failed to verify code")
+		return false
+	}
+	if !ok {
+		recordLoginFailure(authRepo, user.Username, ip)
+		helpers.HttpError(w, http.StatusUnauthorized, "invalid code")
+		return false
+	}
+	return true
+}
+
+func GetTwoFactorRequiredRoles(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles, err := authRepo.GetTwoFactorRequiredRoles()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get required roles")
+			return
+		}
+
+		res := &responses.TwoFactorRolesResponse{
+			Roles: roles,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// SetTwoFactorRequiredRoles makes users of the given roles set up two-factor
+// authentication on their next login before they get any tokens.
+func SetTwoFactorRequiredRoles(authRepo repos.AuthInterface, roleRepo repos.RoleInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.TwoFactorRolesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "invalid request, likey an invalid schema")
+			return
+		}
+
+		roles := []string{}
+		for _, role := range req.Roles {
+			if _, err := roleRepo.GetRole(role); err != nil {
+				if errors.Is(err, repos.ErrNotFound) {
+					helpers.HttpError(w, http.StatusBadRequest, fmt.Sprintf("invalid user role, role '%s' doesn't exist", role))
+					return
+				}
+				helpers.HttpError(w, http.StatusInternalServerError, "failed to get role")
+				return
+			}
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+
+		if err := authRepo.SetTwoFactorRequiredRoles(roles); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to set required roles")
+			return
+		}
+
+		res := &responses.TwoFactorRolesResponse{
+			Roles: roles,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
+
+// ResetUserTwoFactor lets an admin turn off two-factor authentication for a
+// user who lost both their device and their recovery codes.
+func ResetUserTwoFactor(authRepo repos.AuthInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := helpers.ParseUserIdFromRoute(r)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid user ID, pass a valid one")
+			return
+		}
+
+		err = authRepo.DisableTwoFactor(userId)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to reset two-factor authentication")
+			return
+		}
+
+		res := &responses.MessageResponse{
+			Message: "two-factor authentication reset",
+		}
+
+		helpers.HttpJson(w, http.StatusOK, res)
+	}
+}
@@ -3,73 +3,199 @@ package routes
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"immodi/submission-backend/helpers"
 	"immodi/submission-backend/repos"
 	"immodi/submission-backend/routes/requests"
 	"immodi/submission-backend/routes/responses"
 	helper_structs "immodi/submission-backend/structs"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 func UsersRouter(r chi.Router, db *sql.DB, api *helper_structs.API) {
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, func(username string) bool {
-			return api.UserRepo.IsAdmin(username)
-		}, GetAllUsers(api.UserRepo))
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermUsersRead), GetAllUsers(orgUsers(api, r)))
 	})
 	r.Put("/", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, func(username string) bool {
-			return api.UserRepo.IsAdmin(username)
-		}, UpdateUserRole(api.UserRepo))
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermUsersRoles), UpdateUserRole(orgUsers(api, r), api.AuthRepo, api.RoleRepo))
 	})
 	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, func(username string) bool {
-			userId, err := helpers.ParseUserIdFromRoute(r)
-			if err != nil {
-				return false
-			}
-			return api.UserRepo.IsSameUser(username, userId) || api.UserRepo.IsAdmin(username)
-		}, GetUser(api.UserRepo))
+		helpers.ProtectedHandler(w, r, helpers.SelfOr(r, helpers.PermUsersRead), GetUser(orgUsers(api, r)))
 	})
 
 	r.Get("/data", func(w http.ResponseWriter, r *http.Request) {
 		helpers.ProtectedHandler(w, r, nil, GetUserDataFromToken(api.UserRepo))
 	})
+	r.Patch("/me", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.SessionOnly, UpdateOwnProfile(api.UserRepo, api.ImageRepo))
+	})
+	r.Get("/{id}/avatar", GetUserAvatar(api.UserRepo, api.ImageRepo))
+
+	r.Get("/trash", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermUsersRestore), GetDeletedUsers(orgUsers(api, r)))
+	})
+	r.Post("/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermUsersRestore), RestoreUser(orgUsers(api, r)))
+	})
 
 	r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, func(username string) bool {
-			userId, err := helpers.ParseUserIdFromRoute(r)
-			if err != nil {
-				return false
-			}
-			return api.UserRepo.IsSameUser(username, userId) || api.UserRepo.IsAdmin(username)
-		}, DeleteUser(api.UserRepo))
+		helpers.ProtectedHandler(w, r, helpers.SessionSelfOr(r, helpers.PermUsersDelete), DeleteUser(orgUsers(api, r), api.AuthRepo))
+	})
+
+	r.Delete("/{id}/2fa", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermUsersTwoFactor), ResetUserTwoFactor(api.AuthRepo))
+	})
+	r.Get("/{id}/sessions", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermUsersSessions), GetUserSessions(orgUsers(api, r), api.AuthRepo))
+	})
+	r.Delete("/{id}/sessions", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermUsersSessions), RevokeUserSessions(orgUsers(api, r), api.AuthRepo))
+	})
+	r.Get("/{id}/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermUsersSessions), GetUserAPIKeys(orgUsers(api, r), api.AuthRepo))
+	})
+	r.Delete("/{id}/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, helpers.Require(helpers.PermUsersSessions), RevokeUserAPIKeys(orgUsers(api, r), api.AuthRepo))
+	})
+
+	r.Post("/{id}/impersonate", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, canImpersonate, ImpersonateUser(orgUsers(api, r), api.AuthRepo))
 	})
 
 	r.Get("/events/{id}", func(w http.ResponseWriter, r *http.Request) {
-		helpers.ProtectedHandler(w, r, func(username string) bool {
-			userId, err := helpers.ParseUserIdFromRoute(r)
-			if err != nil {
-				return false
-			}
-			return api.UserRepo.IsSameUser(username, userId) || api.UserRepo.IsAdmin(username)
-		}, GetUserEvents(api.EventRepo))
+		helpers.ProtectedHandler(w, r, helpers.SelfOr(r, helpers.PermUsersRead), GetUserEvents(orgEvents(api, r)))
 	})
 }
 
+func userResponse(user *repos.User) *responses.UserResponse {
+	return &responses.UserResponse{
+		UserId:            user.ID,
+		Role:              user.Role,
+		Username:          user.Username,
+		DisplayName:       user.DisplayName,
+		Email:             user.Email,
+		EmailVerified:     user.EmailVerified,
+		Organization:      user.Organization,
+		Bio:               user.Bio,
+		PreferredLanguage: user.PreferredLanguage,
+		Timezone:          user.Timezone,
+		AvatarURL:         user.AvatarURL,
+		CreatedAt:         user.CreatedAt,
+		Tickets:           user.Tickets,
+	}
+}
+
+// GetAllUsers lists a page of users like GetAllEvents does, ?page= and ?limit=
+// picking the page. See parseUserQuery for the filters and the sort order.
 func GetAllUsers(userRepo repos.UserInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		users, err := userRepo.GetAllUsers()
+		query, page, err := parseUserQuery(r)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		users, count, err := userRepo.ListUsers(query)
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "Failed to get all users")
 			return
 		}
 
-		helpers.HttpJson(w, http.StatusOK, users)
+		if count < query.Limit*(page-1) {
+			helpers.HttpError(w, http.StatusBadRequest, "requested page does not exist")
+			return
+		}
+
+		resp := &responses.UsersResponse{
+			Users: users,
+			Count: count,
+		}
+
+		helpers.HttpJson(w, http.StatusOK, resp)
+	}
+}
+
+// parseUserQuery reads the user listing parameters: ?q= for a username or email
+// prefix, ?role=, ?createdAfter= and ?createdBefore= as RFC3339 or plain dates,
+// ?minTickets= and ?maxTickets=, and ?sort= with ?order=asc or desc.
+func parseUserQuery(r *http.Request) (repos.UserQuery, int, error) {
+	params := r.URL.Query()
+	query := repos.UserQuery{
+		Search: strings.TrimSpace(params.Get("q")),
+		Role:   params.Get("role"),
+		Sort:   params.Get("sort"),
+		Limit:  10,
+	}
+
+	page := 1
+	if p, err := strconv.Atoi(params.Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(params.Get("limit")); err == nil && l > 0 {
+		query.Limit = min(l, 100)
+	}
+	query.Offset = (page - 1) * query.Limit
+
+	var err error
+	if query.CreatedAfter, err = parseDateParam(params, "createdAfter"); err != nil {
+		return query, 0, err
+	}
+	if query.CreatedBefore, err = parseDateParam(params, "createdBefore"); err != nil {
+		return query, 0, err
+	}
+	if query.MinTickets, err = parseIntParam(params, "minTickets"); err != nil {
+		return query, 0, err
+	}
+	if query.MaxTickets, err = parseIntParam(params, "maxTickets"); err != nil {
+		return query, 0, err
+	}
+
+	if _, ok := repos.UserSortColumns[query.Sort]; query.Sort != "" && !ok {
+		return query, 0, fmt.Errorf("can't sort users by '%s'", query.Sort)
+	}
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, 0, fmt.Errorf("order must be asc or desc")
 	}
+
+	return query, page, nil
+}
+
+func parseDateParam(params url.Values, name string) (*time.Time, error) {
+	value := params.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return &t, nil
+	}
+	return nil, fmt.Errorf("invalid %s, pass an RFC3339 timestamp or a date like 2006-01-02", name)
+}
+
+func parseIntParam(params url.Values, name string) (*int64, error) {
+	value := params.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, pass a whole number", name)
+	}
+	return &n, nil
 }
 
 func GetUser(userRepo repos.UserInterface) http.HandlerFunc {
@@ -91,25 +217,14 @@ func GetUser(userRepo repos.UserInterface) http.HandlerFunc {
 			return
 		}
 
-		response := &responses.UserResponse{
-			UserId:    user.ID,
-			Role:      user.Role,
-			Username:  user.Username,
-			CreatedAt: user.CreatedAt,
-			Tickets:   user.Tickets,
-		}
+		response := userResponse(user)
 		helpers.HttpJson(w, http.StatusOK, response)
 	}
 }
 
 func GetUserDataFromToken(userRepo repos.UserInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		username, err := helpers.GetUserNameFromToken(r)
-		if err != nil {
-			helpers.HttpError(w, http.StatusInternalServerError, "couldn't retrieve user")
-		}
-
-		user, err := userRepo.GetUserByUsername(username)
+		user, err := userRepo.GetUserById(helpers.GetPrincipal(r).ID)
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "could not retrieve user")
 			return
@@ -119,18 +234,12 @@ func GetUserDataFromToken(userRepo repos.UserInterface) http.HandlerFunc {
 			return
 		}
 
-		response := &responses.UserResponse{
-			UserId:    user.ID,
-			Role:      user.Role,
-			Username:  user.Username,
-			CreatedAt: user.CreatedAt,
-			Tickets:   user.Tickets,
-		}
+		response := userResponse(user)
 		helpers.HttpJson(w, http.StatusOK, response)
 	}
 }
 
-func DeleteUser(userRepo repos.UserInterface) http.HandlerFunc {
+func DeleteUser(userRepo repos.UserInterface, authRepo repos.AuthInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
@@ -140,11 +249,23 @@ func DeleteUser(userRepo repos.UserInterface) http.HandlerFunc {
 		}
 
 		err = userRepo.DeleteUser(id)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "User not found")
+			return
+		}
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "Could not delete the user")
 			return
 		}
 
+		// Access tokens of the user would stay valid until they expire otherwise
+		if err := authRepo.RevokeUserSessions(id); err != nil {
+			log.Printf("Failed to revoke sessions of deleted user %d: %v", id, err)
+		}
+		if err := authRepo.RevokeUserAPIKeys(id); err != nil {
+			log.Printf("Failed to revoke API keys of deleted user %d: %v", id, err)
+		}
+
 		res := &responses.UserDeletionResponse{
 			Message: "User deleted successfully",
 		}
@@ -153,7 +274,49 @@ func DeleteUser(userRepo repos.UserInterface) http.HandlerFunc {
 	}
 }
 
-func UpdateUserRole(userRepo repos.UserInterface) http.HandlerFunc {
+func GetDeletedUsers(userRepo repos.UserInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users, err := userRepo.GetDeletedUsers()
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "Failed to get deleted users")
+			return
+		}
+
+		helpers.HttpJson(w, http.StatusOK, users)
+	}
+}
+
+func RestoreUser(userRepo repos.UserInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			helpers.HttpError(w, http.StatusBadRequest, "Invalid user ID, pass a valid one")
+			return
+		}
+
+		err = userRepo.RestoreUser(id)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "User not found in the trash")
+			return
+		}
+		if err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "Could not restore the user")
+			return
+		}
+
+		user, err := userRepo.GetUserById(id)
+		if err != nil || user == nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "User restore succeeded but fetch failed")
+			return
+		}
+
+		response := userResponse(user)
+		helpers.HttpJson(w, http.StatusOK, response)
+	}
+}
+
+func UpdateUserRole(userRepo repos.UserInterface, authRepo repos.AuthInterface, roleRepo repos.RoleInterface) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req requests.UserRoleUpdateRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -165,29 +328,37 @@ func UpdateUserRole(userRepo repos.UserInterface) http.HandlerFunc {
 			return
 		}
 
-		if req.Role != "admin" && req.Role != "user" {
-			helpers.HttpError(w, http.StatusBadRequest, "invalid user role, role must be 'admin' or 'user'")
+		if _, err := roleRepo.GetRole(req.Role); err != nil {
+			if errors.Is(err, repos.ErrNotFound) {
+				helpers.HttpError(w, http.StatusBadRequest, fmt.Sprintf("invalid user role, role '%s' doesn't exist", req.Role))
+				return
+			}
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get role")
 			return
 		}
 
 		err := userRepo.UpdateUserRole(req.UserId, req.Role)
+		if errors.Is(err, repos.ErrNotFound) {
+			helpers.HttpError(w, http.StatusNotFound, "User not found")
+			return
+		}
 		if err != nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "failed to update user role")
 			return
 		}
 
+		// The role is part of the access tokens, so the user has to log in again
+		if err := authRepo.RevokeUserSessions(req.UserId); err != nil {
+			log.Printf("Failed to revoke sessions of user %d: %v", req.UserId, err)
+		}
+
 		user, err := userRepo.GetUserById(req.UserId)
-		if err != nil {
+		if err != nil || user == nil {
 			helpers.HttpError(w, http.StatusInternalServerError, "User update succeeded but fetch failed")
 			return
 		}
 
-		res := &responses.UserResponse{
-			UserId:    user.ID,
-			Role:      user.Role,
-			Username:  user.Username,
-			CreatedAt: user.CreatedAt,
-		}
+		res := userResponse(user)
 
 		helpers.HttpJson(w, http.StatusOK, res)
 	}
@@ -207,6 +378,10 @@ func GetUserEvents(eventRepository *repos.EventRepository) http.HandlerFunc {
 			helpers.HttpError(w, http.StatusInternalServerError, "failed to get user events")
 			return
 		}
+		if err := localizeEvents(w, r, eventRepository, events); err != nil {
+			helpers.HttpError(w, http.StatusInternalServerError, "failed to get user events")
+			return
+		}
 
 		helpers.HttpJson(w, http.StatusOK, events)
 	}
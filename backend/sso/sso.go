@@ -0,0 +1,182 @@
+// Package sso signs users in with an external OpenID Connect provider using
+// the authorization code flow with PKCE.
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"immodi/submission-backend/helpers"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback of this server registered with the provider
+	RedirectURL string
+	Scopes      []string
+	// UsernameClaim names new users, GroupsClaim lists the groups of the user
+	UsernameClaim string
+	GroupsClaim   string
+	// AdminGroup members get the admin role, everybody else the user role.
+	// Roles are left alone when it's empty.
+	AdminGroup string
+}
+
+// ConfigFromEnv reads the OIDC_* variables, reporting false when single
+// sign-on isn't configured.
+func ConfigFromEnv() (Config, bool) {
+	config := Config{
+		Issuer:        helpers.GetEnv("OIDC_ISSUER", ""),
+		ClientID:      helpers.GetEnv("OIDC_CLIENT_ID", ""),
+		ClientSecret:  helpers.GetEnv("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:   helpers.GetEnv("OIDC_REDIRECT_URL", "http://localhost:8020/auth/oidc/callback"),
+		Scopes:        strings.Fields(helpers.GetEnv("OIDC_SCOPES", "openid profile email groups")),
+		UsernameClaim: helpers.GetEnv("OIDC_USERNAME_CLAIM", "preferred_username"),
+		GroupsClaim:   helpers.GetEnv("OIDC_GROUPS_CLAIM", "groups"),
+		AdminGroup:    helpers.GetEnv("OIDC_ADMIN_GROUP", ""),
+	}
+	return config, config.Issuer != "" && config.ClientID != ""
+}
+
+// Identity is what the provider vouched for about the user.
+type Identity struct {
+	Issuer   string
+	Subject  string
+	Username string
+	Email    string
+	Groups   []string
+}
+
+// Role is the role a user with current as role should have given the groups
+// of the identity. Only the admin role follows the admin group, other roles
+// are handed out in the application and left alone.
+func (i *Identity) Role(adminGroup, current string) string {
+	if adminGroup == "" {
+		return current
+	}
+	if slices.Contains(i.Groups, adminGroup) {
+		return "admin"
+	}
+	if current == "admin" {
+		return helpers.DefaultRole
+	}
+	return current
+}
+
+// Provider talks to the identity provider. Its discovery document is fetched
+// on first use, so the server starts even while the provider is unreachable.
+type Provider struct {
+	Config Config
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewProvider(config Config) *Provider {
+	return &Provider{Config: config}
+}
+
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth == nil {
+		provider, err := oidc.NewProvider(ctx, p.Config.Issuer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to discover identity provider: %w", err)
+		}
+
+		p.oauth = &oauth2.Config{
+			ClientID:     p.Config.ClientID,
+			ClientSecret: p.Config.ClientSecret,
+			RedirectURL:  p.Config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       p.Config.Scopes,
+		}
+		p.verifier = provider.Verifier(&oidc.Config{ClientID: p.Config.ClientID})
+	}
+	return p.oauth, p.verifier, nil
+}
+
+// AuthCodeURL is where to send the user to sign in. The code verifier and the
+// nonce have to be kept until the provider redirects back with state.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+// Exchange trades the code the provider redirected back with for the
+// identity of the user, checking the ID token was issued for this login.
+func (p *Provider) Exchange(ctx context.Context, code, nonce, codeVerifier string) (*Identity, error) {
+	config, verifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce doesn't match the login")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to read id_token claims: %w", err)
+	}
+
+	identity := &Identity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Groups:  stringsClaim(claims[p.Config.GroupsClaim]),
+	}
+	identity.Username, _ = claims[p.Config.UsernameClaim].(string)
+	identity.Email, _ = claims["email"].(string)
+	if identity.Username == "" {
+		identity.Username = identity.Email
+	}
+
+	return identity, nil
+}
+
+// stringsClaim reads a claim that providers send either as a list or as a
+// single string.
+func stringsClaim(claim any) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := []string{}
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return []string{}
+}
@@ -0,0 +1,13 @@
+package storage
+
+import "errors"
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps binary content such as images outside the database, addressed
+// by slash separated keys like "images/12/thumb.jpg".
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
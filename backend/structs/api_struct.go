@@ -1,9 +1,19 @@
 package helper_structs
 
-import "immodi/submission-backend/repos"
+import (
+	"immodi/submission-backend/mail"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/sso"
+)
 
 type API struct {
 	EventRepo *repos.EventRepository
 	UserRepo  *repos.UserRepository
 	AuthRepo  *repos.AuthRepository
+	RoleRepo  *repos.RoleRepository
+	OrgRepo   *repos.OrganizationRepository
+	ImageRepo *repos.ImageRepository
+	Mailer    mail.Sender
+	// SSO is nil unless single sign-on is configured
+	SSO *sso.Provider
 }
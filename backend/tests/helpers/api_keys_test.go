@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"immodi/submission-backend/helpers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withAPIKey makes key a live API key of user 7, limited to the events:create
+// scope and remembering the addresses it was used from.
+func withAPIKey(t *testing.T, key string, usedFrom *[]string) {
+	helpers.FindAPIKey = func(keyHash, ip string) (*helpers.APIKeyOwner, error) {
+		if keyHash != helpers.HashToken(key) {
+			return nil, nil
+		}
+		*usedFrom = append(*usedFrom, ip)
+		return &helpers.APIKeyOwner{KeyID: 3, UserID: 7, Username: "jane", Role: "organizer", Scopes: []string{helpers.PermEventsCreate}}, nil
+	}
+	t.Cleanup(func() {
+		helpers.FindAPIKey = func(string, string) (*helpers.APIKeyOwner, error) { return nil, nil }
+	})
+}
+
+func TestAuthenticate_APIKey(t *testing.T) {
+	key, err := helpers.NewAPIKey()
+	assert.NoError(t, err)
+	var usedFrom []string
+	withAPIKey(t, key, &usedFrom)
+
+	var seen *helpers.Principal
+	router := newRouter(func(*http.Request) func(*helpers.Principal) bool { return nil }, &seen)
+
+	assert.Equal(t, http.StatusNoContent, request(t, router, "/users/7", key))
+	assert.Equal(t, int64(7), seen.ID)
+	assert.Equal(t, "organizer", seen.Role)
+	assert.Equal(t, int64(3), seen.APIKeyID)
+	assert.Empty(t, seen.TokenID)
+	assert.Len(t, usedFrom, 1)
+
+	// Unknown keys aren't mistaken for access tokens either
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/7", helpers.APIKeyPrefix+"unknown"))
+}
+
+func TestCan_APIKeyScopes(t *testing.T) {
+	helpers.HasPermission = func(role, permission string) bool { return role == "organizer" }
+	t.Cleanup(func() { helpers.HasPermission = func(string, string) bool { return false } })
+
+	key := &helpers.Principal{ID: 7, Role: "organizer", APIKeyID: 3, Scopes: []string{helpers.PermEventsCreate}}
+	assert.True(t, key.Can(helpers.PermEventsCreate))
+	assert.False(t, key.Can(helpers.PermEventsDelete))
+
+	// The scopes never grant more than the role of the user
+	assert.False(t, (&helpers.Principal{ID: 8, Role: "user", APIKeyID: 4, Scopes: []string{helpers.PermEventsCreate}}).Can(helpers.PermEventsCreate))
+}
+
+func TestSessionOnly(t *testing.T) {
+	key, err := helpers.NewAPIKey()
+	assert.NoError(t, err)
+	var usedFrom []string
+	withAPIKey(t, key, &usedFrom)
+	token, err := helpers.NewAccessToken(7, "jane", "organizer", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := newRouter(func(*http.Request) func(*helpers.Principal) bool { return helpers.SessionOnly }, &seen)
+
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/7", key))
+	assert.Equal(t, http.StatusNoContent, request(t, router, "/users/7", token.Token))
+}
+
+func TestSessionSelfOr_APIKeyWithoutScopes(t *testing.T) {
+	key, err := helpers.NewAPIKey()
+	assert.NoError(t, err)
+	helpers.FindAPIKey = func(keyHash, ip string) (*helpers.APIKeyOwner, error) {
+		if keyHash != helpers.HashToken(key) {
+			return nil, nil
+		}
+		return &helpers.APIKeyOwner{KeyID: 3, UserID: 7, Username: "jane", Role: "user"}, nil
+	}
+	t.Cleanup(func() {
+		helpers.FindAPIKey = func(string, string) (*helpers.APIKeyOwner, error) { return nil, nil }
+	})
+	token, err := helpers.NewAccessToken(7, "jane", "user", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := newRouter(func(r *http.Request) func(*helpers.Principal) bool {
+		return helpers.SessionSelfOr(r, helpers.PermUsersDelete)
+	}, &seen)
+
+	// The key can't delete the account of its owner, the owner can
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/7", key))
+	assert.Equal(t, http.StatusNoContent, request(t, router, "/users/7", token.Token))
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/8", token.Token))
+}
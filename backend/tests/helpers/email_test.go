@@ -0,0 +1,32 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"immodi/submission-backend/helpers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	email, err := helpers.NormalizeEmail("  Jane.Doe@Example.COM ")
+	assert.NoError(t, err)
+	assert.Equal(t, "jane.doe@example.com", email)
+
+	for _, invalid := range []string{"", "jane", "jane@", "jane@localhost", "Jane <jane@example.com>", "a b@example.com", strings.Repeat("a", 250) + "@example.com"} {
+		_, err := helpers.NormalizeEmail(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestNormalizeDisplayName(t *testing.T) {
+	name, err := helpers.NormalizeDisplayName("  Jane Doe ")
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane Doe", name)
+
+	_, err = helpers.NormalizeDisplayName("Jane\nDoe")
+	assert.Error(t, err)
+	_, err = helpers.NormalizeDisplayName(strings.Repeat("é", 65))
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"immodi/submission-backend/helpers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireIfMatchVersion(t *testing.T) {
+	cases := []struct {
+		ifMatch string
+		version int64
+		status  int
+	}{
+		{`"3"`, 3, 0},
+		{"*", helpers.AnyVersion, 0},
+		{"", 0, http.StatusPreconditionRequired},
+		{`W/"3"`, 0, http.StatusPreconditionFailed},
+		{`"abc"`, 0, http.StatusPreconditionFailed},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodDelete, "/events/1", nil)
+		if c.ifMatch != "" {
+			req.Header.Set("If-Match", c.ifMatch)
+		}
+		res := httptest.NewRecorder()
+
+		version, ok := helpers.RequireIfMatchVersion(res, req)
+		assert.Equal(t, c.status == 0, ok, c.ifMatch)
+		if ok {
+			assert.Equal(t, c.version, version, c.ifMatch)
+		} else {
+			assert.Equal(t, c.status, res.Code, c.ifMatch)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"immodi/submission-backend/helpers"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordImpersonations collects what LogImpersonation is handed.
+func recordImpersonations(t *testing.T) *[]helpers.ImpersonationEntry {
+	entries := &[]helpers.ImpersonationEntry{}
+	helpers.LogImpersonation = func(entry helpers.ImpersonationEntry) {
+		*entries = append(*entries, entry)
+	}
+	t.Cleanup(func() { helpers.LogImpersonation = func(helpers.ImpersonationEntry) {} })
+	return entries
+}
+
+func impersonationRouter(seen **helpers.Principal) http.Handler {
+	r := chi.NewRouter()
+	r.Use(helpers.Authenticate)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, nil, func(w http.ResponseWriter, r *http.Request) {
+			*seen = helpers.GetPrincipal(r)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+	r.Get("/users/data", handler)
+	r.Delete("/users/{id}", handler)
+	r.Post("/events", handler)
+	r.Post("/events/{id}/restore", handler)
+	r.Patch("/users/me", handler)
+	r.Route("/auth", func(r chi.Router) {
+		r.Put("/password", func(w http.ResponseWriter, r *http.Request) {
+			helpers.ProtectedHandler(w, r, helpers.SessionOnly, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+		})
+		r.Post("/logout", handler)
+	})
+	return r
+}
+
+func impersonatedRequest(handler http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res
+}
+
+func TestImpersonation_CarriesActorAndIsLogged(t *testing.T) {
+	entries := recordImpersonations(t)
+	token, err := helpers.NewImpersonationToken(helpers.Actor{ID: 1, Username: "admin"}, 7, "jane", "user", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	res := impersonatedRequest(impersonationRouter(&seen), http.MethodGet, "/users/data", token.Token)
+
+	assert.Equal(t, http.StatusNoContent, res.Code)
+	assert.Equal(t, "admin", res.Header().Get(helpers.ImpersonatedByHeader))
+	assert.Equal(t, int64(7), seen.ID)
+	assert.Equal(t, int64(1), seen.Actor.ID)
+	assert.Equal(t, []helpers.ImpersonationEntry{{
+		ActorID: 1, UserID: 7, TokenID: token.ID, Method: http.MethodGet, Path: "/users/data", Status: http.StatusNoContent, IP: "192.0.2.1",
+	}}, *entries)
+}
+
+func TestImpersonation_CantDeleteOrManageLogins(t *testing.T) {
+	entries := recordImpersonations(t)
+	token, err := helpers.NewImpersonationToken(helpers.Actor{ID: 1, Username: "admin"}, 7, "jane", "user", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := impersonationRouter(&seen)
+
+	assert.Equal(t, http.StatusForbidden, impersonatedRequest(router, http.MethodDelete, "/users/7", token.Token).Code)
+	assert.Equal(t, http.StatusForbidden, impersonatedRequest(router, http.MethodPut, "/auth/password", token.Token).Code)
+	assert.Nil(t, seen)
+
+	// Refused requests are logged all the same
+	assert.Len(t, *entries, 2)
+	assert.Equal(t, http.StatusForbidden, (*entries)[0].Status)
+}
+
+func TestImpersonation_CantChangeAnything(t *testing.T) {
+	recordImpersonations(t)
+	token, err := helpers.NewImpersonationToken(helpers.Actor{ID: 1, Username: "admin"}, 7, "jane", "user", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := impersonationRouter(&seen)
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodPost, "/events"},
+		{http.MethodPost, "/events/3/restore"},
+		{http.MethodPatch, "/users/me"},
+	} {
+		res := impersonatedRequest(router, req.method, req.path, token.Token)
+		assert.Equal(t, http.StatusForbidden, res.Code, req.method+" "+req.path)
+	}
+	assert.Nil(t, seen)
+
+	// Reading and ending the impersonation still work
+	assert.Equal(t, http.StatusNoContent, impersonatedRequest(router, http.MethodGet, "/users/data", token.Token).Code)
+	assert.Equal(t, http.StatusNoContent, impersonatedRequest(router, http.MethodPost, "/auth/logout", token.Token).Code)
+	assert.Equal(t, int64(1), seen.Actor.ID)
+}
+
+func TestImpersonation_OwnTokensAreNotMarked(t *testing.T) {
+	entries := recordImpersonations(t)
+	token, err := helpers.NewAccessToken(7, "jane", "user", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	res := impersonatedRequest(impersonationRouter(&seen), http.MethodDelete, "/users/7", token.Token)
+
+	assert.Equal(t, http.StatusNoContent, res.Code)
+	assert.Empty(t, res.Header().Get(helpers.ImpersonatedByHeader))
+	assert.Nil(t, seen.Actor)
+	assert.Empty(t, *entries)
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"immodi/submission-backend/helpers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memberOfAcme makes user 7 an organizer of organization 2, "acme", which
+// user 8 isn't a member of. Organization 3 doesn't exist.
+func memberOfAcme(t *testing.T) {
+	helpers.FindMembership = func(userID int64, org string) (*helpers.Membership, error) {
+		if org != "2" && org != "acme" {
+			return nil, nil
+		}
+		if userID == 7 {
+			return &helpers.Membership{OrganizationID: 2, Role: "organizer"}, nil
+		}
+		return &helpers.Membership{OrganizationID: 2}, nil
+	}
+	t.Cleanup(func() {
+		helpers.FindMembership = func(int64, string) (*helpers.Membership, error) { return nil, nil }
+	})
+}
+
+func requestInOrganization(handler http.Handler, token, org string) int {
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if org != "" {
+		req.Header.Set(helpers.OrganizationHeader, org)
+	}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res.Code
+}
+
+func TestAuthenticate_OrganizationFromToken(t *testing.T) {
+	memberOfAcme(t)
+	token, err := helpers.NewAccessToken(7, "jane", "user", 2)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := newRouter(func(*http.Request) func(*helpers.Principal) bool { return nil }, &seen)
+
+	assert.Equal(t, http.StatusNoContent, requestInOrganization(router, token.Token, ""))
+	assert.Equal(t, int64(2), seen.OrgID)
+	assert.Equal(t, "organizer", seen.OrgRole)
+}
+
+func TestAuthenticate_OrganizationHeader(t *testing.T) {
+	memberOfAcme(t)
+	jane, err := helpers.NewAccessToken(7, "jane", "user", 0)
+	assert.NoError(t, err)
+	joe, err := helpers.NewAccessToken(8, "joe", "user", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := newRouter(func(*http.Request) func(*helpers.Principal) bool { return nil }, &seen)
+
+	assert.Equal(t, http.StatusNoContent, requestInOrganization(router, jane.Token, "acme"))
+	assert.Equal(t, int64(2), seen.OrgID)
+
+	seen = nil
+	assert.Equal(t, http.StatusForbidden, requestInOrganization(router, joe.Token, "acme"))
+	assert.Equal(t, http.StatusNotFound, requestInOrganization(router, jane.Token, "3"))
+	assert.Nil(t, seen)
+}
+
+func TestAuthenticate_LeftOrganizationFromToken(t *testing.T) {
+	memberOfAcme(t)
+	token, err := helpers.NewAccessToken(8, "joe", "user", 2)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := newRouter(func(*http.Request) func(*helpers.Principal) bool { return nil }, &seen)
+
+	assert.Equal(t, http.StatusNoContent, requestInOrganization(router, token.Token, ""))
+	assert.Equal(t, int64(0), seen.OrgID)
+}
+
+func TestCan_OrganizationRole(t *testing.T) {
+	helpers.HasPermission = func(role, permission string) bool { return role == "organizer" }
+	t.Cleanup(func() { helpers.HasPermission = func(string, string) bool { return false } })
+
+	p := &helpers.Principal{ID: 7, Role: "user", OrgID: 2, OrgRole: "organizer"}
+	assert.True(t, p.Can(helpers.PermEventsCreate))
+	assert.True(t, p.Can(helpers.PermMembersManage))
+	// Only their own role grants permissions beyond the organization
+	assert.False(t, p.Can(helpers.PermUsersDelete))
+	assert.False(t, p.Can(helpers.PermOrgsManage))
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"immodi/submission-backend/helpers"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// newRouter mounts a protected route the way the routers do, behind the
+// Authenticate middleware, recording the principal the handler saw.
+func newRouter(check func(r *http.Request) func(p *helpers.Principal) bool, seen **helpers.Principal) http.Handler {
+	r := chi.NewRouter()
+	r.Use(helpers.Authenticate)
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		helpers.ProtectedHandler(w, r, check(r), func(w http.ResponseWriter, r *http.Request) {
+			*seen = helpers.GetPrincipal(r)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+	return r
+}
+
+func request(t *testing.T, handler http.Handler, path, token string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res.Code
+}
+
+func TestAuthenticate_LoadsPrincipal(t *testing.T) {
+	token, err := helpers.NewAccessToken(7, "jane", "user", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := newRouter(func(*http.Request) func(*helpers.Principal) bool { return nil }, &seen)
+
+	assert.Equal(t, http.StatusNoContent, request(t, router, "/users/7", token.Token))
+	assert.Equal(t, int64(7), seen.ID)
+	assert.Equal(t, "jane", seen.Username)
+	assert.Equal(t, "user", seen.Role)
+	assert.Equal(t, token.ID, seen.TokenID)
+	assert.WithinDuration(t, token.ExpiresAt, seen.ExpiresAt, time.Second)
+}
+
+func TestProtectedHandler_RejectsMissingAndInvalidTokens(t *testing.T) {
+	var seen *helpers.Principal
+	router := newRouter(func(*http.Request) func(*helpers.Principal) bool { return nil }, &seen)
+
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/7", ""))
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/7", "not-a-token"))
+
+	// Challenge tokens are signed with the same key but aren't access tokens
+	challenge, err := helpers.CreateChallengeToken(7, helpers.ChallengeTwoFactor)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/7", challenge.Token))
+	assert.Nil(t, seen)
+}
+
+func TestProtectedHandler_RevokedToken(t *testing.T) {
+	token, err := helpers.NewAccessToken(7, "jane", "user", 0)
+	assert.NoError(t, err)
+
+	helpers.IsTokenRevoked = func(tokenID string) bool { return tokenID == token.ID }
+	defer func() { helpers.IsTokenRevoked = func(string) bool { return false } }()
+
+	var seen *helpers.Principal
+	router := newRouter(func(*http.Request) func(*helpers.Principal) bool { return nil }, &seen)
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/7", token.Token))
+}
+
+// grantAdmin lets the admin role, and only it, use every permission.
+func grantAdmin(t *testing.T) {
+	helpers.HasPermission = func(role, permission string) bool { return role == helpers.AdminRole }
+	t.Cleanup(func() { helpers.HasPermission = func(string, string) bool { return false } })
+}
+
+func TestRequire(t *testing.T) {
+	grantAdmin(t)
+	user, err := helpers.NewAccessToken(7, "jane", "user", 0)
+	assert.NoError(t, err)
+	admin, err := helpers.NewAccessToken(1, "admin", "admin", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := newRouter(func(*http.Request) func(*helpers.Principal) bool {
+		return helpers.Require(helpers.PermUsersRead)
+	}, &seen)
+
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/7", user.Token))
+	assert.Equal(t, http.StatusNoContent, request(t, router, "/users/7", admin.Token))
+}
+
+func TestRequire_ChecksTheGrantedPermission(t *testing.T) {
+	helpers.HasPermission = func(role, permission string) bool {
+		return role == "organizer" && permission == helpers.PermEventsCreate
+	}
+	t.Cleanup(func() { helpers.HasPermission = func(string, string) bool { return false } })
+
+	organizer := &helpers.Principal{ID: 3, Role: "organizer"}
+	assert.True(t, helpers.Require(helpers.PermEventsCreate)(organizer))
+	assert.False(t, helpers.Require(helpers.PermEventsDelete)(organizer))
+	assert.False(t, helpers.Require(helpers.PermEventsCreate)(&helpers.Principal{ID: 4, Role: "user"}))
+}
+
+func TestSelfOr(t *testing.T) {
+	grantAdmin(t)
+	user, err := helpers.NewAccessToken(7, "jane", "user", 0)
+	assert.NoError(t, err)
+	admin, err := helpers.NewAccessToken(1, "admin", "admin", 0)
+	assert.NoError(t, err)
+
+	var seen *helpers.Principal
+	router := newRouter(func(r *http.Request) func(*helpers.Principal) bool {
+		return helpers.SelfOr(r, helpers.PermUsersRead)
+	}, &seen)
+
+	assert.Equal(t, http.StatusNoContent, request(t, router, "/users/7", user.Token))
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/8", user.Token))
+	assert.Equal(t, http.StatusNoContent, request(t, router, "/users/8", admin.Token))
+	assert.Equal(t, http.StatusUnauthorized, request(t, router, "/users/abc", user.Token))
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"immodi/submission-backend/helpers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeProfileText(t *testing.T) {
+	bio, err := helpers.NormalizeProfileText("bio", "  Hi!\nI like concerts. ", 500, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hi!\nI like concerts.", bio)
+
+	_, err = helpers.NormalizeProfileText("organization", "Acme\nCorp", 100, false)
+	assert.Error(t, err)
+	_, err = helpers.NormalizeProfileText("bio", "tab\x00", 500, true)
+	assert.Error(t, err)
+	_, err = helpers.NormalizeProfileText("organization", strings.Repeat("a", 101), 100, false)
+	assert.Error(t, err)
+}
+
+func TestNormalizeTimezone(t *testing.T) {
+	timezone, err := helpers.NormalizeTimezone(" Africa/Cairo ")
+	assert.NoError(t, err)
+	assert.Equal(t, "Africa/Cairo", timezone)
+
+	timezone, err = helpers.NormalizeTimezone("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", timezone)
+
+	for _, invalid := range []string{"Local", "Mars/Olympus", "+02:00"} {
+		_, err := helpers.NormalizeTimezone(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestLanguagePreferences_PreferredLanguage(t *testing.T) {
+	helpers.PreferredLanguage = func(userID int64) string {
+		if userID == 7 {
+			return "ar"
+		}
+		return ""
+	}
+	t.Cleanup(func() { helpers.PreferredLanguage = func(int64) string { return "" } })
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	assert.Equal(t, "en-US,en;q=0.9", helpers.LanguagePreferences(req))
+
+	req = req.WithContext(helpers.WithPrincipal(req.Context(), &helpers.Principal{ID: 7}))
+	preferences := helpers.LanguagePreferences(req)
+	assert.Equal(t, "ar", helpers.NegotiateLanguage(preferences, []string{"en", "ar"}, "en"))
+	// Events without the preferred language fall back to Accept-Language
+	assert.Equal(t, "en", helpers.NegotiateLanguage(preferences, []string{"de", "en"}, "de"))
+
+	// ?lang= still wins over the profile
+	req = httptest.NewRequest("GET", "/events?lang=en", nil)
+	req = req.WithContext(helpers.WithPrincipal(req.Context(), &helpers.Principal{ID: 7}))
+	assert.Equal(t, "en", helpers.LanguagePreferences(req))
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/repos"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	org := int64(2)
+	key := repos.APIKey{UserID: 7, Name: "ci", Prefix: "sk_abcdef", Scopes: []string{"events:create", "events:update"}, OrganizationID: &org}
+	expiresAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("INSERT INTO api_keys").
+		WithArgs(int64(7), "ci", "sk_abcdef", "hash", "events:create events:update", &org, "2025-06-01 12:00:00").
+		WillReturnResult(sqlmock.NewResult(5, 1))
+
+	id, err := repo.CreateAPIKey(key, "hash", &expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAPIKeys(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "prefix", "scopes", "organization_id", "created_at", "expires_at", "last_used_at", "last_used_ip"}).
+		AddRow(5, 7, "ci", "sk_abcdef", "events:create events:update", 2, "2025-05-17 10:00:00", nil, "2025-05-17 11:00:00", "10.0.0.1").
+		AddRow(4, 7, "report", "sk_ghijkl", "", nil, "2025-05-16 10:00:00", "2025-05-18 10:00:00", nil, nil)
+	mock.ExpectQuery("SELECT (.+) FROM api_keys k WHERE k.user_id = \\? AND k.revoked_at IS NULL").
+		WithArgs(int64(7)).
+		WillReturnRows(rows)
+
+	keys, err := repo.GetAPIKeys(7)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, []string{"events:create", "events:update"}, keys[0].Scopes)
+	assert.Equal(t, int64(2), *keys[0].OrganizationID)
+	assert.Equal(t, "10.0.0.1", *keys[0].LastUsedIP)
+	assert.Empty(t, keys[1].Scopes)
+	assert.Nil(t, keys[1].LastUsedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeAPIKey_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectExec("UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = \\? AND user_id = \\?").
+		WithArgs(int64(5), int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RevokeAPIKey(8, 5)
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAPIKeyOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "name", "prefix", "scopes", "organization_id", "created_at", "expires_at", "last_used_at", "last_used_ip", "username", "role"}).
+		AddRow(5, 7, "ci", "sk_abcdef", "events:create", nil, "2025-05-17 10:00:00", nil, nil, nil, "jane", "organizer")
+	mock.ExpectQuery("SELECT (.+) FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.key_hash = \\? AND k.revoked_at IS NULL (.+) AND u.deleted_at IS NULL").
+		WithArgs("hash").
+		WillReturnRows(rows)
+
+	owner, err := repo.GetAPIKeyOwner("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), owner.Key.ID)
+	assert.Equal(t, "jane", owner.Username)
+	assert.Equal(t, "organizer", owner.Role)
+	assert.Equal(t, []string{"events:create"}, owner.Key.Scopes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAPIKeyOwner_Unknown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectQuery("SELECT (.+) FROM api_keys k").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	owner, err := repo.GetAPIKeyOwner("hash")
+	assert.NoError(t, err)
+	assert.Nil(t, owner)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTouchAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectExec("UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP, last_used_ip = \\? WHERE id = \\? AND \\(last_used_at IS NULL OR last_used_at < \\?\\)").
+		WithArgs("10.0.0.1", int64(5), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.TouchAPIKey(5, "10.0.0.1"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
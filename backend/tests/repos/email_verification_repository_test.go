@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/repos"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE email = \\? AND id != \\?\\)").
+		WithArgs("jane@example.com", int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("UPDATE users SET email = \\?, email_verified_at = CASE WHEN email = \\? THEN email_verified_at ELSE NULL END").
+		WithArgs("jane@example.com", "jane@example.com", int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.SetEmail(7, "jane@example.com"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetEmail_Taken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("jane@example.com", int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	err = repo.SetEmail(8, "jane@example.com")
+	assert.True(t, errors.Is(err, repos.ErrAlreadyExists))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateEmailVerificationToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM email_verification_tokens WHERE user_id = \\? AND used_at IS NULL").
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO email_verification_tokens").
+		WithArgs(int64(7), "jane@example.com", "hash", "2025-05-18 10:00:00").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.CreateEmailVerificationToken(7, "jane@example.com", "hash", time.Date(2025, 5, 18, 10, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE email_verification_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = \\? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP (.+) RETURNING user_id, email").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "email"}).AddRow(int64(7), "jane@example.com"))
+	mock.ExpectExec("UPDATE users SET email_verified_at = COALESCE\\(email_verified_at, CURRENT_TIMESTAMP\\) WHERE id = \\? AND email = \\?").
+		WithArgs(int64(7), "jane@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.VerifyEmail("hash"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyEmail_InvalidToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE email_verification_tokens").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "email"}))
+	mock.ExpectRollback()
+
+	err = repo.VerifyEmail("hash")
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
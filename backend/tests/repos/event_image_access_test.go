@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const otherOrgImageHash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+// fetchOtherOrgEventImage fetches the image of event 7, which belongs to
+// another organization, without being signed in.
+func fetchOtherOrgEventImage(t *testing.T, query string) *httptest.ResponseRecorder {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	store := newMemoryBlobStore()
+	store.blobs["images/3/original.png"] = []byte{1, 2, 3}
+	router := chi.NewRouter()
+	router.Get("/events/{id}/image", routes.GetEventImage(repos.NewEventRepository(db), repos.NewImageRepository(db, store)))
+
+	mock.ExpectQuery("SELECT e.id, e.name").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "version", "image_id", "hash"}).
+			AddRow(int64(7), "Board meeting", "", "internal", "2026-11-02", "HQ", 0.0, int64(1), int64(3), otherOrgImageHash))
+	mock.ExpectQuery("SELECT language, name, description, venue FROM event_translations").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"language", "name", "description", "venue"}))
+	mock.ExpectQuery("SELECT v.image_id, i.hash, v.blob_key").
+		WithArgs(int64(3), "original").
+		WillReturnRows(sqlmock.NewRows([]string{"image_id", "hash", "blob_key", "size", "content_type", "width", "height", "byte_size"}).
+			AddRow(int64(3), otherOrgImageHash, "images/3/original.png", "original", "image/png", 640, 480, int64(3)))
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/events/7/image"+query, nil))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	return res
+}
+
+func TestGetEventImage_OtherOrganizationByID(t *testing.T) {
+	res := fetchOtherOrgEventImage(t, "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	assert.False(t, strings.HasPrefix(res.Header().Get("Content-Type"), "image/"))
+}
+
+func TestGetEventImage_WrongVersion(t *testing.T) {
+	// A prefix of the hash isn't enough, nor is a guess of the right length
+	for _, v := range []string{"9", "9f86d081", "0000000000000000", otherOrgImageHash} {
+		res := fetchOtherOrgEventImage(t, "?v="+v)
+		assert.Equal(t, http.StatusNotFound, res.Code, v)
+		assert.False(t, strings.HasPrefix(res.Header().Get("Content-Type"), "image/"), v)
+	}
+}
+
+func TestGetEventImage_VersionFromImageURL(t *testing.T) {
+	res := fetchOtherOrgEventImage(t, "?v="+repos.ImageVersion(otherOrgImageHash))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "image/png", res.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=31536000, immutable", res.Header().Get("Cache-Control"))
+	assert.Equal(t, []byte{1, 2, 3}, res.Body.Bytes())
+}
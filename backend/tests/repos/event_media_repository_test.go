@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddEventMedia(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	eventID := int64(1)
+	imageID := int64(7)
+	translations := []repos.EventMediaTranslation{{Language: "ar", Caption: "Caption AR", AltText: "Alt AR"}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM events WHERE id = \\? AND deleted_at IS NULL\\)").
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("INSERT INTO event_media").
+		WithArgs(eventID, imageID, "Venue map", "Map of the venue", eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(3)))
+	mock.ExpectExec("INSERT INTO event_media_translations").
+		WithArgs(int64(3), "ar", "Caption AR", "Alt AR").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mediaID, err := repo.AddEventMedia(eventID, imageID, "Venue map", "Map of the venue", translations)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), mediaID)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestAddEventMedia_EventNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(int64(99)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, err = repo.AddEventMedia(99, 7, "", "", nil)
+	assert.ErrorIs(t, err, repos.ErrNotFound)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestGetEventMedia(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	eventID := int64(1)
+
+	rows := sqlmock.NewRows([]string{"id", "event_id", "position", "caption", "alt_text", "image_id", "hash", "width", "height", "cover"}).
+		AddRow(int64(2), eventID, 1, "Map", "", int64(8), "fedcba9876543210fedcba9876543210", 600, 400, int64(1)).
+		AddRow(int64(1), eventID, 2, "Logo", "Sponsor logo", int64(7), "0123456789abcdef0123456789abcdef", 160, 160, int64(0))
+	mock.ExpectQuery("SELECT m.id, m.event_id, m.position").
+		WithArgs(eventID).
+		WillReturnRows(rows)
+
+	translationRows := sqlmock.NewRows([]string{"media_id", "language", "caption", "alt_text"}).
+		AddRow(int64(1), "ar", "Logo AR", "Alt AR")
+	mock.ExpectQuery("SELECT t.media_id, t.language, t.caption, t.alt_text").
+		WithArgs(eventID).
+		WillReturnRows(translationRows)
+
+	media, err := repo.GetEventMedia(eventID)
+	assert.NoError(t, err)
+	assert.Len(t, media, 2)
+	assert.True(t, media[0].Cover)
+	assert.Equal(t, "/events/1/media/2/image?v=fedcba9876543210", media[0].ImageURL)
+	assert.Empty(t, media[0].Translations)
+	assert.Len(t, media[1].Translations, 1)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestReorderEventMedia(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	eventID := int64(1)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT id FROM event_media WHERE event_id = ?").
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)))
+	mock.ExpectExec("UPDATE event_media SET position = \\? WHERE id = \\?").
+		WithArgs(1, int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE event_media SET position = \\? WHERE id = \\?").
+		WithArgs(2, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.ReorderEventMedia(eventID, []int64{2, 1})
+	assert.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestReorderEventMedia_IncompleteOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	eventID := int64(1)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT id FROM event_media WHERE event_id = ?").
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)).AddRow(int64(2)))
+	mock.ExpectRollback()
+
+	err = repo.ReorderEventMedia(eventID, []int64{2, 2})
+	assert.ErrorIs(t, err, repos.ErrInvalidInput)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestDeleteEventMedia(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	eventID := int64(1)
+	mediaID := int64(2)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.image_id FROM event_media m").
+		WithArgs(mediaID, eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"image_id"}).AddRow(int64(8)))
+	mock.ExpectExec("DELETE FROM event_media WHERE id = ?").
+		WithArgs(mediaID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE events SET image_id = NULL, version = version \\+ 1 WHERE id = \\? AND image_id = \\?").
+		WithArgs(eventID, int64(8)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	imageID, err := repo.DeleteEventMedia(eventID, mediaID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), imageID)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsEventOrganizer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM events WHERE id = \\? AND created_by = \\?\\) OR EXISTS \\(SELECT 1 FROM event_organizers").
+		WithArgs(int64(3), int64(7), int64(3), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"organizer"}).AddRow(true))
+
+	organizer, err := repo.IsEventOrganizer(3, 7)
+	assert.NoError(t, err)
+	assert.True(t, organizer)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetEventOrganizers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+	addedAt := "2025-05-17 10:10:00"
+
+	mock.ExpectQuery("SELECT u.id, u.username FROM events e JOIN users u ON u.id = e.created_by WHERE e.id = \\?").
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(7, "jane"))
+	mock.ExpectQuery("SELECT u.id, u.username, o.added_at FROM event_organizers o").
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "added_at"}).AddRow(8, "joe", addedAt))
+
+	organizers, err := repo.GetEventOrganizers(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []repos.EventOrganizer{
+		{UserID: 7, Username: "jane", Owner: true},
+		{UserID: 8, Username: "joe", AddedAt: &addedAt},
+	}, organizers)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddEventOrganizer_AlreadyOrganizer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(int64(3), int64(7), int64(3), int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"organizer"}).AddRow(true))
+
+	err = repo.AddEventOrganizer(3, 7)
+	assert.True(t, errors.Is(err, repos.ErrAlreadyExists))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddEventOrganizer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(int64(3), int64(8), int64(3), int64(8)).
+		WillReturnRows(sqlmock.NewRows([]string{"organizer"}).AddRow(false))
+	mock.ExpectExec("INSERT INTO event_organizers \\(event_id, user_id\\) VALUES \\(\\?, \\?\\)").
+		WithArgs(int64(3), int64(8)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	assert.NoError(t, repo.AddEventOrganizer(3, 8))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveEventOrganizer_NotCoOrganizer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectExec("DELETE FROM event_organizers WHERE event_id = \\? AND user_id = \\?").
+		WithArgs(int64(3), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RemoveEventOrganizer(3, 7)
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckInAttendee(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectQuery("SELECT checked_in_at FROM registrations WHERE event_id = \\? AND user_id = \\?").
+		WithArgs(int64(3), int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"checked_in_at"}).AddRow(nil))
+	mock.ExpectExec("UPDATE registrations SET checked_in_at = CURRENT_TIMESTAMP WHERE event_id = \\? AND user_id = \\? AND checked_in_at IS NULL").
+		WithArgs(int64(3), int64(9)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.CheckInAttendee(3, 9))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckInAttendee_NotRegistered(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectQuery("SELECT checked_in_at FROM registrations").
+		WithArgs(int64(3), int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"checked_in_at"}))
+
+	err = repo.CheckInAttendee(3, 9)
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckInAttendee_AlreadyCheckedIn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectQuery("SELECT checked_in_at FROM registrations").
+		WithArgs(int64(3), int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"checked_in_at"}).AddRow("2025-05-17 10:10:00"))
+
+	err = repo.CheckInAttendee(3, 9)
+	assert.True(t, errors.Is(err, repos.ErrAlreadyExists))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetEventAttendees(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+	checkedInAt := "2025-05-17 11:00:00"
+
+	mock.ExpectQuery("SELECT u.id, u.username, reg.registered_at, reg.checked_in_at FROM registrations reg").
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "registered_at", "checked_in_at"}).
+			AddRow(9, "pw1", "2025-05-17 10:00:00", checkedInAt).
+			AddRow(10, "sam", "2025-05-17 10:05:00", nil))
+
+	attendees, err := repo.GetEventAttendees(3)
+	assert.NoError(t, err)
+	assert.Len(t, attendees, 2)
+	assert.Equal(t, &checkedInAt, attendees[0].CheckedInAt)
+	assert.Nil(t, attendees[1].CheckedInAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
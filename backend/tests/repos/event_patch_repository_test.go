@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchEvent_FieldsAndTranslations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	id := int64(1)
+	version := int64(3)
+	price := 25.0
+	arVenue := "New Venue AR"
+	deName, deDesc, deVenue := "Name DE", "Desc DE", "Venue DE"
+
+	patch := repos.EventPatch{
+		Price: &price,
+		Translations: map[string]*repos.EventTranslationPatch{
+			"ar": {Venue: &arVenue},
+			"de": {Name: &deName, Description: &deDesc, Venue: &deVenue},
+			"fr": nil,
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE events SET price = \\?, version = version \\+ 1 WHERE id = \\? AND version = \\?").
+		WithArgs(price, id, version).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec("UPDATE event_translations").
+		WithArgs(nil, nil, arVenue, nil, nil, arVenue, id, "ar").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec("UPDATE event_translations").
+		WithArgs(deName, deDesc, deVenue, deName, deDesc, deVenue, id, "de").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO event_translations").
+		WithArgs(id, "de", deName, deDesc, deVenue).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+
+	mock.ExpectExec("DELETE FROM event_translations WHERE event_id = \\? AND language = \\?").
+		WithArgs(id, "fr").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.PatchEvent(id, version, patch)
+	assert.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestPatchEvent_IncompleteNewTranslation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	id := int64(1)
+	name := "Name DE"
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE events SET version = version \\+ 1 WHERE id = \\? AND version = \\?").
+		WithArgs(id, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE event_translations").
+		WithArgs(name, nil, nil, name, nil, nil, id, "de").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.PatchEvent(id, 1, repos.EventPatch{
+		Translations: map[string]*repos.EventTranslationPatch{"de": {Name: &name}},
+	})
+	assert.ErrorIs(t, err, repos.ErrInvalidInput)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestPatchEvent_StaleVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	id := int64(1)
+	name := "Renamed"
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE events SET name = \\?, text_updated_at = CASE .* END, version = version \\+ 1").
+		WithArgs(name, name, nil, nil, id, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT version FROM events WHERE id = ?").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(int64(2)))
+
+	err = repo.PatchEvent(id, 1, repos.EventPatch{Name: &name})
+	assert.ErrorIs(t, err, repos.ErrVersionConflict)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
@@ -2,8 +2,10 @@ package tests
 
 import (
 	"database/sql"
+	"errors"
 	"immodi/submission-backend/repos"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -16,11 +18,11 @@ func TestGetAllEvents(t *testing.T) {
 
 	repo := repos.NewEventRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price"}).
-		AddRow(int64(1), "Event1", "Desc1", "Cat1", "2025-01-01", "Venue1", 10.0).
-		AddRow(int64(2), "Event2", "Desc2", "Cat2", "2025-02-02", "Venue2", 20.0)
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "version"}).
+		AddRow(int64(1), "Event1", "Desc1", "Cat1", "2025-01-01", "Venue1", 10.0, int64(1)).
+		AddRow(int64(2), "Event2", "Desc2", "Cat2", "2025-02-02", "Venue2", 20.0, int64(1))
 
-	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price FROM events").
+	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price, version FROM events").
 		WillReturnRows(rows)
 
 	events, err := repo.GetAllEvents()
@@ -43,10 +45,10 @@ func TestGetEventById_Success(t *testing.T) {
 	eventID := int64(1)
 
 	// Mock event row
-	eventRows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "image"}).
-		AddRow(eventID, "Event1", "Desc1", "Cat1", "2025-01-01", "Venue1", 10.0, []byte{1, 2, 3})
+	eventRows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "version", "image_id", "hash"}).
+		AddRow(eventID, "Event1", "Desc1", "Cat1", "2025-01-01", "Venue1", 10.0, int64(3), int64(7), "0123456789abcdef0123456789abcdef")
 
-	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price, image FROM events WHERE id = ?").
+	mock.ExpectQuery("SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, e.version, e.image_id, i.hash FROM events e LEFT JOIN images i ON i.id = e.image_id WHERE e.id = \\?").
 		WithArgs(eventID).
 		WillReturnRows(eventRows)
 
@@ -63,6 +65,8 @@ func TestGetEventById_Success(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, event)
 	assert.Equal(t, eventID, event.ID)
+	assert.Equal(t, int64(3), event.Version)
+	assert.Equal(t, "/events/1/image?v=0123456789abcdef", event.ImageURL)
 	assert.Len(t, event.Translations, 2)
 
 	err = mock.ExpectationsWereMet()
@@ -78,7 +82,7 @@ func TestGetEventById_NotFound(t *testing.T) {
 
 	eventID := int64(999)
 
-	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price, image FROM events WHERE id = ?").
+	mock.ExpectQuery("SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, e.version, e.image_id, i.hash FROM events e LEFT JOIN images i ON i.id = e.image_id WHERE e.id = \\?").
 		WithArgs(eventID).
 		WillReturnError(sql.ErrNoRows)
 
@@ -99,10 +103,10 @@ func TestGetEventsByCategory(t *testing.T) {
 
 	category := "Cat1"
 
-	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price"}).
-		AddRow(int64(1), "Event1", "Desc1", category, "2025-01-01", "Venue1", 10.0)
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "version"}).
+		AddRow(int64(1), "Event1", "Desc1", category, "2025-01-01", "Venue1", 10.0, int64(1))
 
-	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price FROM events WHERE category = ?").
+	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price, version FROM events WHERE category = ?").
 		WithArgs(category).
 		WillReturnRows(rows)
 
@@ -120,7 +124,7 @@ func TestCreateEvent(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	repo := repos.NewEventRepository(db)
+	repo := repos.NewEventRepository(db).InOrganization(2)
 
 	name := "Event1"
 	description := "Desc1"
@@ -128,21 +132,20 @@ func TestCreateEvent(t *testing.T) {
 	date := "2025-01-01"
 	venue := "Venue1"
 	price := 10.0
-	image := []byte{1, 2, 3}
 
 	eventTranslations := []repos.EventTranslation{
 		{Language: "en", Name: "Name EN", Description: "Desc EN", Venue: "Venue EN"},
 	}
 
 	mock.ExpectExec("INSERT INTO events").
-		WithArgs(name, description, category, date, venue, price, image).
+		WithArgs(name, description, category, date, venue, price, int64(4), int64(2)).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectExec("INSERT INTO event_translations").
 		WithArgs(int64(1), eventTranslations[0].Language, eventTranslations[0].Name, eventTranslations[0].Description, eventTranslations[0].Venue).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	id, err := repo.CreateEvent(name, description, category, date, venue, price, image, eventTranslations)
+	id, err := repo.CreateEvent(name, description, category, date, venue, price, eventTranslations, 4)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), id)
 
@@ -158,32 +161,87 @@ func TestUpdateEvent(t *testing.T) {
 	repo := repos.NewEventRepository(db)
 
 	id := int64(1)
+	version := int64(2)
 	name := "Updated"
 	description := "Updated Desc"
 	category := "Updated Cat"
 	date := "2025-02-02"
 	venue := "Updated Venue"
 	price := 20.0
-	image := []byte{4, 5, 6}
 
 	eventTranslations := []repos.EventTranslation{
 		{Language: "en", Name: "Updated EN", Description: "Desc EN", Venue: "Venue EN"},
 	}
 
+	mock.ExpectBegin()
 	mock.ExpectExec("UPDATE events").
-		WithArgs(name, description, category, date, venue, price, image, id).
+		WithArgs(name, description, venue, name, description, category, date, venue, price, id, version).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	mock.ExpectExec("DELETE FROM event_translations WHERE event_id = ?").
-		WithArgs(id).
+	mock.ExpectExec("DELETE FROM event_translations WHERE event_id = \\? AND language NOT IN \\(\\?\\)").
+		WithArgs(id, "en").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	mock.ExpectExec("INSERT INTO event_translations").
+	mock.ExpectExec("INSERT INTO event_translations .* ON CONFLICT").
 		WithArgs(id, eventTranslations[0].Language, eventTranslations[0].Name, eventTranslations[0].Description, eventTranslations[0].Venue).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.UpdateEvent(id, version, name, description, category, date, venue, price, eventTranslations)
+	assert.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
 
-	err = repo.UpdateEvent(id, name, description, category, date, venue, price, image, eventTranslations)
+func TestUpdateEvent_TranslationFailureRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE events").
+		WithArgs("Updated", "Desc", "Venue", "Updated", "Desc", "Cat", "2025-02-02", "Venue", 20.0, int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM event_translations").
+		WithArgs(int64(1), "de").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO event_translations .* ON CONFLICT").
+		WillReturnError(errors.New("disk I/O error"))
+	// Neither the version bump nor the translations stick
+	mock.ExpectRollback()
+
+	err = repo.UpdateEvent(1, 2, "Updated", "Desc", "Cat", "2025-02-02", "Venue", 20.0, []repos.EventTranslation{
+		{Language: "de", Name: "Neu", Description: "Beschreibung", Venue: "Ort"},
+	})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateEvent_StaleVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	id := int64(1)
+	staleVersion := int64(2)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE events").
+		WithArgs("Updated", "Desc", "Venue", "Updated", "Desc", "Cat", "2025-02-02", "Venue", 20.0, id, staleVersion).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	mock.ExpectQuery("SELECT version FROM events WHERE id = ?").
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(int64(3)))
+
+	err = repo.UpdateEvent(id, staleVersion, "Updated", "Desc", "Cat", "2025-02-02", "Venue", 20.0, nil)
+	assert.ErrorIs(t, err, repos.ErrVersionConflict)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
@@ -197,13 +255,122 @@ func TestDeleteEvent(t *testing.T) {
 	repo := repos.NewEventRepository(db)
 
 	id := int64(1)
+	version := int64(4)
+
+	mock.ExpectExec("UPDATE events SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?").
+		WithArgs(id, version).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.DeleteEvent(id, version)
+	assert.NoError(t, err)
 
-	mock.ExpectExec("DELETE FROM events WHERE id = ?").
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestDeleteEvent_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	id := int64(999)
+
+	mock.ExpectExec("UPDATE events SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?").
+		WithArgs(id, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectQuery("SELECT version FROM events WHERE id = ?").
+		WithArgs(id).
+		WillReturnError(sql.ErrNoRows)
+
+	err = repo.DeleteEvent(id, 1)
+	assert.ErrorIs(t, err, repos.ErrNotFound)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestGetDeletedEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "deleted_at"}).
+		AddRow(int64(1), "Deleted Event", "Desc", "Cat", "2025-06-01", "Venue", 30.0, "2025-05-20 10:00:00")
+
+	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price, deleted_at FROM events WHERE deleted_at IS NOT NULL").
+		WillReturnRows(rows)
+
+	events, err := repo.GetDeletedEvents()
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.NotNil(t, events[0].DeletedAt)
+	assert.Equal(t, "2025-05-20 10:00:00", *events[0].DeletedAt)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestRestoreEvent(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	id := int64(1)
+
+	mock.ExpectExec("UPDATE events SET deleted_at = NULL WHERE id = ?").
 		WithArgs(id).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err = repo.DeleteEvent(id)
+	err = repo.RestoreEvent(id)
+	assert.NoError(t, err)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestRestoreEvent_NotInTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	id := int64(1)
+
+	mock.ExpectExec("UPDATE events SET deleted_at = NULL WHERE id = ?").
+		WithArgs(id).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RestoreEvent(id)
+	assert.ErrorIs(t, err, repos.ErrNotFound)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestPurgeDeletedEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	cutoff := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("DELETE FROM events WHERE deleted_at IS NOT NULL AND deleted_at < ?").
+		WithArgs("2025-05-01 12:00:00").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	purged, err := repo.PurgeDeletedEvents(cutoff)
 	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
@@ -216,10 +383,10 @@ func TestGetUpcomingEvents(t *testing.T) {
 
 	repo := repos.NewEventRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "image"}).
-		AddRow(int64(1), "Upcoming Event", "Desc", "Cat", "2025-06-01", "Venue", 30.0, []byte{1, 2})
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "hash"}).
+		AddRow(int64(1), "Upcoming Event", "Desc", "Cat", "2025-06-01", "Venue", 30.0, nil)
 
-	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price, image FROM events WHERE date >= datetime\\('now'\\) ORDER BY date ASC").
+	mock.ExpectQuery("SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, i.hash FROM events e LEFT JOIN images i ON i.id = e.image_id WHERE e.deleted_at IS NULL AND e.date >= datetime\\('now'\\)").
 		WillReturnRows(rows)
 
 	events, err := repo.GetUpcomingEvents()
@@ -241,10 +408,10 @@ func TestSearchEvents(t *testing.T) {
 	keyword := "party"
 	searchTerm := "%" + keyword + "%"
 
-	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price"}).
-		AddRow(int64(1), "Party Event", "Desc", "Fun", "2025-07-07", "Club", 50.0)
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "version"}).
+		AddRow(int64(1), "Party Event", "Desc", "Fun", "2025-07-07", "Club", 50.0, int64(1))
 
-	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price FROM events WHERE name LIKE ?").
+	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price, version FROM events WHERE name LIKE ?").
 		WithArgs(searchTerm, searchTerm, searchTerm).
 		WillReturnRows(rows)
 
@@ -287,10 +454,10 @@ func TestGetEventsForUser(t *testing.T) {
 
 	userID := int64(1)
 
-	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "image"}).
-		AddRow(int64(1), "User Event", "Desc", "Cat", "2025-08-01", "Venue", 40.0, []byte{1, 2})
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "hash"}).
+		AddRow(int64(1), "User Event", "Desc", "Cat", "2025-08-01", "Venue", 40.0, "fedcba9876543210fedcba9876543210")
 
-	mock.ExpectQuery("SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, e.image FROM events e JOIN registrations r ON e.id = r.event_id WHERE r.user_id = ?").
+	mock.ExpectQuery("SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, i.hash FROM events e JOIN registrations r ON e.id = r.event_id LEFT JOIN images i ON i.id = e.image_id WHERE r.user_id = ?").
 		WithArgs(userID).
 		WillReturnRows(rows)
 
@@ -298,6 +465,7 @@ func TestGetEventsForUser(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, events, 1)
 	assert.Equal(t, "User Event", events[0].Name)
+	assert.Equal(t, "/events/1/image?v=fedcba9876543210", events[0].ImageURL)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"encoding/json"
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordEventRevision(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	eventID := int64(1)
+
+	eventRows := sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "version", "image_id", "hash"}).
+		AddRow(eventID, "Event1", "Desc1", "Cat1", "2025-01-01", "Venue1", 10.0, int64(1), nil, nil)
+	mock.ExpectQuery("SELECT e.id, e.name, e.description, e.category, e.date, e.venue, e.price, e.version, e.image_id, i.hash FROM events e LEFT JOIN images i ON i.id = e.image_id WHERE e.id = \\?").
+		WithArgs(eventID).
+		WillReturnRows(eventRows)
+
+	transRows := sqlmock.NewRows([]string{"language", "name", "description", "venue"}).
+		AddRow("ar", "Event1 AR", "Desc AR", "Venue AR")
+	mock.ExpectQuery("SELECT language, name, description, venue FROM event_translations WHERE event_id = ?").
+		WithArgs(eventID).
+		WillReturnRows(transRows)
+
+	expectedSnapshot, err := json.Marshal(repos.EventSnapshot{
+		Name:         "Event1",
+		Description:  "Desc1",
+		Category:     "Cat1",
+		Date:         "2025-01-01",
+		Venue:        "Venue1",
+		Price:        10.0,
+		Translations: []repos.EventTranslation{{Language: "ar", Name: "Event1 AR", Description: "Desc AR", Venue: "Venue AR"}},
+	})
+	assert.NoError(t, err)
+
+	mock.ExpectQuery("INSERT INTO event_revisions").
+		WithArgs(eventID, repos.RevisionActionUpdate, "admin", string(expectedSnapshot), eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"revision"}).AddRow(int64(4)))
+
+	revision, err := repo.RecordEventRevision(eventID, repos.RevisionActionUpdate, "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), revision)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestGetEventRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	eventID := int64(1)
+
+	rows := sqlmock.NewRows([]string{"event_id", "revision", "action", "author", "created_at", "snapshot"}).
+		AddRow(eventID, int64(1), "create", "admin", "2025-05-17 10:00:00", `{"name":"Event1","price":10,"translations":[]}`).
+		AddRow(eventID, int64(2), "update", "admin", "2025-05-18 10:00:00", `{"name":"Event1","price":12,"translations":[]}`)
+
+	mock.ExpectQuery("SELECT event_id, revision, action, author, created_at, snapshot FROM event_revisions WHERE event_id = ?").
+		WithArgs(eventID).
+		WillReturnRows(rows)
+
+	revisions, err := repo.GetEventRevisions(eventID)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, "create", revisions[0].Action)
+	assert.Equal(t, 12.0, revisions[1].Snapshot.Price)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestGetEventRevision_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectQuery("SELECT event_id, revision, action, author, created_at, snapshot FROM event_revisions WHERE event_id = \\? AND revision = \\?").
+		WithArgs(int64(1), int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"event_id", "revision", "action", "author", "created_at", "snapshot"}))
+
+	revision, err := repo.GetEventRevision(1, 9)
+	assert.NoError(t, err)
+	assert.Nil(t, revision)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestDiffEventSnapshots(t *testing.T) {
+	from := repos.EventSnapshot{
+		Name:  "Event1",
+		Venue: "Hall A",
+		Price: 10,
+		Translations: []repos.EventTranslation{
+			{Language: "ar", Name: "Event1 AR", Description: "Desc AR", Venue: "Venue AR"},
+			{Language: "fr", Name: "Event1 FR", Description: "Desc FR", Venue: "Venue FR"},
+		},
+	}
+	to := repos.EventSnapshot{
+		Name:  "Event1",
+		Venue: "Hall B",
+		Price: 15,
+		Translations: []repos.EventTranslation{
+			{Language: "ar", Name: "Event1 AR", Description: "Desc AR", Venue: "New Venue AR"},
+			{Language: "de", Name: "Event1 DE", Description: "Desc DE", Venue: "Venue DE"},
+		},
+	}
+
+	changes := repos.DiffEventSnapshots(from, to)
+
+	assert.Equal(t, []repos.FieldChange{
+		{Field: "venue", From: "Hall A", To: "Hall B"},
+		{Field: "price", From: 10.0, To: 15.0},
+		{Field: "translations.ar.venue", From: "Venue AR", To: "New Venue AR"},
+		{Field: "translations.de", From: nil, To: to.Translations[1]},
+		{Field: "translations.fr", From: from.Translations[1], To: nil},
+	}, changes)
+}
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveEventTranslation_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	eventID := int64(1)
+	translation := repos.EventTranslation{Language: "ar", Name: "Name AR", Description: "Desc AR", Venue: "Venue AR"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM events").
+		WithArgs(eventID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM event_translations").
+		WithArgs(eventID, "ar").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("INSERT INTO event_translations .* ON CONFLICT \\(event_id, language\\) DO UPDATE").
+		WithArgs(eventID, "ar", "Name AR", "Desc AR", "Venue AR").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE events SET version = version \\+ 1 WHERE id = ?").
+		WithArgs(eventID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	created, err := repo.SaveEventTranslation(eventID, translation)
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestSaveEventTranslation_EventNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM events").
+		WithArgs(int64(99)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, err = repo.SaveEventTranslation(99, repos.EventTranslation{Language: "ar", Name: "n", Description: "d", Venue: "v"})
+	assert.ErrorIs(t, err, repos.ErrNotFound)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestDeleteEventTranslation_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM event_translations").
+		WithArgs(int64(1), "fr").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.DeleteEventTranslation(1, "fr")
+	assert.ErrorIs(t, err, repos.ErrNotFound)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestGetTranslationsForEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	rows := sqlmock.NewRows([]string{"event_id", "language", "name", "description", "venue"}).
+		AddRow(int64(1), "ar", "Event1 AR", "Desc AR", "Venue AR").
+		AddRow(int64(2), "fr", "Event2 FR", "Desc FR", "Venue FR")
+	mock.ExpectQuery("SELECT event_id, language, name, description, venue FROM event_translations WHERE event_id IN \\(\\?, \\?\\)").
+		WithArgs(int64(1), int64(2)).
+		WillReturnRows(rows)
+
+	translations, err := repo.GetTranslationsForEvents([]int64{1, 2})
+	assert.NoError(t, err)
+	assert.Len(t, translations[1], 1)
+	assert.Equal(t, "Event2 FR", translations[2][0].Name)
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
+
+func TestEventLocalize(t *testing.T) {
+	event := repos.Event{
+		Name:        "Concert",
+		Description: "Live music",
+		Venue:       "Hall",
+		Translations: []repos.EventTranslation{
+			{Language: "ar", Name: "حفلة", Description: "موسيقى حية", Venue: "قاعة"},
+		},
+	}
+
+	assert.Equal(t, []string{"en", "ar"}, event.Languages("en"))
+
+	event.Localize("ar")
+	assert.Equal(t, "ar", event.Language)
+	assert.Equal(t, "حفلة", event.Name)
+	assert.Equal(t, "قاعة", event.Venue)
+}
+
+func TestGetTranslationReport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db)
+
+	edited := "2025-03-01 10:00:00"
+	translated := "2025-02-01 10:00:00"
+
+	rows := sqlmock.NewRows([]string{"id", "name", "text_updated_at", "language", "name", "description", "venue", "updated_at", "stale"}).
+		AddRow(int64(1), "Concert", edited, "ar", "حفلة", "", "قاعة", translated, int64(1)).
+		AddRow(int64(2), "Talk", nil, nil, nil, nil, nil, nil, int64(0))
+	mock.ExpectQuery("SELECT e.id, e.name, e.text_updated_at, t.language").
+		WillReturnRows(rows)
+
+	reports, err := repo.GetTranslationReport([]string{"ar"})
+	assert.NoError(t, err)
+	assert.Len(t, reports, 2)
+
+	concert := reports[0].Languages[0]
+	assert.False(t, reports[0].Complete)
+	assert.Equal(t, repos.TranslationStale, concert.Fields["name"])
+	assert.Equal(t, repos.TranslationMissing, concert.Fields["description"])
+	assert.Equal(t, &translated, concert.UpdatedAt)
+
+	talk := reports[1].Languages[0]
+	assert.False(t, talk.Complete)
+	assert.Equal(t, repos.TranslationMissing, talk.Fields["venue"])
+
+	err = mock.ExpectationsWereMet()
+	assert.NoError(t, err)
+}
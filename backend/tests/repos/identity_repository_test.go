@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/repos"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumeOIDCLogin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	expiresAt := time.Date(2025, 5, 17, 10, 10, 0, 0, time.UTC)
+
+	mock.ExpectQuery("DELETE FROM oidc_logins WHERE state_hash = \\? AND expires_at > CURRENT_TIMESTAMP RETURNING").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"code_verifier", "nonce", "user_id", "browser_hash", "expires_at"}).
+			AddRow("verifier", "nonce", 3, "browser", expiresAt))
+
+	login, err := repo.ConsumeOIDCLogin("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, "verifier", login.CodeVerifier)
+	assert.Equal(t, "nonce", login.Nonce)
+	assert.Equal(t, int64(3), *login.UserID)
+	assert.Equal(t, "browser", login.BrowserHash)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConsumeOIDCLogin_Unknown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectQuery("DELETE FROM oidc_logins").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"code_verifier", "nonce", "user_id", "expires_at"}))
+
+	_, err = repo.ConsumeOIDCLogin("hash")
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAuthUserByIdentity_NotLinked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectQuery("SELECT (.+) FROM user_identities i JOIN users u ON u.id = i.user_id").
+		WithArgs("https://idp.example.com", "1234").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "role", "created_at", "password_hash"}))
+
+	user, err := repo.GetAuthUserByIdentity("https://idp.example.com", "1234")
+	assert.NoError(t, err)
+	assert.Nil(t, user)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLinkIdentity_AlreadyLinked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectExec("INSERT INTO user_identities (.+) ON CONFLICT \\(issuer, subject\\) DO NOTHING").
+		WithArgs(int64(1), "https://idp.example.com", "1234").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.LinkIdentity(1, "https://idp.example.com", "1234")
+	assert.True(t, errors.Is(err, repos.ErrAlreadyExists))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProvisionUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE username = \\?\\)").
+		WithArgs("jane").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("INSERT INTO users \\(username, password_hash, role\\) VALUES \\(\\?, '', \\?\\)").
+		WithArgs("jane", "user").
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec("INSERT INTO user_identities").
+		WithArgs(int64(7), "https://idp.example.com", "1234").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	user, err := repo.ProvisionUser("jane", "user", "https://idp.example.com", "1234")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), user.ID)
+	assert.Equal(t, "jane", user.Username)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProvisionUser_UsernameTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("jane").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, err = repo.ProvisionUser("jane", "user", "https://idp.example.com", "1234")
+	assert.True(t, errors.Is(err, repos.ErrAlreadyExists))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
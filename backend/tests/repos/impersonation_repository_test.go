@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordImpersonation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectExec("INSERT INTO impersonation_log").
+		WithArgs(int64(1), int64(7), "jti", "GET", "/users/data", 200, "10.0.0.1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = repo.RecordImpersonation(repos.ImpersonationEntry{ActorID: 1, UserID: 7, TokenID: "jti", Method: "GET", Path: "/users/data", Status: 200, IP: "10.0.0.1"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetImpersonationLog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "actor_id", "actor_username", "user_id", "username", "token_id", "method", "path", "status", "ip", "created_at"}).
+		AddRow(2, 1, "admin", 7, "jane", "jti", "GET", "/users/data", 200, "10.0.0.1", "2025-05-17 10:01:00").
+		AddRow(1, 1, "admin", 7, nil, "jti", "POST", "/users/7/impersonate", 201, "10.0.0.1", "2025-05-17 10:00:00")
+	mock.ExpectQuery("SELECT (.+) FROM impersonation_log l (.+) WHERE 1 = 1 AND l.user_id = \\? ORDER BY l.id DESC LIMIT \\?").
+		WithArgs(int64(7), 100).
+		WillReturnRows(rows)
+
+	entries, err := repo.GetImpersonationLog(0, 7, 100)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "jane", *entries[0].Username)
+	assert.Nil(t, entries[1].Username)
+	assert.Equal(t, "/users/7/impersonate", entries[1].Path)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"immodi/submission-backend/repos"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLockoutPolicy = repos.LockoutPolicy{
+	Threshold:   3,
+	MaxFailures: 10,
+	BaseDelay:   time.Second,
+	Lockout:     15 * time.Minute,
+	Window:      time.Hour,
+}
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), testLockoutPolicy.Delay(2))
+	assert.Equal(t, time.Second, testLockoutPolicy.Delay(3))
+	assert.Equal(t, 2*time.Second, testLockoutPolicy.Delay(4))
+	assert.Equal(t, 32*time.Second, testLockoutPolicy.Delay(8))
+	assert.Equal(t, 15*time.Minute, testLockoutPolicy.Delay(10))
+
+	// The backoff never goes beyond the lockout
+	policy := testLockoutPolicy
+	policy.MaxFailures = 100
+	assert.Equal(t, 15*time.Minute, policy.Delay(60))
+}
+
+func TestGetLoginLockout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	lockedUntil := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+
+	mock.ExpectQuery("SELECT locked_until FROM login_failures WHERE kind = \\? AND value = \\?").
+		WithArgs("username", "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"locked_until"}).AddRow(lockedUntil))
+
+	until, err := repo.GetLoginLockout("username", "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, &lockedUntil, until)
+
+	// A lockout that is over doesn't count
+	mock.ExpectQuery("SELECT locked_until FROM login_failures").
+		WithArgs("ip", "127.0.0.1").
+		WillReturnRows(sqlmock.NewRows([]string{"locked_until"}).AddRow(time.Now().Add(-time.Minute)))
+
+	until, err = repo.GetLoginLockout("ip", "127.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, until)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	// The first failure only gets counted
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT failures, last_failed_at FROM login_failures WHERE kind = \\? AND value = \\?").
+		WithArgs("username", "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failed_at"}))
+	mock.ExpectExec("INSERT INTO login_failures (.+) ON CONFLICT \\(kind, value\\) DO UPDATE").
+		WithArgs("username", "admin", 1, sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	until, err := repo.RecordLoginFailure("username", "admin", testLockoutPolicy)
+	assert.NoError(t, err)
+	assert.Nil(t, until)
+
+	// Reaching the threshold starts the backoff
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT failures, last_failed_at FROM login_failures").
+		WithArgs("username", "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failed_at"}).AddRow(3, time.Now().Add(-time.Minute)))
+	mock.ExpectExec("INSERT INTO login_failures").
+		WithArgs("username", "admin", 4, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	until, err = repo.RecordLoginFailure("username", "admin", testLockoutPolicy)
+	assert.NoError(t, err)
+	assert.NotNil(t, until)
+	assert.WithinDuration(t, time.Now().Add(2*time.Second), *until, time.Second)
+
+	// Failures outside the window are forgotten
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT failures, last_failed_at FROM login_failures").
+		WithArgs("username", "admin").
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "last_failed_at"}).AddRow(9, time.Now().Add(-2*time.Hour)))
+	mock.ExpectExec("INSERT INTO login_failures").
+		WithArgs("username", "admin", 1, sqlmock.AnyArg(), nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	until, err = repo.RecordLoginFailure("username", "admin", testLockoutPolicy)
+	assert.NoError(t, err)
+	assert.Nil(t, until)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClearLoginFailures(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectExec("DELETE FROM login_failures WHERE kind = \\? AND value = \\?").
+		WithArgs("ip", "127.0.0.1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	cleared, err := repo.ClearLoginFailures("ip", "127.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, cleared)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetLoginFailures(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	since := time.Date(2025, 5, 17, 9, 0, 0, 0, time.UTC)
+	lockedUntil := time.Now().Add(10 * time.Minute).UTC().Truncate(time.Second)
+	failedAt := time.Now().UTC().Truncate(time.Second)
+
+	rows := sqlmock.NewRows([]string{"kind", "value", "failures", "last_failed_at", "locked_until"}).
+		AddRow("username", "admin", 10, failedAt, lockedUntil).
+		AddRow("ip", "127.0.0.1", 2, failedAt, nil)
+	mock.ExpectQuery("SELECT kind, value, failures, last_failed_at, locked_until FROM login_failures WHERE last_failed_at > \\?").
+		WithArgs("2025-05-17 09:00:00").
+		WillReturnRows(rows)
+
+	failures, err := repo.GetLoginFailures(since)
+	assert.NoError(t, err)
+	assert.Equal(t, []repos.LoginFailures{
+		{Kind: "username", Value: "admin", Failures: 10, LastFailedAt: failedAt, LockedUntil: &lockedUntil},
+		{Kind: "ip", Value: "127.0.0.1", Failures: 2, LastFailedAt: failedAt},
+	}, failures)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrganization_BySlug(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewOrganizationRepository(db)
+
+	mock.ExpectQuery("SELECT o.id, o.slug, o.name, o.created_at, (.+) FROM organizations o WHERE o.slug = \\?").
+		WithArgs("acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "slug", "name", "created_at", "members", "events"}).
+			AddRow(2, "acme", "Acme", "2025-05-17T10:00:00Z", 3, 1))
+
+	org, err := repo.GetOrganization("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, &repos.Organization{ID: 2, Slug: "acme", Name: "Acme", CreatedAt: "2025-05-17T10:00:00Z", Members: 3, Events: 1}, org)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetOrganization_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewOrganizationRepository(db)
+
+	mock.ExpectQuery("FROM organizations o WHERE o.id = \\?").
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "slug", "name", "created_at", "members", "events"}))
+
+	_, err = repo.GetOrganization("9")
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateOrganization_AlreadyExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewOrganizationRepository(db)
+
+	mock.ExpectExec("INSERT INTO organizations \\(slug, name\\) VALUES \\(\\?, \\?\\) ON CONFLICT \\(slug\\) DO NOTHING").
+		WithArgs("acme", "Acme").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = repo.CreateOrganization("acme", "Acme")
+	assert.True(t, errors.Is(err, repos.ErrAlreadyExists))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteOrganization_InUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewOrganizationRepository(db)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM events WHERE organization_id = \\?").
+		WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	err = repo.DeleteOrganization(2)
+	assert.True(t, errors.Is(err, repos.ErrInUse))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMembership_NotAMember(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewOrganizationRepository(db)
+
+	mock.ExpectQuery("LEFT JOIN organization_members m ON m.organization_id = o.id AND m.user_id = \\?\\s+WHERE o.slug = \\?").
+		WithArgs(int64(7), "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "slug", "name", "role"}).AddRow(2, "acme", "Acme", ""))
+
+	membership, err := repo.GetMembership(7, "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), membership.OrganizationID)
+	assert.Empty(t, membership.Role)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMembership_UnknownOrganization(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewOrganizationRepository(db)
+
+	mock.ExpectQuery("FROM organizations o").
+		WithArgs(int64(7), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "slug", "name", "role"}))
+
+	membership, err := repo.GetMembership(7, "5")
+	assert.NoError(t, err)
+	assert.Nil(t, membership)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddMember_AlreadyMember(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewOrganizationRepository(db)
+
+	mock.ExpectExec("INSERT INTO organization_members \\(organization_id, user_id, role\\) VALUES \\(\\?, \\?, \\?\\) ON CONFLICT DO NOTHING").
+		WithArgs(int64(2), int64(7), "user").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.AddMember(2, 7, "user")
+	assert.True(t, errors.Is(err, repos.ErrAlreadyExists))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveMember_NotAMember(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewOrganizationRepository(db)
+
+	mock.ExpectExec("DELETE FROM organization_members WHERE organization_id = \\? AND user_id = \\?").
+		WithArgs(int64(2), int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RemoveMember(2, 7)
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDefaultOrganization_NoMemberships(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectQuery("SELECT organization_id FROM organization_members WHERE user_id = \\? ORDER BY joined_at, organization_id LIMIT 1").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"organization_id"}))
+
+	orgID, err := repo.GetDefaultOrganization(7)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), orgID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEventRepository_InOrganization(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db).InOrganization(2)
+
+	mock.ExpectQuery("SELECT id, name, description, category, date, venue, price, version FROM events WHERE deleted_at IS NULL AND organization_id = 2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "category", "date", "venue", "price", "version"}))
+
+	events, err := repo.GetAllEvents()
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRegisterUserToEvent_OutsideOrganization(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewEventRepository(db).InOrganization(2)
+
+	mock.ExpectExec("INSERT INTO registrations \\(user_id, event_id\\)\\s+SELECT u.id, e.id FROM users u, events e\\s+WHERE u.id = \\? AND e.id = \\? AND u.id IN \\(SELECT user_id FROM organization_members WHERE organization_id = 2\\) AND e.organization_id = 2").
+		WithArgs(int64(7), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RegisterUserToEvent(7, 3)
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_InOrganization(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db).InOrganization(2)
+
+	mock.ExpectQuery("SELECT id, username, role, tickets, created_at, display_name, email, email_verified_at, organization, bio, preferred_language, timezone, avatar_image_id, \\(SELECT hash FROM images WHERE images.id = users.avatar_image_id\\) FROM users WHERE id = \\? AND deleted_at IS NULL AND id IN \\(SELECT user_id FROM organization_members WHERE organization_id = 2\\)").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at", "display_name", "email", "email_verified_at", "organization", "bio", "preferred_language", "timezone", "avatar_image_id", "avatar_hash"}))
+
+	user, err := repo.GetUserById(7)
+	assert.NoError(t, err)
+	assert.Nil(t, user)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
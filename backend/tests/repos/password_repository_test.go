@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockHashPassword(t *testing.T, password, hash string) {
+	origHashFunc := helpers.HashPassword
+	t.Cleanup(func() { helpers.HashPassword = origHashFunc })
+
+	helpers.HashPassword = func(pw string) (string, error) {
+		assert.Equal(t, password, pw)
+		return hash, nil
+	}
+}
+
+func expectSetPassword(mock sqlmock.Sqlmock, userID int64, hash string) {
+	mock.ExpectQuery("SELECT username FROM users WHERE id = \\? AND deleted_at IS NULL").
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("testuser"))
+	mock.ExpectExec("UPDATE users SET password_hash = \\? WHERE id = \\?").
+		WithArgs(hash, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM password_reset_tokens WHERE user_id = \\? AND used_at IS NULL").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT OR IGNORE INTO revoked_tokens (.+) FROM sessions WHERE user_id = \\?").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestChangePassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	mockHashPassword(t, "new-secret-42", "newhash")
+
+	mock.ExpectBegin()
+	expectSetPassword(mock, 1, "newhash")
+	mock.ExpectCommit()
+
+	err = repo.ChangePassword(1, "new-secret-42")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePassword_UserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	mockHashPassword(t, "new-secret-42", "newhash")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}))
+	mock.ExpectRollback()
+
+	err = repo.ChangePassword(9, "new-secret-42")
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangePassword_PolicyViolation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT username FROM users").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("testuser"))
+	mock.ExpectRollback()
+
+	err = repo.ChangePassword(1, "testuser")
+
+	var policyErr *helpers.PasswordPolicyError
+	assert.True(t, errors.As(err, &policyErr))
+	assert.Len(t, policyErr.Violations, 1)
+	assert.Equal(t, "username", policyErr.Violations[0].Rule)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreatePasswordResetToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	expiresAt := time.Date(2025, 5, 17, 11, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM password_reset_tokens WHERE user_id = \\? AND used_at IS NULL").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO password_reset_tokens").
+		WithArgs(int64(1), "hash", "2025-05-17 11:00:00").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.CreatePasswordResetToken(1, "hash", expiresAt)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResetPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	mockHashPassword(t, "new-secret-42", "newhash")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = \\? AND used_at IS NULL").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(1))
+	expectSetPassword(mock, 1, "newhash")
+	mock.ExpectCommit()
+
+	err = repo.ResetPassword("hash", "new-secret-42")
+	assert.NoError(t, err)
+
+	// The token can only be used once
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE password_reset_tokens SET used_at").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectRollback()
+
+	err = repo.ResetPassword("hash", "new-secret-42")
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := helpers.PasswordPolicy{
+		MinLength:     10,
+		MaxLength:     64,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	assert.NoError(t, policy.Check("testuser", "Correct-Horse-42"))
+
+	err := policy.Check("testuser", "qwerty")
+	var policyErr *helpers.PasswordPolicyError
+	assert.True(t, errors.As(err, &policyErr))
+
+	rules := []string{}
+	for _, v := range policyErr.Violations {
+		rules = append(rules, v.Rule)
+	}
+	assert.Equal(t, []string{"min_length", "uppercase", "digit", "symbol", "breached"}, rules)
+
+	err = policy.Check("Long-Username-1", "long-username-1")
+	assert.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, "uppercase", policyErr.Violations[0].Rule)
+	assert.Equal(t, "username", policyErr.Violations[1].Rule)
+}
+
+func TestPasswordHashers(t *testing.T) {
+	argon := helpers.Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	bcryptHasher := helpers.BcryptHasher{Cost: 4}
+
+	argonHash, err := argon.Hash("correct-horse-42")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=1024,t=1,p=1\$`, argonHash)
+	assert.True(t, argon.Verify("correct-horse-42", argonHash))
+	assert.False(t, argon.Verify("wrong-horse-42", argonHash))
+	assert.False(t, argon.NeedsRehash(argonHash))
+
+	stronger := argon
+	stronger.Iterations = 2
+	assert.True(t, stronger.NeedsRehash(argonHash))
+
+	bcryptHash, err := bcryptHasher.Hash("correct-horse-42")
+	assert.NoError(t, err)
+	assert.True(t, bcryptHasher.Owns(bcryptHash))
+	assert.False(t, bcryptHasher.Owns(argonHash))
+	assert.True(t, bcryptHasher.Verify("correct-horse-42", bcryptHash))
+	assert.True(t, helpers.BcryptHasher{Cost: 10}.NeedsRehash(bcryptHash))
+}
+
+func TestCheckPasswordHashAcrossAlgorithms(t *testing.T) {
+	t.Setenv("PASSWORD_HASH_ALGORITHM", "argon2id")
+	t.Setenv("ARGON2_MEMORY_KIB", "1024")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+
+	// Hashes from before the switch to argon2id keep working until they are rehashed
+	bcryptHash, err := helpers.BcryptHasher{Cost: 4}.Hash("correct-horse-42")
+	assert.NoError(t, err)
+	assert.True(t, helpers.CheckPasswordHash("correct-horse-42", bcryptHash))
+	assert.True(t, helpers.PasswordNeedsRehash(bcryptHash))
+
+	argonHash, err := helpers.HashPassword("correct-horse-42")
+	assert.NoError(t, err)
+	assert.True(t, helpers.CheckPasswordHash("correct-horse-42", argonHash))
+	assert.False(t, helpers.PasswordNeedsRehash(argonHash))
+
+	assert.False(t, helpers.CheckPasswordHash("correct-horse-42", "not a hash"))
+}
+
+func TestRehashPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectExec("UPDATE users SET password_hash = \\? WHERE id = \\? AND password_hash = \\?").
+		WithArgs("newhash", int64(1), "oldhash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.RehashPassword(1, "oldhash", "newhash")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateProfile_OnlyPatchedFields(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db)
+
+	bio, timezone := "Likes concerts", ""
+	mock.ExpectExec("UPDATE users SET bio = \\?, timezone = \\? WHERE id = \\? AND deleted_at IS NULL").
+		WithArgs(bio, timezone, int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.UpdateProfile(7, repos.ProfilePatch{Bio: &bio, Timezone: &timezone})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateProfile_EmptyPatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db)
+
+	assert.NoError(t, repo.UpdateProfile(7, repos.ProfilePatch{}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateProfile_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db)
+
+	language := "ar"
+	mock.ExpectExec("UPDATE users SET preferred_language = \\? WHERE id = \\?").
+		WithArgs(language, int64(99)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.UpdateProfile(99, repos.ProfilePatch{PreferredLanguage: &language})
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetAvatar_ReturnsPrevious(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db)
+
+	imageID := int64(12)
+	mock.ExpectQuery("SELECT avatar_image_id FROM users WHERE id = \\?").
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"avatar_image_id"}).AddRow(int64(5)))
+	mock.ExpectExec("UPDATE users SET avatar_image_id = \\? WHERE id = \\?").
+		WithArgs(imageID, int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	previous, err := repo.SetAvatar(7, &imageID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), *previous)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserById_Profile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at", "display_name", "email", "email_verified_at", "organization", "bio", "preferred_language", "timezone", "avatar_image_id", "avatar_hash"}).
+		AddRow(int64(7), "jane", "user", int64(3), "2025-05-17T10:00:00Z", "Jane Doe", nil, nil, "Acme", "Likes concerts", "ar", "Africa/Cairo", int64(12), "0123456789abcdef0123")
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE id = \\?").
+		WithArgs(int64(7)).
+		WillReturnRows(rows)
+
+	user, err := repo.GetUserById(7)
+	assert.NoError(t, err)
+	assert.Equal(t, "Acme", user.Organization)
+	assert.Equal(t, "ar", user.PreferredLanguage)
+	assert.Equal(t, "Africa/Cairo", user.Timezone)
+	assert.Equal(t, int64(12), *user.AvatarImageID)
+	assert.Equal(t, "/users/7/avatar?v=0123456789abcdef", user.AvatarURL)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
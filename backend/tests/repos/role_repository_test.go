@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewRoleRepository(db)
+
+	mock.ExpectQuery("SELECT r.name, r.description, r.builtin, (.+) FROM roles r ORDER BY r.builtin DESC, r.name").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "description", "builtin", "users"}).
+			AddRow("admin", "Full access to everything", true, 1).
+			AddRow("user", "Default role of new users", true, 4).
+			AddRow("editor", "Edits events", false, 0))
+	mock.ExpectQuery("SELECT role, permission FROM role_permissions").
+		WithArgs("", "").
+		WillReturnRows(sqlmock.NewRows([]string{"role", "permission"}).
+			AddRow("admin", "events:create").
+			AddRow("admin", "events:update").
+			AddRow("editor", "events:update"))
+
+	roles, err := repo.GetRoles()
+	assert.NoError(t, err)
+	assert.Len(t, roles, 3)
+	assert.Equal(t, []string{"events:create", "events:update"}, roles[0].Permissions)
+	assert.Equal(t, []string{}, roles[1].Permissions)
+	assert.Equal(t, int64(4), roles[1].Users)
+	assert.Equal(t, []string{"events:update"}, roles[2].Permissions)
+	assert.False(t, roles[2].Builtin)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetRole_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewRoleRepository(db)
+
+	mock.ExpectQuery("FROM roles r WHERE r.name = \\?").
+		WithArgs("ghost").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "description", "builtin", "users"}))
+
+	_, err = repo.GetRole("ghost")
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewRoleRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO roles \\(name, description\\) VALUES \\(\\?, \\?\\) ON CONFLICT \\(name\\) DO NOTHING").
+		WithArgs("editor", "Edits events").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT OR IGNORE INTO role_permissions").
+		WithArgs("editor", "events:update").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT OR IGNORE INTO role_permissions").
+		WithArgs("editor", "events:translate").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	err = repo.CreateRole(repos.Role{
+		Name:        "editor",
+		Description: "Edits events",
+		Permissions: []string{"events:update", "events:translate"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateRole_AlreadyExists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewRoleRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO roles").
+		WithArgs("user", "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.CreateRole(repos.Role{Name: "user"})
+	assert.True(t, errors.Is(err, repos.ErrAlreadyExists))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateRole_ReplacesPermissions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewRoleRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE roles SET description = \\? WHERE name = \\?").
+		WithArgs("Edits and deletes events", "editor").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM role_permissions WHERE role = \\?").
+		WithArgs("editor").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT OR IGNORE INTO role_permissions").
+		WithArgs("editor", "events:delete").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = repo.UpdateRole(repos.Role{
+		Name:        "editor",
+		Description: "Edits and deletes events",
+		Permissions: []string{"events:delete"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRole_InUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewRoleRepository(db)
+
+	mock.ExpectQuery("SELECT \\(SELECT COUNT\\(\\*\\) FROM users WHERE role = \\?\\) \\+ \\(SELECT COUNT\\(\\*\\) FROM organization_members WHERE role = \\?\\)").
+		WithArgs("editor", "editor").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	err = repo.DeleteRole("editor")
+	assert.True(t, errors.Is(err, repos.ErrInUse))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewRoleRepository(db)
+
+	mock.ExpectQuery("SELECT \\(SELECT COUNT\\(\\*\\) FROM users WHERE role = \\?\\) \\+ \\(SELECT COUNT\\(\\*\\) FROM organization_members WHERE role = \\?\\)").
+		WithArgs("editor", "editor").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("DELETE FROM roles WHERE name = \\? AND builtin = 0").
+		WithArgs("editor").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, repo.DeleteRole("editor"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRoleHasPermission(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewRoleRepository(db)
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM role_permissions WHERE role = \\? AND permission = \\?\\)").
+		WithArgs("editor", "events:update").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	granted, err := repo.RoleHasPermission("editor", "events:update")
+	assert.NoError(t, err)
+	assert.True(t, granted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/repos"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	token := newRefreshToken()
+	session := repos.Session{UserID: 1, Device: "Firefox on Linux", IP: "127.0.0.1", UserAgent: "Mozilla/5.0"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO sessions").
+		WithArgs(int64(1), "family", "Firefox on Linux", "127.0.0.1", "Mozilla/5.0", "2025-05-18 10:00:00").
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(int64(1), "family", "hash", "jti", "2025-05-17 10:15:00", "2025-05-18 10:00:00").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	id, err := repo.CreateSession(session, token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserSessions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "family_id", "device", "ip", "user_agent", "created_at", "last_seen_at", "current"}).
+		AddRow(2, 1, "second", "Chrome on Android", "10.0.0.2", "Mozilla/5.0 (Linux; Android 14)", "2025-05-17 10:00:00", "2025-05-17 12:00:00", true).
+		AddRow(1, 1, "first", "curl", "10.0.0.1", "curl/8.0", "2025-05-16 10:00:00", "2025-05-16 10:00:00", false)
+	mock.ExpectQuery("SELECT (.+) FROM sessions s WHERE s.user_id = \\? AND s.revoked_at IS NULL").
+		WithArgs("jti", int64(1)).
+		WillReturnRows(rows)
+
+	sessions, err := repo.GetUserSessions(1, "jti")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, "Chrome on Android", sessions[0].Device)
+	assert.True(t, sessions[0].Current)
+	assert.False(t, sessions[1].Current)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT family_id FROM sessions WHERE id = \\? AND user_id = \\?").
+		WithArgs(int64(2), int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"family_id"}).AddRow("family"))
+	mock.ExpectExec("INSERT OR IGNORE INTO revoked_tokens").
+		WithArgs("family").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+		WithArgs("family").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").
+		WithArgs("family").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.RevokeSession(1, 2)
+	assert.NoError(t, err)
+
+	// Sessions of other users are not found
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT family_id FROM sessions").
+		WithArgs(int64(3), int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"family_id"}))
+	mock.ExpectRollback()
+
+	err = repo.RevokeSession(1, 3)
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT OR IGNORE INTO revoked_tokens (.+) WHERE family_id IN \\(SELECT family_id FROM sessions WHERE user_id = \\?\\)").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err = repo.RevokeUserSessions(1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/repos"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRefreshToken() repos.RefreshToken {
+	now := time.Date(2025, 5, 17, 10, 0, 0, 0, time.UTC)
+	return repos.RefreshToken{
+		UserID:          1,
+		FamilyID:        "family",
+		TokenHash:       "hash",
+		AccessTokenID:   "jti",
+		AccessExpiresAt: now.Add(15 * time.Minute),
+		ExpiresAt:       now.Add(24 * time.Hour),
+	}
+}
+
+func TestGetRefreshToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "username", "role", "family_id", "token_hash", "access_token_id", "used", "revoked", "expired"}).
+		AddRow(3, 1, "testuser", "user", "family", "hash", "jti", true, false, false)
+	mock.ExpectQuery("SELECT (.+) FROM refresh_tokens t JOIN users u ON u.id = t.user_id WHERE t.token_hash = \\?").
+		WithArgs("hash").
+		WillReturnRows(rows)
+
+	token, err := repo.GetRefreshToken("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, &repos.RefreshToken{
+		ID:            3,
+		UserID:        1,
+		Username:      "testuser",
+		Role:          "user",
+		FamilyID:      "family",
+		TokenHash:     "hash",
+		AccessTokenID: "jti",
+		Used:          true,
+	}, token)
+
+	mock.ExpectQuery("SELECT (.+) FROM refresh_tokens").
+		WithArgs("unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	token, err = repo.GetRefreshToken("unknown")
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	next := newRefreshToken()
+	next.TokenHash = "next"
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = \\? AND used_at IS NULL").
+		WithArgs("hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(int64(1), "family", "next", "jti", "2025-05-17 10:15:00", "2025-05-18 10:00:00").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("UPDATE sessions SET last_seen_at = CURRENT_TIMESTAMP, ip = \\?, expires_at = \\? WHERE family_id = \\?").
+		WithArgs("127.0.0.1", "2025-05-18 10:00:00", "family").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.RotateRefreshToken("hash", next, "127.0.0.1")
+	assert.NoError(t, err)
+
+	// A token that was already traded is not rotated again
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE refresh_tokens SET used_at").
+		WithArgs("hash").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.RotateRefreshToken("hash", next, "127.0.0.1")
+	assert.True(t, errors.Is(err, repos.ErrTokenReused))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeTokenFamily(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT OR IGNORE INTO revoked_tokens (.+) SELECT access_token_id, access_expires_at FROM refresh_tokens WHERE family_id = \\?").
+		WithArgs("family").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = \\?").
+		WithArgs("family").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = \\?").
+		WithArgs("family").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.RevokeTokenFamily("family")
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+	expiresAt := time.Date(2025, 5, 17, 10, 15, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT OR IGNORE INTO revoked_tokens \\(token_id, expires_at\\) VALUES").
+		WithArgs("jti", "2025-05-17 10:15:00").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT OR IGNORE INTO revoked_tokens (.+) WHERE family_id IN \\(SELECT family_id FROM refresh_tokens WHERE access_token_id = \\?\\)").
+		WithArgs("jti").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at").
+		WithArgs("jti").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sessions SET revoked_at").
+		WithArgs("jti").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.Logout("jti", expiresAt)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsTokenRevoked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM revoked_tokens WHERE token_id = \\?\\)").
+		WithArgs("jti").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	revoked, err := repo.IsTokenRevoked("jti")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
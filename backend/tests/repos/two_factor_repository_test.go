@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"errors"
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// The secret "12345678901234567890" from RFC 6238, base32 encoded
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFCVectors(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range vectors {
+		code, err := helpers.TOTPCode(rfcTOTPSecret, helpers.TOTPStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := helpers.TOTPStep(now)
+	previous, err := helpers.TOTPCode(rfcTOTPSecret, current-1)
+	assert.NoError(t, err)
+
+	step, ok := helpers.ValidateTOTP(rfcTOTPSecret, "005924", now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, current, step)
+
+	// Codes from the step before are still accepted to allow for clock drift
+	step, ok = helpers.ValidateTOTP(rfcTOTPSecret, previous, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, current-1, step)
+
+	// But not once that step was used
+	_, ok = helpers.ValidateTOTP(rfcTOTPSecret, "005924", now, current)
+	assert.False(t, ok)
+	_, ok = helpers.ValidateTOTP(rfcTOTPSecret, "000000", now, 0)
+	assert.False(t, ok)
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := helpers.GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	for _, code := range codes {
+		assert.Regexp(t, "^[a-z0-9]{5}-[a-z0-9]{5}$", code)
+		assert.Equal(t, code, helpers.NormalizeRecoveryCode(code))
+	}
+
+	assert.Equal(t, "abcde-fghjk", helpers.NormalizeRecoveryCode("ABCDEFGHJK"))
+}
+
+func TestGetTwoFactor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectQuery("SELECT totp_enabled, totp_secret, totp_pending_secret, totp_last_step").
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"totp_enabled", "totp_secret", "totp_pending_secret", "totp_last_step", "count"}).
+			AddRow(true, rfcTOTPSecret, nil, 41152263, 8))
+
+	tf, err := repo.GetTwoFactor(1)
+	assert.NoError(t, err)
+	assert.True(t, tf.Enabled)
+	assert.Equal(t, rfcTOTPSecret, tf.Secret)
+	assert.Equal(t, "", tf.PendingSecret)
+	assert.Equal(t, int64(41152263), tf.LastStep)
+	assert.Equal(t, 8, tf.RecoveryCodesLeft)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnableTwoFactor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET totp_secret = totp_pending_secret").
+		WithArgs(int64(41152263), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_codes WHERE user_id = \\?").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO recovery_codes").
+		WithArgs(int64(1), "first").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO recovery_codes").
+		WithArgs(int64(1), "second").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	err = repo.EnableTwoFactor(1, 41152263, []string{"first", "second"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEnableTwoFactor_NoPendingSecret(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET totp_secret = totp_pending_secret").
+		WithArgs(int64(41152263), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.EnableTwoFactor(1, 41152263, []string{"first"})
+	assert.True(t, errors.Is(err, repos.ErrNotFound))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUseTOTPStep_Replayed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectExec("UPDATE users SET totp_last_step = \\? WHERE id = \\? AND totp_last_step < \\?").
+		WithArgs(int64(41152263), int64(1), int64(41152263)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users SET totp_last_step = \\? WHERE id = \\? AND totp_last_step < \\?").
+		WithArgs(int64(41152263), int64(1), int64(41152263)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	used, err := repo.UseTOTPStep(1, 41152263)
+	assert.NoError(t, err)
+	assert.True(t, used)
+
+	used, err = repo.UseTOTPStep(1, 41152263)
+	assert.NoError(t, err)
+	assert.False(t, used)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUseRecoveryCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectExec("UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE user_id = \\? AND code_hash = \\? AND used_at IS NULL").
+		WithArgs(int64(1), "hash").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	used, err := repo.UseRecoveryCode(1, "hash")
+	assert.NoError(t, err)
+	assert.False(t, used)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET totp_secret = NULL").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM recovery_codes WHERE user_id = \\?").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectCommit()
+
+	err = repo.DisableTwoFactor(1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTwoFactorRequiredRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewAuthRepository(db)
+
+	mock.ExpectExec("INSERT INTO settings").
+		WithArgs("two_factor_required_roles", "admin").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT value FROM settings WHERE key = \\?").
+		WithArgs("two_factor_required_roles").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("admin"))
+	mock.ExpectQuery("SELECT value FROM settings WHERE key = \\?").
+		WithArgs("two_factor_required_roles").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("admin"))
+
+	assert.NoError(t, repo.SetTwoFactorRequiredRoles([]string{"admin"}))
+
+	required, err := repo.IsTwoFactorRequired("admin")
+	assert.NoError(t, err)
+	assert.True(t, required)
+
+	required, err = repo.IsTwoFactorRequired("user")
+	assert.NoError(t, err)
+	assert.False(t, required)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
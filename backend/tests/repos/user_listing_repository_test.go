@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"immodi/submission-backend/repos"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var listedUserColumns = []string{"id", "username", "role", "tickets", "created_at", "display_name", "email", "email_verified_at", "organization", "bio", "preferred_language", "timezone", "avatar_image_id", "avatar_hash"}
+
+func TestListUsers_FiltersAndSort(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db)
+
+	after := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	minTickets := int64(1)
+	query := repos.UserQuery{
+		Search:       "ja",
+		Role:         "user",
+		CreatedAfter: &after,
+		MinTickets:   &minTickets,
+		Sort:         "createdAt",
+		Descending:   true,
+		Limit:        10,
+		Offset:       10,
+	}
+
+	where := "WHERE deleted_at IS NULL AND \\(username LIKE \\? ESCAPE '\\\\' OR email LIKE \\? ESCAPE '\\\\'\\) AND role = \\? AND created_at >= \\? AND tickets >= \\?"
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users "+where).
+		WithArgs("ja%", "ja%", "user", "2025-05-01 00:00:00", minTickets).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	mock.ExpectQuery("SELECT (.+) FROM users "+where+" ORDER BY created_at DESC, id DESC LIMIT \\? OFFSET \\?").
+		WithArgs("ja%", "ja%", "user", "2025-05-01 00:00:00", minTickets, 10, 10).
+		WillReturnRows(sqlmock.NewRows(listedUserColumns).
+			AddRow(int64(4), "jane", "user", int64(3), "2025-05-02T10:00:00Z", "", nil, nil, "", "", "", "", nil, nil))
+
+	users, count, err := repo.ListUsers(query)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 11, count)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "jane", users[0].Username)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListUsers_EscapesSearchAndDefaultsToId(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db).InOrganization(2)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users WHERE (.+) AND id IN \\(SELECT user_id FROM organization_members WHERE organization_id = 2\\)").
+		WithArgs("50\\%\\_off%", "50\\%\\_off%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE (.+) ORDER BY id ASC LIMIT \\? OFFSET \\?").
+		WithArgs("50\\%\\_off%", "50\\%\\_off%", 10, 0).
+		WillReturnRows(sqlmock.NewRows(listedUserColumns))
+
+	users, count, err := repo.ListUsers(repos.UserQuery{Search: "50%_off", Limit: 10})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Empty(t, users)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
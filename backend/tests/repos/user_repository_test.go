@@ -1,10 +1,11 @@
 package tests
 
 import (
-	"database/sql"
+	"errors"
 	"immodi/submission-backend/helpers"
 	"immodi/submission-backend/repos"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -17,11 +18,11 @@ func TestGetAllUsers(t *testing.T) {
 
 	repo := repos.NewUserRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at"}).
-		AddRow(int64(1), "user1", "admin", int64(3), "2025-05-17T10:00:00Z").
-		AddRow(int64(2), "user2", "user", int64(1), "2025-05-16T09:00:00Z")
+	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at", "display_name", "email", "email_verified_at", "organization", "bio", "preferred_language", "timezone", "avatar_image_id", "avatar_hash"}).
+		AddRow(int64(1), "user1", "admin", int64(3), "2025-05-17T10:00:00Z", "", nil, nil, "", "", "", "", nil, nil).
+		AddRow(int64(2), "user2", "user", int64(1), "2025-05-16T09:00:00Z", "", nil, nil, "", "", "", "", nil, nil)
 
-	mock.ExpectQuery("SELECT id, username, role, tickets, created_at FROM users").
+	mock.ExpectQuery("SELECT id, username, role, tickets, created_at, display_name, email, email_verified_at, organization, bio, preferred_language, timezone, avatar_image_id, \\(SELECT hash FROM images WHERE images.id = users.avatar_image_id\\) FROM users").
 		WillReturnRows(rows)
 
 	users, err := repo.GetAllUsers()
@@ -43,10 +44,10 @@ func TestGetUserByUsername(t *testing.T) {
 	repo := repos.NewUserRepository(db)
 
 	username := "user1"
-	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at"}).
-		AddRow(int64(1), username, "admin", int64(5), "2025-05-17T10:00:00Z")
+	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at", "display_name", "email", "email_verified_at", "organization", "bio", "preferred_language", "timezone", "avatar_image_id", "avatar_hash"}).
+		AddRow(int64(1), username, "admin", int64(5), "2025-05-17T10:00:00Z", "", nil, nil, "", "", "", "", nil, nil)
 
-	mock.ExpectQuery("SELECT id, username, role, tickets, created_at FROM users WHERE username = ?").
+	mock.ExpectQuery("SELECT id, username, role, tickets, created_at, display_name, email, email_verified_at, organization, bio, preferred_language, timezone, avatar_image_id, \\(SELECT hash FROM images WHERE images.id = users.avatar_image_id\\) FROM users WHERE username = ?").
 		WithArgs(username).
 		WillReturnRows(rows)
 
@@ -71,14 +72,12 @@ func TestCreateUser_UserAlreadyExists(t *testing.T) {
 	username := "user1"
 	password := "pass123"
 
-	// Mock GetUserByUsername returns a user (exists)
-	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at"}).
-		AddRow(int64(1), username, "user", int64(1), "2025-05-17T10:00:00Z")
-	mock.ExpectQuery("SELECT id, username, role, tickets, created_at FROM users WHERE username = ?").
+	// The username is taken
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE username = \\?\\)").
 		WithArgs(username).
-		WillReturnRows(rows)
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
-	id, err := repo.CreateUser(username, password)
+	id, err := repo.CreateUser(username, password, "", "")
 
 	assert.Error(t, err)
 	assert.Equal(t, int64(400), id)
@@ -88,6 +87,26 @@ func TestCreateUser_UserAlreadyExists(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestCreateUser_DeletedUserKeepsUsername(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db)
+
+	// Deleted users aren't left out, their username is still UNIQUE
+	mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM users WHERE username = ?)").
+		WithArgs("deleted1").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	status, err := repo.CreateUser("deleted1", "correct-horse-42", "", "")
+
+	assert.Error(t, err)
+	assert.Equal(t, int64(400), status)
+	assert.Equal(t, "user 'deleted1' already exists", err.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestCreateUser_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -96,7 +115,7 @@ func TestCreateUser_Success(t *testing.T) {
 	repo := repos.NewUserRepository(db)
 
 	username := "newuser"
-	password := "pass123"
+	password := "correct-horse-42"
 	mockHash := "$2a$10$mockedhashedpassword"
 
 	// Save original function to restore later
@@ -109,17 +128,17 @@ func TestCreateUser_Success(t *testing.T) {
 		return mockHash, nil
 	}
 
-	// Mock GetUserByUsername to return no rows (user does not exist)
-	mock.ExpectQuery("SELECT id, username, role, tickets, created_at FROM users WHERE username = ?").
+	// The username is free
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE username = \\?\\)").
 		WithArgs(username).
-		WillReturnError(sql.ErrNoRows) // proper no rows simulation
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 	// Expect insert with the mocked hashed password
 	mock.ExpectExec("INSERT INTO users").
-		WithArgs(username, mockHash).
+		WithArgs(username, mockHash, nil, "").
 		WillReturnResult(sqlmock.NewResult(10, 1))
 
-	id, err := repo.CreateUser(username, password)
+	id, err := repo.CreateUser(username, password, "", "")
 
 	assert.NoError(t, err)
 	assert.Equal(t, int64(10), id)
@@ -137,7 +156,7 @@ func TestDeleteUser_Success(t *testing.T) {
 
 	userID := int64(1)
 
-	mock.ExpectExec("DELETE FROM users WHERE id = ?").
+	mock.ExpectExec("UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?").
 		WithArgs(userID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -158,7 +177,7 @@ func TestDeleteUser_NotFound(t *testing.T) {
 
 	userID := int64(999)
 
-	mock.ExpectExec("DELETE FROM users WHERE id = ?").
+	mock.ExpectExec("UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?").
 		WithArgs(userID).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
@@ -166,107 +185,119 @@ func TestDeleteUser_NotFound(t *testing.T) {
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no user found")
+	assert.ErrorIs(t, err, repos.ErrNotFound)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestUpdateUserRole(t *testing.T) {
-	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+func TestGetDeletedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer db.Close()
 
 	repo := repos.NewUserRepository(db)
 
-	userID := int64(1)
-	role := "admin"
+	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at", "display_name", "email", "email_verified_at", "organization", "bio", "preferred_language", "timezone", "avatar_image_id", "avatar_hash", "deleted_at"}).
+		AddRow(int64(3), "gone", "user", int64(2), "2025-05-16T09:00:00Z", "", nil, nil, "", "", "", "", nil, nil, "2025-05-20 10:00:00")
 
-	mock.ExpectExec(`UPDATE users SET role = \? WHERE id = \?`).
-		WithArgs(role, userID).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, username, role, tickets, created_at, display_name, email, email_verified_at, organization, bio, preferred_language, timezone, avatar_image_id, \\(SELECT hash FROM images WHERE images.id = users.avatar_image_id\\), deleted_at FROM users WHERE deleted_at IS NOT NULL").
+		WillReturnRows(rows)
 
-	err = repo.UpdateUserRole(userID, role)
+	users, err := repo.GetDeletedUsers()
 
 	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+	assert.Equal(t, "gone", users[0].Username)
+	assert.Equal(t, "2025-05-20 10:00:00", *users[0].DeletedAt)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestIsAdmin_True(t *testing.T) {
+func TestRestoreUser(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer db.Close()
 
 	repo := repos.NewUserRepository(db)
 
-	username := "adminuser"
+	userID := int64(3)
 
-	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at"}).
-		AddRow(int64(1), username, "admin", int64(0), "2025-05-17T10:00:00Z")
-
-	mock.ExpectQuery("SELECT id, username, role, tickets, created_at FROM users WHERE username = ?").
-		WithArgs(username).
-		WillReturnRows(rows)
+	mock.ExpectExec("UPDATE users SET deleted_at = NULL WHERE id = ?").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	isAdmin := repo.IsAdmin(username)
+	err = repo.RestoreUser(userID)
 
-	assert.True(t, isAdmin)
+	assert.NoError(t, err)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestIsAdmin_False(t *testing.T) {
+func TestPurgeDeletedUsers(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer db.Close()
 
 	repo := repos.NewUserRepository(db)
 
-	username := "regularuser"
+	cutoff := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
 
-	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at"}).
-		AddRow(int64(2), username, "user", int64(0), "2025-05-17T10:00:00Z")
+	mock.ExpectExec("DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < ?").
+		WithArgs("2025-05-01 12:00:00").
+		WillReturnResult(sqlmock.NewResult(0, 2))
 
-	mock.ExpectQuery("SELECT id, username, role, tickets, created_at FROM users WHERE username = ?").
-		WithArgs(username).
-		WillReturnRows(rows)
-
-	isAdmin := repo.IsAdmin(username)
+	purged, err := repo.PurgeDeletedUsers(cutoff)
 
-	assert.False(t, isAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestIsSameUser_True(t *testing.T) {
-	db, mock, err := sqlmock.New()
+func TestUpdateUserRole(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
 	assert.NoError(t, err)
 	defer db.Close()
 
 	repo := repos.NewUserRepository(db)
 
 	userID := int64(1)
-	username := "user1"
-
-	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at"}).
-		AddRow(userID, username, "user", int64(0), "2025-05-17T10:00:00Z")
+	role := "admin"
 
-	mock.ExpectQuery("SELECT id, username, role, tickets, created_at FROM users WHERE id = ?").
-		WithArgs(userID).
-		WillReturnRows(rows)
+	mock.ExpectExec(`UPDATE users SET role = \? WHERE id = \?`).
+		WithArgs(role, userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	isSame := repo.IsSameUser(username, userID)
+	err = repo.UpdateUserRole(userID, role)
 
-	assert.True(t, isSame)
+	assert.NoError(t, err)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestIsSameUser_False(t *testing.T) {
+func TestUpdateUserRole_OtherOrganization(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db).InOrganization(2)
+
+	// The user isn't a member of organization 2, so nothing is updated
+	mock.ExpectExec(`UPDATE users SET role = \? WHERE id = \? AND deleted_at IS NULL AND id IN \(SELECT user_id FROM organization_members WHERE organization_id = 2\)`).
+		WithArgs("admin", int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.UpdateUserRole(5, "admin")
+	assert.ErrorIs(t, err, repos.ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveOneTicketFromUser(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer db.Close()
@@ -274,38 +305,81 @@ func TestIsSameUser_False(t *testing.T) {
 	repo := repos.NewUserRepository(db)
 
 	userID := int64(1)
-	username := "user1"
 
-	// Return no rows (user not found)
-	mock.ExpectQuery("SELECT id, username, role, tickets, created_at FROM users WHERE id = ?").
+	mock.ExpectExec("UPDATE users SET tickets = tickets - 1 WHERE id = ?").
 		WithArgs(userID).
-		WillReturnError(sqlmock.ErrCancelled) // simulate no rows or error
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	isSame := repo.IsSameUser(username, userID)
+	err = repo.RemoveOneTicketFromUser(userID)
 
-	assert.False(t, isSame)
+	assert.NoError(t, err)
 
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
 
-func TestRemoveOneTicketFromUser(t *testing.T) {
+func TestCreateUser_PasswordPolicy(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
 	defer db.Close()
 
 	repo := repos.NewUserRepository(db)
 
-	userID := int64(1)
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE username = \\?\\)").
+		WithArgs("newuser").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
-	mock.ExpectExec("UPDATE users SET tickets = tickets - 1 WHERE id = ?").
-		WithArgs(userID).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+	status, err := repo.CreateUser("newuser", "password", "", "")
 
-	err = repo.RemoveOneTicketFromUser(userID)
+	assert.Equal(t, int64(400), status)
+	var policyErr *helpers.PasswordPolicyError
+	assert.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, []helpers.PasswordViolation{
+		{Rule: "breached", Message: "is too common, it appears in lists of breached passwords"},
+	}, policyErr.Violations)
 
+	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
+}
 
-	err = mock.ExpectationsWereMet()
+func TestCreateUser_EmailTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db)
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE username = \\?\\)").
+		WithArgs("newuser").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM users WHERE email = \\?\\)").
+		WithArgs("jane@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	status, err := repo.CreateUser("newuser", "correct-horse-42", "jane@example.com", "Jane")
+	assert.Error(t, err)
+	assert.Equal(t, int64(400), status)
+	assert.Contains(t, err.Error(), "already in use")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserById_EmailVerified(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	repo := repos.NewUserRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "username", "role", "tickets", "created_at", "display_name", "email", "email_verified_at", "organization", "bio", "preferred_language", "timezone", "avatar_image_id", "avatar_hash"}).
+		AddRow(int64(7), "jane", "user", int64(3), "2025-05-17T10:00:00Z", "Jane Doe", "jane@example.com", "2025-05-17T11:00:00Z", "", "", "", "", nil, nil)
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE id = ?").
+		WithArgs(int64(7)).
+		WillReturnRows(rows)
+
+	user, err := repo.GetUserById(7)
 	assert.NoError(t, err)
+	assert.Equal(t, "Jane Doe", user.DisplayName)
+	assert.Equal(t, "jane@example.com", *user.Email)
+	assert.True(t, user.EmailVerified)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"immodi/submission-backend/helpers"
+	"immodi/submission-backend/repos"
+	"immodi/submission-backend/routes"
+	"immodi/submission-backend/sso"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// capture is a sqlmock argument that accepts anything and keeps it.
+type capture struct{ value driver.Value }
+
+func (c *capture) Match(v driver.Value) bool {
+	c.value = v
+	return true
+}
+
+type pendingLink struct {
+	verifier, nonce, browserHash capture
+	cookie                       *http.Cookie
+	callback                     url.Values
+}
+
+// startLink starts linking the account of user 1 and follows the provider's
+// login page up to the redirect back, like the browser of that user would.
+func startLink(t *testing.T, mock sqlmock.Sqlmock, authRepo repos.AuthInterface, provider *sso.Provider) *pendingLink {
+	link := &pendingLink{}
+	mock.ExpectExec("INSERT INTO oidc_logins").
+		WithArgs(sqlmock.AnyArg(), &link.verifier, &link.nonce, int64(1), &link.browserHash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/oidc/link", nil)
+	req = req.WithContext(helpers.WithPrincipal(req.Context(), &helpers.Principal{ID: 1, Username: "attacker"}))
+	res := httptest.NewRecorder()
+	routes.LinkOIDC(authRepo, provider)(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	cookies := res.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	link.cookie = cookies[0]
+	assert.True(t, link.cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, link.cookie.SameSite)
+
+	var body struct {
+		AuthorizationURL string `json:"authorizationUrl"`
+	}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	idpRes, err := client.Get(body.AuthorizationURL)
+	assert.NoError(t, err)
+	defer idpRes.Body.Close()
+
+	callback, err := url.Parse(idpRes.Header.Get("Location"))
+	assert.NoError(t, err)
+	link.callback = callback.Query()
+	return link
+}
+
+func (link *pendingLink) expectConsume(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("DELETE FROM oidc_logins").
+		WithArgs(helpers.HashToken(link.callback.Get("state"))).
+		WillReturnRows(sqlmock.NewRows([]string{"code_verifier", "nonce", "user_id", "browser_hash", "expires_at"}).
+			AddRow(link.verifier.value, link.nonce.value, int64(1), link.browserHash.value, time.Now().Add(time.Minute)))
+}
+
+// finish replays the redirect back from the provider, with cookie if not nil.
+func (link *pendingLink) finish(handler http.HandlerFunc, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+link.callback.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	res := httptest.NewRecorder()
+	handler(res, req)
+	return res
+}
+
+func TestOIDCCallback_RejectsOtherBrowser(t *testing.T) {
+	idp := newStubIdP(t, jwt.MapClaims{"sub": "victim", "preferred_username": "victim"})
+	provider := newTestProvider(idp)
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	authRepo := repos.NewAuthRepository(db)
+	callback := routes.OIDCCallback(repos.NewUserRepository(db), authRepo, repos.NewOrganizationRepository(db), provider)
+
+	// The link URL is finished by someone else, whose browser has no cookie
+	link := startLink(t, mock, authRepo, provider)
+	link.expectConsume(mock)
+	res := link.finish(callback, nil)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	// Nor does the cookie of another login
+	other := startLink(t, mock, authRepo, provider)
+	other.expectConsume(mock)
+	res = other.finish(callback, link.cookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	// Nothing got linked
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOIDCCallback_LinksInSameBrowser(t *testing.T) {
+	idp := newStubIdP(t, jwt.MapClaims{"sub": "1234", "preferred_username": "jane"})
+	provider := newTestProvider(idp)
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	authRepo := repos.NewAuthRepository(db)
+	callback := routes.OIDCCallback(repos.NewUserRepository(db), authRepo, repos.NewOrganizationRepository(db), provider)
+
+	link := startLink(t, mock, authRepo, provider)
+	link.expectConsume(mock)
+	mock.ExpectQuery("FROM user_identities").
+		WithArgs(idp.server.URL, "1234").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "role", "created_at", "password_hash"}))
+	mock.ExpectExec("INSERT INTO user_identities").
+		WithArgs(int64(1), idp.server.URL, "1234").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	res := link.finish(callback, link.cookie)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"immodi/submission-backend/sso"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func newTestProvider(idp *stubIdP) *sso.Provider {
+	return sso.NewProvider(sso.Config{
+		Issuer:        idp.server.URL,
+		ClientID:      idp.clientID,
+		ClientSecret:  idp.secret,
+		RedirectURL:   "http://localhost:8020/auth/oidc/callback",
+		Scopes:        []string{"openid", "profile", "groups"},
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		AdminGroup:    "competition-admins",
+	})
+}
+
+// authorize follows the provider's login page like a browser would, up to
+// the redirect back to the app, and returns the code it carries.
+func authorize(t *testing.T, provider *sso.Provider, state, nonce, verifier string) string {
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, verifier)
+	assert.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(authURL)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, state, callback.Query().Get("state"))
+	return callback.Query().Get("code")
+}
+
+func TestAuthCodeURL_UsesPKCE(t *testing.T) {
+	idp := newStubIdP(t, jwt.MapClaims{"sub": "1234"})
+	provider := newTestProvider(idp)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", oauth2.GenerateVerifier())
+	assert.NoError(t, err)
+
+	parsed, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	query := parsed.Query()
+	assert.Equal(t, idp.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.NotEmpty(t, query.Get("code_challenge"))
+	assert.Equal(t, "nonce", query.Get("nonce"))
+	assert.Equal(t, "state", query.Get("state"))
+}
+
+func TestExchange(t *testing.T) {
+	idp := newStubIdP(t, jwt.MapClaims{
+		"sub":                "1234",
+		"preferred_username": "jane",
+		"email":              "jane@example.com",
+		"groups":             []string{"staff", "competition-admins"},
+	})
+	provider := newTestProvider(idp)
+	verifier := oauth2.GenerateVerifier()
+
+	code := authorize(t, provider, "state", "nonce", verifier)
+	identity, err := provider.Exchange(context.Background(), code, "nonce", verifier)
+	assert.NoError(t, err)
+	assert.Equal(t, idp.server.URL, identity.Issuer)
+	assert.Equal(t, "1234", identity.Subject)
+	assert.Equal(t, "jane", identity.Username)
+	assert.Equal(t, "jane@example.com", identity.Email)
+	assert.Equal(t, []string{"staff", "competition-admins"}, identity.Groups)
+	assert.Equal(t, "admin", identity.Role(provider.Config.AdminGroup, "user"))
+	assert.Equal(t, "admin", identity.Role(provider.Config.AdminGroup, "organizer"))
+}
+
+func TestExchange_SingleGroupAndEmailUsername(t *testing.T) {
+	idp := newStubIdP(t, jwt.MapClaims{
+		"sub":    "5678",
+		"email":  "john@example.com",
+		"groups": "staff",
+	})
+	provider := newTestProvider(idp)
+	verifier := oauth2.GenerateVerifier()
+
+	code := authorize(t, provider, "state", "nonce", verifier)
+	identity, err := provider.Exchange(context.Background(), code, "nonce", verifier)
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", identity.Username)
+	assert.Equal(t, []string{"staff"}, identity.Groups)
+	assert.Equal(t, "user", identity.Role(provider.Config.AdminGroup, "admin"))
+	assert.Equal(t, "user", identity.Role(provider.Config.AdminGroup, "user"))
+	assert.Equal(t, "admin", identity.Role("", "admin"))
+}
+
+func TestIdentityRole_KeepsCustomRoles(t *testing.T) {
+	identity := &sso.Identity{Groups: []string{"staff"}}
+
+	// Roles other than admin aren't managed by the identity provider
+	assert.Equal(t, "organizer", identity.Role("competition-admins", "organizer"))
+	assert.Equal(t, "translator", identity.Role("competition-admins", "translator"))
+
+	identity.Groups = append(identity.Groups, "competition-admins")
+	assert.Equal(t, "admin", identity.Role("competition-admins", "organizer"))
+}
+
+func TestExchange_WrongVerifier(t *testing.T) {
+	idp := newStubIdP(t, jwt.MapClaims{"sub": "1234"})
+	provider := newTestProvider(idp)
+
+	code := authorize(t, provider, "state", "nonce", oauth2.GenerateVerifier())
+	_, err := provider.Exchange(context.Background(), code, "nonce", oauth2.GenerateVerifier())
+	assert.Error(t, err)
+}
+
+func TestExchange_WrongNonce(t *testing.T) {
+	idp := newStubIdP(t, jwt.MapClaims{"sub": "1234"})
+	provider := newTestProvider(idp)
+	verifier := oauth2.GenerateVerifier()
+
+	code := authorize(t, provider, "state", "nonce", verifier)
+	_, err := provider.Exchange(context.Background(), code, "another-nonce", verifier)
+	assert.Error(t, err)
+}
+
+func TestExchange_CodeUsedTwice(t *testing.T) {
+	idp := newStubIdP(t, jwt.MapClaims{"sub": "1234"})
+	provider := newTestProvider(idp)
+	verifier := oauth2.GenerateVerifier()
+
+	code := authorize(t, provider, "state", "nonce", verifier)
+	_, err := provider.Exchange(context.Background(), code, "nonce", verifier)
+	assert.NoError(t, err)
+	_, err = provider.Exchange(context.Background(), code, "nonce", verifier)
+	assert.Error(t, err)
+}
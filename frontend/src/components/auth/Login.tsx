@@ -1,14 +1,21 @@
 import { useAppContext } from "@/contexts/AppContext";
-import { signInUser } from "@/repo/auth";
+import type { Token } from "@/interfaces/models/auth";
+import { signInUser, verifyTwoFactor } from "@/repo/auth";
 import { useEffect, useState } from "react";
 import toast from "react-hot-toast";
-import { NavLink, useNavigate } from "react-router";
+import { NavLink, useLocation, useNavigate } from "react-router";
 import AdminDisclaimer from "./AdminDisclamer";
 
 const Login: React.FC = () => {
     const { isDarkMode, changeHeader, authData } = useAppContext();
     const [username, setUsername] = useState("");
     const [password, setPassword] = useState("");
+    const location = useLocation();
+    // Single sign-on logins that still need a code arrive with their challenge
+    const [challengeToken, setChallengeToken] = useState<string>(
+        location.state?.challengeToken ?? ""
+    );
+    const [code, setCode] = useState("");
     const navigate = useNavigate();
 
     useEffect(() => {
@@ -25,16 +32,35 @@ const Login: React.FC = () => {
         return <div className="p-10 text-center text-lg">Unauthorized</div>;
     }
 
+    function finishLogin(res: Token) {
+        authData.setToken(res);
+        navigate("/");
+        toast.success("Logged in successfully");
+    }
+
     function handleLoginCallback(e: React.FormEvent<HTMLFormElement>) {
         e.preventDefault();
 
-        console.log(username);
+        if (challengeToken) {
+            verifyTwoFactor(challengeToken, code)
+                .then(finishLogin)
+                .catch((err) => {
+                    toast.error(err.message);
+                    console.warn(err);
+                });
+            return;
+        }
+
         signInUser(username, password)
             .then((res) => {
-                if (res.token) {
-                    authData.setToken(res.token);
-                    navigate("/");
-                    toast.success("Logged in successfully");
+                if ("token" in res) {
+                    finishLogin(res);
+                } else if (res.twoFactorRequired) {
+                    setChallengeToken(res.challengeToken);
+                } else if (res.enrollmentRequired) {
+                    toast.error(
+                        "Your account requires two-factor authentication, set it up with an authenticator app first"
+                    );
                 }
             })
             .catch((err) => {
@@ -59,38 +85,63 @@ const Login: React.FC = () => {
                     <h2 className="text-2xl font-bold text-gray-800 dark:text-gray-100 text-center">
                         Log In
                     </h2>
-                    <div className="space-y-4">
-                        <label className="block">
-                            <span className="text-gray-700 dark:text-gray-200">
-                                Username
-                            </span>
-                            <input
-                                type="username"
-                                className="mt-1 block w-full rounded-lg bg-gray-100 dark:bg-gray-700 border border-gray-300 dark:border-gray-600 px-3 py-2 focus:outline-none focus:ring-2 focus:ring-blue-400"
-                                value={username}
-                                onChange={(e) => setUsername(e.target.value)}
-                                required
-                            />
-                        </label>
+                    {challengeToken ? (
                         <label className="block">
                             <span className="text-gray-700 dark:text-gray-200">
-                                Password
+                                Authentication code or recovery code
                             </span>
                             <input
-                                type="password"
+                                type="text"
+                                autoComplete="one-time-code"
                                 className="mt-1 block w-full rounded-lg bg-gray-100 dark:bg-gray-700 border border-gray-300 dark:border-gray-600 px-3 py-2 focus:outline-none focus:ring-2 focus:ring-blue-400"
-                                value={password}
-                                onChange={(e) => setPassword(e.target.value)}
+                                value={code}
+                                onChange={(e) => setCode(e.target.value)}
                                 required
                             />
                         </label>
-                    </div>
+                    ) : (
+                        <div className="space-y-4">
+                            <label className="block">
+                                <span className="text-gray-700 dark:text-gray-200">
+                                    Username
+                                </span>
+                                <input
+                                    type="username"
+                                    className="mt-1 block w-full rounded-lg bg-gray-100 dark:bg-gray-700 border border-gray-300 dark:border-gray-600 px-3 py-2 focus:outline-none focus:ring-2 focus:ring-blue-400"
+                                    value={username}
+                                    onChange={(e) => setUsername(e.target.value)}
+                                    required
+                                />
+                            </label>
+                            <label className="block">
+                                <span className="text-gray-700 dark:text-gray-200">
+                                    Password
+                                </span>
+                                <input
+                                    type="password"
+                                    className="mt-1 block w-full rounded-lg bg-gray-100 dark:bg-gray-700 border border-gray-300 dark:border-gray-600 px-3 py-2 focus:outline-none focus:ring-2 focus:ring-blue-400"
+                                    value={password}
+                                    onChange={(e) => setPassword(e.target.value)}
+                                    required
+                                />
+                            </label>
+                        </div>
+                    )}
                     <button
                         type="submit"
                         className="w-full py-2 cursor-pointer bg-blue-500 dark:bg-blue-600 text-white font-semibold rounded-lg hover:bg-blue-600 dark:hover:bg-blue-700 transition"
                     >
-                        Sign In
+                        {challengeToken ? "Verify" : "Sign In"}
                     </button>
+                    {import.meta.env.VITE_SSO_ENABLED === "true" &&
+                        !challengeToken && (
+                            <a
+                                href={`${import.meta.env.VITE_API_URL}/auth/oidc/login`}
+                                className="block w-full py-2 text-center border border-blue-500 text-blue-500 dark:text-blue-400 font-semibold rounded-lg hover:bg-blue-50 dark:hover:bg-gray-700 transition"
+                            >
+                                Sign in with SSO
+                            </a>
+                        )}
                     <p className="text-center text-sm text-gray-600 dark:text-gray-400">
                         Don't have an account?{" "}
                         <NavLink
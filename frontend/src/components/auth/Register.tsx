@@ -34,10 +34,19 @@ const Register: React.FC = () => {
         if (password === confirm) {
             registerUser(username, password)
                 .then((res) => {
-                    if (res.token) {
-                        authData.setToken(res.token);
+                    if ("token" in res) {
+                        authData.setToken(res);
                         navigate("/");
                         toast.success("Registered successfully");
+                    } else if (res.twoFactorRequired) {
+                        navigate("/auth/login", {
+                            state: { challengeToken: res.challengeToken },
+                        });
+                    } else if (res.enrollmentRequired) {
+                        toast.error(
+                            "Your account requires two-factor authentication, set it up with an authenticator app first"
+                        );
+                        navigate("/auth/login");
                     }
                 })
                 .catch((err) => {
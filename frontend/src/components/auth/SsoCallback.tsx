@@ -0,0 +1,40 @@
+import { useAppContext } from "@/contexts/AppContext";
+import { useEffect } from "react";
+import toast from "react-hot-toast";
+import { useNavigate } from "react-router";
+
+// The backend sends the browser here after a single sign-on login with the
+// result in the fragment, so the tokens never reach a server log.
+const SsoCallback: React.FC = () => {
+    const { authData } = useAppContext();
+    const navigate = useNavigate();
+
+    useEffect(() => {
+        const result = new URLSearchParams(window.location.hash.slice(1));
+        window.history.replaceState(null, "", window.location.pathname);
+
+        if (result.get("token")) {
+            authData.setToken({
+                token: result.get("token")!,
+                refreshToken: result.get("refreshToken") ?? "",
+                expiresIn: Number(result.get("expiresIn")),
+            });
+            toast.success("Logged in successfully");
+            navigate("/");
+        } else if (result.get("twoFactorRequired")) {
+            navigate("/auth/login", {
+                state: { challengeToken: result.get("challengeToken") },
+            });
+        } else if (result.get("message")) {
+            toast.success(result.get("message")!);
+            navigate("/");
+        } else {
+            toast.error(result.get("error") ?? "Single sign-on failed");
+            navigate("/auth/login");
+        }
+    }, []);
+
+    return <div className="p-10 text-center text-lg">Signing in...</div>;
+};
+
+export default SsoCallback;
@@ -38,7 +38,7 @@ const EventUpdate: React.FC = () => {
 
         // console.log(data);
 
-        await updateEvent(authData.token, Number(id), data);
+        await updateEvent(authData.token, Number(id), data, eventData.version ?? 0);
         toast.success("Event updated!");
         navigate("/");
     }
@@ -143,9 +143,9 @@ const EventView: React.FC = () => {
                     </div>
 
                     <div className="flex items-center justify-center">
-                        {event.image ? (
+                        {event.imageUrl ? (
                             <img
-                                src={`data:image/png;base64,${event.image}`}
+                                src={`${import.meta.env.VITE_API_URL}${event.imageUrl}&size=medium`}
                                 alt={event.name}
                                 className="w-full h-64 md:h-80 object-cover rounded-lg shadow-md"
                             />
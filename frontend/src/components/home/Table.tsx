@@ -29,11 +29,11 @@ const Table: React.FC<TableProps> = ({
         }
     }, [authData.token, authData.userData.userId, authData.userData.tickets]);
 
-    function deleteEventCallback(eventId: number) {
+    function deleteEventCallback(eventId: number, version: number) {
         if (window.confirm("Are you sure you want to delete this event?")) {
             // console.log(eventId);
 
-            deleteEvent(authData.token, eventId)
+            deleteEvent(authData.token, eventId, version)
                 .then(() => {
                     toast.success("Event deleted successfully");
                     refreshEvents();
@@ -175,7 +175,8 @@ const Table: React.FC<TableProps> = ({
                                             <button
                                                 onClick={() =>
                                                     deleteEventCallback(
-                                                        event.id
+                                                        event.id,
+                                                        event.version ?? 0
                                                     )
                                                 }
                                                 title="Delete Event"
@@ -1,5 +1,7 @@
 import type { AuthInterface } from "@/interfaces/auth";
+import type { Token } from "@/interfaces/models/auth";
 import type { User } from "@/interfaces/models/user";
+import { logoutUser, refreshTokens } from "@/repo/auth";
 import { getUserData } from "@/repo/user";
 import { useState, useEffect } from "react";
 
@@ -11,12 +13,16 @@ const BLANK_USER: User = {
     role: "user",
 };
 
+// Refresh the access token this many seconds before it expires
+const REFRESH_MARGIN = 60;
+
 function useAuthed(): AuthInterface {
     const [isAuthed, setIsAuthed] = useState(!!localStorage.getItem("token"));
     const [userData, setUserData] = useState<User>(BLANK_USER);
-    const [token, setToken] = useState(
+    const [token, setAccessToken] = useState(
         () => localStorage.getItem("token") ?? ""
     );
+    const [expiresIn, setExpiresIn] = useState(0);
 
     useEffect(() => {
         if (token) {
@@ -25,12 +31,44 @@ function useAuthed(): AuthInterface {
             setIsAuthed(true);
         } else {
             localStorage.removeItem("token");
+            localStorage.removeItem("refreshToken");
             setIsAuthed(false);
         }
     }, [token]);
 
+    // A stored access token may have expired since the last visit, so the
+    // refresh token is traded right away when the expiry isn't known
+    useEffect(() => {
+        if (!token) {
+            return;
+        }
+
+        const delay = Math.max(expiresIn - REFRESH_MARGIN, 0) * 1000;
+        const timer = setTimeout(() => {
+            const refreshToken = localStorage.getItem("refreshToken");
+            if (!refreshToken) {
+                logout();
+                return;
+            }
+            refreshTokens(refreshToken)
+                .then(setToken)
+                .catch(() => logout());
+        }, delay);
+
+        return () => clearTimeout(timer);
+    }, [token, expiresIn]);
+
+    function setToken(res: Token) {
+        localStorage.setItem("refreshToken", res.refreshToken);
+        setExpiresIn(res.expiresIn);
+        setAccessToken(res.token);
+    }
+
     function logout() {
-        setToken("");
+        if (token) {
+            logoutUser(token).catch(console.warn);
+        }
+        setAccessToken("");
         setUserData(BLANK_USER);
     }
 
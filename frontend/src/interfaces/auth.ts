@@ -1,10 +1,11 @@
+import type { Token } from "./models/auth";
 import type { User } from "./models/user";
 
 export interface AuthInterface {
     token: string;
     isAuthed: boolean;
     userData: User;
-    setToken: (token: string) => void;
+    setToken: (token: Token) => void;
     logout: () => void;
     refreshUserData: () => void;
 }
@@ -1,3 +1,12 @@
 export interface Token {
     token: string;
+    refreshToken: string;
+    expiresIn: number;
+}
+
+export interface TwoFactorChallenge {
+    challengeToken: string;
+    expiresIn: number;
+    twoFactorRequired?: boolean;
+    enrollmentRequired?: boolean;
 }
@@ -13,8 +13,10 @@ interface Event {
     date: string;
     venue: string;
     price: number;
-    image: string | null;
+    imageUrl?: string;
+    language?: string;
     translations: EventTranslation[];
+    version?: number;
 }
 
 interface EventsResponse {
@@ -1,4 +1,4 @@
-import type { Token } from "@/interfaces/models/auth";
+import type { Token, TwoFactorChallenge } from "@/interfaces/models/auth";
 import axios from "axios";
 
 const API_URL = import.meta.env.VITE_API_URL;
@@ -6,9 +6,12 @@ const HEADERS = {
     "ngrok-skip-browser-warning": "true",
 };
 
-async function signInUser(username: string, password: string): Promise<Token> {
+async function signInUser(
+    username: string,
+    password: string
+): Promise<Token | TwoFactorChallenge> {
     try {
-        const response = await axios.post<Token>(
+        const response = await axios.post<Token | TwoFactorChallenge>(
             `${API_URL}/auth/login`,
             { username, password },
             { headers: HEADERS }
@@ -22,12 +25,37 @@ async function signInUser(username: string, password: string): Promise<Token> {
     }
 }
 
+async function verifyTwoFactor(
+    challengeToken: string,
+    code: string
+): Promise<Token> {
+    // Recovery codes look like "abcde-fghjk", authenticator codes are 6 digits
+    const body = /^\d{6}$/.test(code.replace(/\s/g, ""))
+        ? { challengeToken, code }
+        : { challengeToken, recoveryCode: code };
+
+    try {
+        const response = await axios.post<Token>(
+            `${API_URL}/auth/2fa/verify`,
+            body,
+            { headers: HEADERS }
+        );
+
+        return response.data;
+    } catch (error: any) {
+        throw new Error(
+            error.response?.data?.message ||
+                `Verification failed: ${error.message}`
+        );
+    }
+}
+
 async function registerUser(
     username: string,
     password: string
-): Promise<Token> {
+): Promise<Token | TwoFactorChallenge> {
     try {
-        const response = await axios.post<Token>(
+        const response = await axios.post<Token | TwoFactorChallenge>(
             `${API_URL}/auth/register`,
             { username, password },
             { headers: HEADERS }
@@ -41,4 +69,40 @@ async function registerUser(
     }
 }
 
-export { signInUser, registerUser, HEADERS };
+async function refreshTokens(refreshToken: string): Promise<Token> {
+    try {
+        const response = await axios.post<Token>(
+            `${API_URL}/auth/refresh`,
+            { refreshToken },
+            { headers: HEADERS }
+        );
+
+        return response.data;
+    } catch (error: any) {
+        throw new Error(
+            error.response?.data?.message || `Refresh failed: ${error.message}`
+        );
+    }
+}
+
+async function logoutUser(token: string): Promise<void> {
+    try {
+        await axios.post(
+            `${API_URL}/auth/logout`,
+            {},
+            { headers: { ...HEADERS, Authorization: `Bearer ${token}` } }
+        );
+    } catch (error: any) {
+        throw new Error(
+            error.response?.data?.message || `Logout failed: ${error.message}`
+        );
+    }
+}
+
+export {
+    signInUser,
+    verifyTwoFactor,
+    registerUser, refreshTokens,
+    logoutUser,
+    HEADERS,
+};
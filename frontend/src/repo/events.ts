@@ -98,14 +98,15 @@ async function createEvent(
 async function updateEvent(
     token: string,
     eventId: number,
-    eventData: CreateEventRequest
+    eventData: CreateEventRequest,
+    version: number
 ): Promise<Event> {
     try {
         const response = await axios.put<Event>(
             `${API_URL}/events/${eventId}`,
             eventData,
             {
-                headers: getAuthHeaders(token),
+                headers: { ...getAuthHeaders(token), "If-Match": `"${version}"` },
             }
         );
         return response.data;
@@ -163,10 +164,14 @@ async function searchEvents(
     }
 }
 
-async function deleteEvent(token: string, eventId: number): Promise<void> {
+async function deleteEvent(
+    token: string,
+    eventId: number,
+    version: number
+): Promise<void> {
     try {
         await axios.delete(`${API_URL}/events/${eventId}`, {
-            headers: getAuthHeaders(token),
+            headers: { ...getAuthHeaders(token), "If-Match": `"${version}"` },
         });
     } catch (error: any) {
         throw new Error(
@@ -1,5 +1,6 @@
 import Login from "@/components/auth/Login";
 import Register from "@/components/auth/Register";
+import SsoCallback from "@/components/auth/SsoCallback";
 import { createBrowserRouter } from "react-router";
 import App from "../components/App";
 import Home from "../components/home/Home";
@@ -24,6 +25,7 @@ const router = createBrowserRouter([
                     { index: true, Component: Login },
                     { path: "login", Component: Login },
                     { path: "register", Component: Register },
+                    { path: "sso", Component: SsoCallback },
                 ],
             },
             {